@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/config"
+)
+
+func (p *printer) printGroups(groups []config.Group) {
+	if len(groups) == 0 {
+		p.hint("No groups configured. Add one with: outline-cli config group add <group> <server>...")
+		return
+	}
+
+	for _, group := range groups {
+		fmt.Printf("%s: %s\n", group.Name, strings.Join(group.Servers, ", "))
+	}
+}
+
+func (p *printer) printProfiles(profiles []config.ProfileInfo) error {
+	if p.json() {
+		return printJSON(profiles)
+	}
+
+	for _, profile := range profiles {
+		marker := " "
+		if profile.Active {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, profile.Name)
+	}
+	return nil
+}
+
+func (p *printer) printPermissionsFix(fix *config.PermissionsFix) {
+	switch {
+	case fix.Unsupported:
+		fmt.Println("File permissions are not checked on Windows.")
+	case fix.Missing:
+		p.hint("No config file at %s yet; it will be created with mode %04o.", fix.Path, fix.NewMode)
+	case !fix.Changed:
+		p.hint("%s already has mode %04o.", fix.Path, fix.OldMode)
+	default:
+		fmt.Printf("Changed mode of %s from %04o to %04o\n", fix.Path, fix.OldMode, fix.NewMode)
+	}
+}
+
+func (p *printer) printConfigEdit(edit *config.ConfigEdit) {
+	if !edit.Changed {
+		p.hint("No changes to %s.", edit.Path)
+		return
+	}
+	fmt.Printf("Saved %s (previous version in %s)\n", edit.Path, edit.BackupPath)
+}
+
+func (p *printer) printConfigBackups(backups []config.ConfigBackup) error {
+	if p.json() {
+		return printJSON(backups)
+	}
+	if len(backups) == 0 {
+		p.hint("No config backups yet. One is taken before each change to the config.")
+		return nil
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tTAKEN\tSIZE\t")
+	for _, backup := range backups {
+		current := ""
+		if backup.Current {
+			current = "same as current config"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", backup.Name, backup.Time.Local().Format(time.DateTime), p.bytes(backup.Size), current)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	p.hint("\nRestore one with: outline-cli config restore <name>")
+	return nil
+}
+
+// editorCommand returns the user's editor with its arguments, e.g. "code --wait". $VISUAL wins
+// over $EDITOR as in other tools; without either a platform default is used.
+func editorCommand() []string {
+	for _, variable := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(variable)); len(fields) > 0 {
+			return fields
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// runEditor opens path in the user's editor attached to the terminal and waits for it to exit
+func runEditor(path string) error {
+	command := editorCommand()
+	editor := exec.Command(command[0], append(command[1:], path)...)
+	editor.Stdin = os.Stdin
+	editor.Stdout = os.Stdout
+	editor.Stderr = os.Stderr
+	return editor.Run()
+}
+
+// effectiveSetting is a setting in effect for this run and where its value came from
+type effectiveSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// effectiveSettings resolves the settings that shape every command. argv is the command line
+// without the program name; go-arg fills in defaults without saying so, so it is scanned to tell
+// a flag from a default.
+func effectiveSettings(args *Args, argv []string, configPath, backupDir string) []effectiveSetting {
+	fromFlag := func(names ...string) string {
+		if flagGiven(argv, names...) {
+			return "flag"
+		}
+		return "default"
+	}
+
+	editorSource := "default"
+	for _, variable := range []string{"VISUAL", "EDITOR"} {
+		if strings.TrimSpace(os.Getenv(variable)) != "" {
+			editorSource = "env $" + variable
+			break
+		}
+	}
+
+	return []effectiveSetting{
+		{"config path", configPath, fromFlag("--profile")},
+		{"profile", args.Profile.Name, fromFlag("--profile")},
+		{"output", args.Output.Format, fromFlag("-o", "--output")},
+		{"units", args.Units.System, fromFlag("--units")},
+		{"verbosity", args.Verbosity, fromFlag("-v", "--verbosity")},
+		{"timeout", args.Timeout.String(), fromFlag("--timeout")},
+		{"dial timeout", args.DialTimeout.String(), fromFlag("--dial-timeout")},
+		{"max conns", args.MaxConns.String(), fromFlag("--max-conns")},
+		{"retries", strconv.Itoa(args.Retries), fromFlag("--retries")},
+		{"retry on", args.RetryOn.String(), fromFlag("--retry-on")},
+		{"max retry wait", args.MaxRetryWait.String(), fromFlag("--max-retry-wait")},
+		{"retry budget", retryBudget(args.RetryBudget), fromFlag("--retry-budget")},
+		{"user agent", userAgent(args.UserAgent), fromFlag("--user-agent")},
+		{"resolve", resolveList(args.Resolve), fromFlag("--resolve")},
+		{"max data limit", maxDataLimit(args.MaxDataLimit, args.Units.System), fromFlag("--max-data-limit")},
+		{"backups", strconv.Itoa(args.Backups), fromFlag("--backups")},
+		{"backup dir", backupDir, fromFlag("--backup-dir")},
+		{"editor", strings.Join(editorCommand(), " "), editorSource},
+	}
+}
+
+func maxDataLimit(limit DataSize, units string) string {
+	if limit.Bytes == 0 {
+		return "none"
+	}
+	return config.FormatBytes(limit.Bytes, units)
+}
+
+func retryBudget(budget time.Duration) string {
+	if budget == 0 {
+		return "none"
+	}
+	return budget.String()
+}
+
+func resolveList(overrides []HostOverride) string {
+	if len(overrides) == 0 {
+		return "DNS"
+	}
+	entries := make([]string, len(overrides))
+	for i, override := range overrides {
+		entries[i] = override.String()
+	}
+	return strings.Join(entries, ", ")
+}
+
+// flagGiven reports whether any of the flag names appears in argv, as "--name", "--name=value"
+// or "-n". Arguments after "--" are positional.
+func flagGiven(argv []string, names ...string) bool {
+	for _, arg := range argv {
+		if arg == "--" {
+			return false
+		}
+		for _, name := range names {
+			if arg == name || strings.HasPrefix(arg, name+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *printer) printEffectiveSettings(settings []effectiveSetting) error {
+	if p.json() {
+		return printJSON(settings)
+	}
+
+	for _, setting := range settings {
+		fmt.Printf("%-15s %s (%s)\n", setting.Name, setting.Value, setting.Source)
+	}
+	return nil
+}
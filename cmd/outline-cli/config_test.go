@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alexflint/go-arg"
+)
+
+func TestEffectiveSettings(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "nano -w")
+
+	argv := []string{"--timeout=5s", "-o", "json", "config", "effective"}
+	var args Args
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		t.Fatalf("NewParser failed: %v", err)
+	}
+	if err := parser.Parse(argv); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := map[string]effectiveSetting{
+		"timeout":  {"timeout", "5s", "flag"},
+		"output":   {"output", "json", "flag"},
+		"retries":  {"retries", "0", "default"},
+		"retry on": {"retry on", "429", "default"},
+		"editor":   {"editor", "nano -w", "env $EDITOR"},
+	}
+	for _, setting := range effectiveSettings(&args, argv, "/tmp/config.yaml", "/tmp/backups") {
+		if expected, ok := want[setting.Name]; ok && setting != expected {
+			t.Errorf("Got %+v, want %+v", setting, expected)
+		}
+	}
+}
+
+func TestFlagGiven(t *testing.T) {
+	tests := []struct {
+		name string
+		argv []string
+		want bool
+	}{
+		{"long", []string{"--output", "json"}, true},
+		{"long with value", []string{"--output=json"}, true},
+		{"short", []string{"-o", "json"}, true},
+		{"other flag with the same prefix", []string{"--output-file", "x"}, false},
+		{"after --", []string{"keys", "list", "--", "--output"}, false},
+		{"missing", []string{"keys", "list"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flagGiven(tt.argv, "-o", "--output"); got != tt.want {
+				t.Errorf("flagGiven(%v) = %v, want %v", tt.argv, got, tt.want)
+			}
+		})
+	}
+}
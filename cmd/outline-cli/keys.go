@@ -0,0 +1,550 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+	"github.com/art-shutter/outline-cli/internal/config"
+)
+
+func (p *printer) printKeys(serverName string, entries []config.KeyEntry, unused bool) error {
+	switch {
+	case p.structured():
+		return p.printStructured(entries)
+	case p.format == "csv":
+		return p.writeKeysCSV(os.Stdout, entries)
+	}
+
+	if len(entries) == 0 {
+		if unused {
+			p.hint("No unused access keys on server '%s'.", serverName)
+		} else {
+			p.hint("No access keys on server '%s'. Create one with: outline-cli keys create %s", serverName, serverName)
+		}
+		return nil
+	}
+
+	fmt.Printf("Access keys for server '%s':\n", serverName)
+	fmt.Println("==================================")
+	if unused {
+		fmt.Println("Note: zero transfer may only mean the key has not connected since the server's metrics were last reset;")
+		fmt.Println("it does not prove the key is safe to delete.")
+		p.printSeparator()
+	}
+	for _, entry := range entries {
+		fmt.Printf("ID:       %s\n", entry.ID)
+		fmt.Printf("Name:     %s\n", entry.Name)
+		fmt.Printf("Port:     %d\n", entry.Port)
+		fmt.Printf("Method:   %s\n", entry.Method)
+		fmt.Printf("Access URL: %s\n", entry.AccessURL)
+		if entry.DataLimit != nil {
+			fmt.Printf("Data Limit: %s\n", p.bytes(entry.DataLimit.Bytes))
+		}
+		if entry.UsedBytes != nil {
+			fmt.Printf("Transferred: %s\n", p.bytes(*entry.UsedBytes))
+			if entry.RemainingBytes != nil {
+				fmt.Printf("Remaining:  %s\n", p.bytes(*entry.RemainingBytes))
+			} else {
+				fmt.Printf("Remaining:  unlimited\n")
+			}
+		}
+		p.printSeparator()
+	}
+	return nil
+}
+
+// flatKeyEntry is config.KeyEntry with the data limit as a plain number, for consumers that
+// cannot handle the nested object; DataLimitBytes is null for keys without a limit
+type flatKeyEntry struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Password       string `json:"password"`
+	Port           int    `json:"port"`
+	Method         string `json:"method"`
+	AccessURL      string `json:"accessUrl"`
+	DataLimitBytes *int64 `json:"dataLimitBytes"`
+	UsedBytes      *int64 `json:"usedBytes,omitempty"`
+	RemainingBytes *int64 `json:"remainingBytes,omitempty"`
+}
+
+func flattenKeyLimits(entries []config.KeyEntry) []flatKeyEntry {
+	flat := make([]flatKeyEntry, len(entries))
+	for i, entry := range entries {
+		flat[i] = flatKeyEntry{
+			ID:             entry.ID,
+			Name:           entry.Name,
+			Password:       entry.Password,
+			Port:           entry.Port,
+			Method:         entry.Method,
+			AccessURL:      entry.AccessURL,
+			UsedBytes:      entry.UsedBytes,
+			RemainingBytes: entry.RemainingBytes,
+		}
+		if entry.DataLimit != nil {
+			flat[i].DataLimitBytes = &entry.DataLimit.Bytes
+		}
+	}
+	return flat
+}
+
+// printKeyDescription prints every field of a key, noting the sources that could not be read
+func (p *printer) printKeyDescription(description *config.KeyDescription) error {
+	if p.structured() {
+		return p.printItem(description)
+	}
+
+	fmt.Printf("Server:     %s\n", description.Server)
+	// Prints the key's own data limit, if any
+	p.printAccessKey(&description.AccessKey)
+	switch {
+	case description.EffectiveLimit == nil:
+		fmt.Printf("Data Limit: unlimited\n")
+	case description.LimitSource != "key":
+		fmt.Printf("Data Limit: %s (%s)\n", p.bytes(*description.EffectiveLimit), description.LimitSource)
+	}
+	if description.UsedBytes != nil {
+		fmt.Printf("Used:       %s\n", p.bytes(*description.UsedBytes))
+	}
+	if description.RemainingBytes != nil {
+		fmt.Printf("Remaining:  %s\n", p.bytes(*description.RemainingBytes))
+	}
+	if description.FirstSeen != nil {
+		fmt.Printf("First Seen: %s\n", description.FirstSeen.Local().Format(time.DateTime))
+	} else {
+		p.hint("First Seen: not cached yet; run: outline-cli keys cache-sync %s", description.Server)
+	}
+	if description.ExpiresAt != nil {
+		fmt.Printf("Expires:    %s\n", description.ExpiresAt.Local().Format(time.DateTime))
+	}
+	for _, unavailable := range description.Unavailable {
+		fmt.Fprintf(os.Stderr, "Unavailable: %s\n", unavailable)
+	}
+	return nil
+}
+
+// printKeyField prints one field of every key per line with no decoration, for use in shell loops
+func printKeyField(entries []config.KeyEntry, field func(config.KeyEntry) string) {
+	for _, entry := range entries {
+		fmt.Println(field(entry))
+	}
+}
+
+// writeKeysCSV writes one row per key; usage is empty when the server's metrics were unavailable
+func (p *printer) writeKeysCSV(out io.Writer, entries []config.KeyEntry) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"id", "name", "port", "method", "accessUrl", "dataLimit", "dataLimitBytes", "usage"}); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		// dataLimitBytes keeps the exact limit for keys import, since dataLimit is rounded
+		dataLimit, dataLimitBytes, usage := "", "", ""
+		if entry.DataLimit != nil {
+			dataLimit = p.bytes(entry.DataLimit.Bytes)
+			dataLimitBytes = strconv.FormatInt(entry.DataLimit.Bytes, 10)
+		}
+		if entry.UsedBytes != nil {
+			usage = p.bytes(*entry.UsedBytes)
+		}
+		record := []string{entry.ID, entry.Name, strconv.Itoa(entry.Port), entry.Method, entry.AccessURL, dataLimit, dataLimitBytes, usage}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		slog.Error("failed to write CSV output", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (p *printer) printAccessKey(accessKey *api.AccessKey) {
+	fmt.Printf("ID:         %s\n", accessKey.ID)
+	fmt.Printf("Name:       %s\n", accessKey.Name)
+	fmt.Printf("Password:   %s\n", accessKey.Password)
+	fmt.Printf("Port:       %d\n", accessKey.Port)
+	fmt.Printf("Method:     %s\n", accessKey.Method)
+	fmt.Printf("Access URL: %s\n", accessKey.AccessURL)
+	if accessKey.DataLimit != nil {
+		fmt.Printf("Data Limit: %s\n", p.bytes(accessKey.DataLimit.Bytes))
+	}
+}
+
+// resolvedKey is the output of keys resolve in JSON and YAML
+type resolvedKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// printResolvedKey prints the ID and name of a key separated by a tab, so scripts can cut the ID
+func (p *printer) printResolvedKey(accessKey *api.AccessKey) error {
+	if p.structured() {
+		return p.printItem(resolvedKey{ID: accessKey.ID, Name: accessKey.Name})
+	}
+
+	fmt.Printf("%s\t%s\n", accessKey.ID, accessKey.Name)
+	return nil
+}
+
+// printShadowsocksConfig prints the connection details decoded from an access URL
+func (p *printer) printShadowsocksConfig(config *api.ShadowsocksConfig) error {
+	if p.structured() {
+		return p.printItem(config)
+	}
+
+	fmt.Printf("Method:   %s\n", config.Method)
+	fmt.Printf("Password: %s\n", config.Password)
+	fmt.Printf("Host:     %s\n", config.Host)
+	fmt.Printf("Port:     %d\n", config.Port)
+	if config.Name != "" {
+		fmt.Printf("Name:     %s\n", config.Name)
+	}
+	return nil
+}
+
+// createMethod is the method to create keys with: empty lets --method-from-server choose,
+// unless -m was given as well
+func createMethod(cmd *CreateKeyCmd) string {
+	if cmd.MethodFromServer && !flagGiven(os.Args[1:], "-m", "--method") {
+		return ""
+	}
+	return cmd.Method.Method
+}
+
+// createdKeysOutput selects how `keys create` prints the keys it created
+type createdKeysOutput struct {
+	// batch selects "csv" to print the created keys as a spreadsheet instead of text
+	batch string
+	// file receives the batch output instead of stdout
+	file string
+	// accessURLOnly prints just the access URL of each created key
+	accessURLOnly bool
+	// idOnly prints just the ID of each created key
+	idOnly bool
+	// inviteLink also prints the Outline client invite link of each created key
+	inviteLink bool
+}
+
+// printCreatedKeys prints the created keys, then lists the failed ones on stderr when several keys were requested
+func (p *printer) printCreatedKeys(result *config.CreateResult, opts createdKeysOutput) error {
+	if err := p.writeCreatedKeys(result.Created, opts); err != nil {
+		return err
+	}
+
+	if len(result.Failed) > 0 && len(result.Created)+len(result.Failed) > 1 {
+		fmt.Fprintln(os.Stderr, "Failed keys:")
+		for _, failure := range result.Failed {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", failure.Label, failure.Err)
+		}
+	}
+	return nil
+}
+
+// writeCreatedKeys prints the created keys as text, their access URLs, or CSV to stdout or the output file
+func (p *printer) writeCreatedKeys(keys []*api.AccessKey, opts createdKeysOutput) error {
+	if opts.accessURLOnly {
+		for _, accessKey := range keys {
+			fmt.Println(accessKey.AccessURL)
+		}
+		return nil
+	}
+
+	if opts.idOnly {
+		for _, accessKey := range keys {
+			fmt.Println(accessKey.ID)
+		}
+		return nil
+	}
+
+	if opts.batch != "csv" {
+		for _, accessKey := range keys {
+			fmt.Printf("Access key created successfully!\n")
+			p.printAccessKey(accessKey)
+			if opts.inviteLink {
+				fmt.Printf("Invite Link: %s\n", api.InviteLink(accessKey.AccessURL))
+			}
+		}
+		return nil
+	}
+
+	if opts.file == "" {
+		return p.writeCreatedKeysCSV(os.Stdout, keys)
+	}
+
+	// The file holds access URLs, so only the owner may read it
+	file, err := os.OpenFile(opts.file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		slog.Error("failed to create output file", "error", err)
+		return err
+	}
+	if err := p.writeCreatedKeysCSV(file, keys); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		slog.Error("failed to write output file", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (p *printer) writeCreatedKeysCSV(out io.Writer, keys []*api.AccessKey) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"name", "accessUrl", "port", "method", "dataLimit"}); err != nil {
+		return err
+	}
+	for _, accessKey := range keys {
+		dataLimit := ""
+		if accessKey.DataLimit != nil {
+			dataLimit = p.bytes(accessKey.DataLimit.Bytes)
+		}
+		record := []string{accessKey.Name, accessKey.AccessURL, strconv.Itoa(accessKey.Port), accessKey.Method, dataLimit}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		slog.Error("failed to write CSV output", "error", err)
+		return err
+	}
+	return nil
+}
+
+// keyImportOutput is the JSON form of `keys import`
+type keyImportOutput struct {
+	Created []*api.AccessKey   `json:"created"`
+	Skipped []string           `json:"skipped"`
+	Failed  []keyFailureOutput `json:"failed"`
+}
+
+type keyFailureOutput struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// printKeyImport prints a line per key and a summary; failed keys go to stderr
+func (p *printer) printKeyImport(result *config.KeyImportResult) error {
+	if p.json() {
+		output := keyImportOutput{
+			Created: append([]*api.AccessKey{}, result.Created...),
+			Skipped: append([]string{}, result.Skipped...),
+			Failed:  []keyFailureOutput{},
+		}
+		for _, failure := range result.Failed {
+			output.Failed = append(output.Failed, keyFailureOutput{Name: failure.Label, Error: failure.Err.Error()})
+		}
+		return printJSON(output)
+	}
+
+	for _, accessKey := range result.Created {
+		fmt.Printf("Created %s\n", describeKey(*accessKey))
+	}
+	for _, name := range result.Skipped {
+		fmt.Printf("Skipped %s: a key with this name already exists\n", name)
+	}
+	for _, failure := range result.Failed {
+		fmt.Fprintf(os.Stderr, "Failed %s: %v\n", failure.Label, failure.Err)
+	}
+	fmt.Printf("%d created, %d skipped, %d failed\n", len(result.Created), len(result.Skipped), len(result.Failed))
+	return nil
+}
+
+// printUsageReport prints the keys ranked by transfer with a total footer
+func (p *printer) printUsageReport(report *config.UsageReport) error {
+	switch p.format {
+	case "json":
+		return printJSON(report)
+	case "csv":
+		return p.writeUsageReportCSV(os.Stdout, report)
+	}
+
+	fmt.Printf("Usage report for server '%s':\n", report.Server)
+	var createdAt time.Time
+	if report.ServerCreatedAt != nil {
+		createdAt = *report.ServerCreatedAt
+	}
+	fmt.Println(config.MetricsWindowNote(createdAt, time.Now()))
+	fmt.Println()
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "#\tNAME\tID\tUSED\tSHARE\tLIMIT")
+	for i, usage := range report.Keys {
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%.1f%%\t%s\n", i+1, usage.Name, usage.ID, p.bytes(usage.Bytes), usage.Share, p.limitStatus(usage))
+	}
+	fmt.Fprintf(table, "\tTotal\t\t%s\t\t\n", p.bytes(report.Total))
+	return table.Flush()
+}
+
+// printLimitAlert prints an alert of keys watch-limits as a line of text, or with --output json as
+// a JSON object on a line of its own so that the stream can be piped into other tools
+func (p *printer) printLimitAlert(alert config.LimitAlert) {
+	if p.json() {
+		data, err := json.Marshal(alert)
+		if err != nil {
+			slog.Error("failed to marshal alert", "error", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	limit := p.bytes(alert.DataLimit)
+	if alert.LimitSource != "key" {
+		limit += " (" + alert.LimitSource + ")"
+	}
+	fmt.Printf("%s ALERT %s (ID %s) on %s has used %.0f%% of its data limit: %s of %s\n",
+		alert.Time.Format(time.DateTime), alert.Name, alert.ID, alert.Server, alert.Percent, p.bytes(alert.Bytes), limit)
+}
+
+// limitStatus describes how much of its limit a key has used, e.g. "40% of 5.0 GB"
+func (p *printer) limitStatus(usage config.KeyUsage) string {
+	if usage.DataLimit == nil {
+		return "unlimited"
+	}
+	status := fmt.Sprintf("%.0f%% of %s", float64(usage.Bytes)*100/float64(max(*usage.DataLimit, 1)), p.bytes(*usage.DataLimit))
+	if usage.LimitSource != "key" {
+		status += " (" + usage.LimitSource + ")"
+	}
+	if usage.LimitStatus == config.LimitExceeded {
+		status += ", exceeded"
+	}
+	return status
+}
+
+func (p *printer) writeUsageReportCSV(out io.Writer, report *config.UsageReport) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"rank", "id", "name", "bytes", "used", "share", "dataLimit", "limitStatus"}); err != nil {
+		return err
+	}
+	for i, usage := range report.Keys {
+		dataLimit := ""
+		if usage.DataLimit != nil {
+			dataLimit = p.bytes(*usage.DataLimit)
+		}
+		record := []string{strconv.Itoa(i + 1), usage.ID, usage.Name, strconv.FormatInt(usage.Bytes, 10), p.bytes(usage.Bytes), strconv.FormatFloat(usage.Share, 'f', 1, 64), dataLimit, usage.LimitStatus}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		slog.Error("failed to write CSV output", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (p *printer) printEditResult(result *config.EditKeyResult) {
+	if result.NewName != "" {
+		fmt.Printf("Access key renamed successfully to: %s\n", result.NewName)
+	}
+	if result.LimitRemoved {
+		fmt.Printf("Data limit removed successfully\n")
+	} else if result.DataLimit != nil {
+		fmt.Printf("Data limit updated successfully to: %s\n", p.bytes(*result.DataLimit))
+	}
+}
+
+func (p *printer) printCapResults(results []config.CapResult) {
+	for _, result := range results {
+		key := describeKey(result.Key)
+		switch {
+		case result.Err != nil:
+			fmt.Printf("Failed %s: %v\n", key, result.Err)
+		case result.Skipped:
+			fmt.Printf("Skipped %s: %s limit %s is already at or below the cap\n", key, result.PreviousSource, p.bytes(result.Previous.Bytes))
+		default:
+			previous := "unlimited"
+			if result.Previous != nil {
+				previous = p.bytes(result.Previous.Bytes)
+			}
+			fmt.Printf("Capped %s: %s -> %s\n", key, previous, p.bytes(result.Limit))
+		}
+	}
+}
+
+// printPruneReport lists the keys selected for pruning and what happened to them
+func (p *printer) printPruneReport(report *config.PruneReport) error {
+	if p.structured() {
+		return p.printStructured(report)
+	}
+
+	for _, result := range report.Keys {
+		var reasons []string
+		if result.UsedBytes != nil {
+			reasons = append(reasons, "unused")
+		}
+		if result.FirstSeen != nil {
+			reasons = append(reasons, "first seen "+result.FirstSeen.Local().Format(time.DateOnly))
+		}
+		key := describeKey(result.Key)
+		switch {
+		case report.DryRun:
+			fmt.Printf("Would delete %s: %s\n", key, strings.Join(reasons, ", "))
+		case result.Error != "":
+			fmt.Printf("Failed %s: %s\n", key, result.Error)
+		default:
+			fmt.Printf("Deleted %s: %s\n", key, strings.Join(reasons, ", "))
+		}
+	}
+
+	if report.Uncached > 0 {
+		p.hint("%d keys are not in the key cache, so their age is unknown and they were skipped; run: outline-cli keys cache-sync %s", report.Uncached, report.Server)
+	}
+	switch {
+	case len(report.Keys) == 0:
+		p.hint("No keys match.")
+	case report.DryRun:
+		p.hint("Nothing was deleted. Pass --yes to delete these %d keys.", len(report.Keys))
+	}
+	return nil
+}
+
+// printRenameReport prints the old and new name of every selected key, and what happened to it
+func (p *printer) printRenameReport(report *config.RenameReport) error {
+	if p.structured() {
+		return p.printStructured(report)
+	}
+
+	for _, result := range report.Keys {
+		switch {
+		case result.Error != "":
+			fmt.Printf("Failed key %s: %s: %s\n", result.Key.ID, result.Key.Name, result.Error)
+		case report.DryRun:
+			fmt.Printf("Would rename key %s: %s -> %s\n", result.Key.ID, result.Key.Name, result.NewName)
+		default:
+			fmt.Printf("Renamed key %s: %s -> %s\n", result.Key.ID, result.Key.Name, result.NewName)
+		}
+	}
+
+	if report.Unchanged > 0 {
+		p.hint("%d keys already have the name the template gives them.", report.Unchanged)
+	}
+	switch {
+	case len(report.Keys) == 0 && report.Unchanged == 0:
+		p.hint("No keys match.")
+	case report.DryRun && len(report.Keys) > 0:
+		p.hint("Nothing was renamed. Pass --yes to rename these keys.")
+	}
+	return nil
+}
+
+func describeKey(key api.AccessKey) string {
+	if key.Name == "" {
+		return "key " + key.ID
+	}
+	return fmt.Sprintf("key %s (%s)", key.ID, key.Name)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+	"github.com/art-shutter/outline-cli/internal/config"
+)
+
+func TestWriteKeysCSV(t *testing.T) {
+	used := int64(1500)
+	entries := []config.KeyEntry{
+		{AccessKey: api.AccessKey{ID: "1", Name: "Smith, John", Port: 443, Method: "aes-192-gcm", AccessURL: "ss://a", DataLimit: &api.DataLimit{Bytes: 1000000}}, UsedBytes: &used},
+		{AccessKey: api.AccessKey{ID: "2", Name: "plain", Port: 443, Method: "aes-192-gcm", AccessURL: "ss://b"}},
+	}
+
+	var buf bytes.Buffer
+	p := &printer{}
+	if err := p.writeKeysCSV(&buf, entries); err != nil {
+		t.Fatalf("writeKeysCSV failed: %v", err)
+	}
+
+	expected := "id,name,port,method,accessUrl,dataLimit,dataLimitBytes,usage\n" +
+		"1,\"Smith, John\",443,aes-192-gcm,ss://a,1.0 MB,1000000,1.5 kB\n" +
+		"2,plain,443,aes-192-gcm,ss://b,,,\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected CSV output:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestFlattenKeyLimits(t *testing.T) {
+	entries := []config.KeyEntry{
+		{AccessKey: api.AccessKey{ID: "1", Name: "alice", DataLimit: &api.DataLimit{Bytes: 1000000}}},
+		{AccessKey: api.AccessKey{ID: "2", Name: "bob"}},
+	}
+
+	data, err := json.Marshal(flattenKeyLimits(entries))
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+
+	var keys []map[string]any
+	if err := json.Unmarshal(data, &keys); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	if got := keys[0]["dataLimitBytes"]; got != float64(1000000) {
+		t.Errorf("Expected dataLimitBytes 1000000, got %v", got)
+	}
+	if got, ok := keys[1]["dataLimitBytes"]; !ok || got != nil {
+		t.Errorf("Expected dataLimitBytes null for a key without a limit, got %v (present: %v)", got, ok)
+	}
+	if _, ok := keys[0]["dataLimit"]; ok {
+		t.Error("Expected no nested dataLimit object")
+	}
+}
+
+func TestWriteCreatedKeysCSV(t *testing.T) {
+	keys := []*api.AccessKey{
+		{ID: "1", Name: "alice", Port: 12345, Method: "aes-192-gcm", AccessURL: "ss://key1", DataLimit: &api.DataLimit{Bytes: 1000000000}},
+		{ID: "2", Name: "smith, bob", Port: 12345, Method: "aes-192-gcm", AccessURL: "ss://key2", DataLimit: &api.DataLimit{Bytes: 1000000000}},
+	}
+
+	var buf bytes.Buffer
+	p := &printer{}
+	if err := p.writeCreatedKeysCSV(&buf, keys); err != nil {
+		t.Fatalf("writeCreatedKeysCSV failed: %v", err)
+	}
+
+	expected := "name,accessUrl,port,method,dataLimit\n" +
+		"alice,ss://key1,12345,aes-192-gcm,1.0 GB\n" +
+		"\"smith, bob\",ss://key2,12345,aes-192-gcm,1.0 GB\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected CSV output:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestWriteUsageReportCSV(t *testing.T) {
+	limit := int64(1000000)
+	report := &config.UsageReport{
+		Server: "test",
+		Keys: []config.KeyUsage{
+			{ID: "2", Name: "bob", Bytes: 1500000, Share: 75, DataLimit: &limit, LimitSource: "key", LimitStatus: config.LimitExceeded},
+			{ID: "1", Name: "alice", Bytes: 500000, Share: 25, LimitStatus: config.LimitUnlimited},
+		},
+		Total: 2000000,
+	}
+
+	var buf bytes.Buffer
+	p := &printer{}
+	if err := p.writeUsageReportCSV(&buf, report); err != nil {
+		t.Fatalf("writeUsageReportCSV failed: %v", err)
+	}
+
+	expected := "rank,id,name,bytes,used,share,dataLimit,limitStatus\n" +
+		"1,2,bob,1500000,1.5 MB,75.0,1.0 MB,exceeded\n" +
+		"2,1,alice,500000,500 kB,25.0,,unlimited\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected CSV output:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
@@ -1,25 +1,103 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"time"
 
 	"github.com/alexflint/go-arg"
+	"github.com/art-shutter/outline-cli/internal/api"
 	"github.com/art-shutter/outline-cli/internal/config"
 )
 
-var Version = "dev"
-
-type VersionCmd struct{}
+type VersionCmd struct {
+	JSON bool `arg:"--json" help:"Print version and build information as JSON"`
+}
 
 type PrintConfigCmd struct{}
 
+type ParseURLCmd struct {
+	URL string `arg:"positional,required" help:"ss:// access URL to decode"`
+}
+
+type ConfigCmd struct {
+	Profiles  *ProfilesCmd  `arg:"subcommand:profiles" help:"List config profiles"`
+	FixPerms  *FixPermsCmd  `arg:"subcommand:fix-perms" help:"Make the config file readable only by its owner"`
+	Edit      *EditCmd      `arg:"subcommand:edit" help:"Edit the config file in $VISUAL or $EDITOR and validate it"`
+	Group     *GroupCmd     `arg:"subcommand:group" help:"Manage named groups of servers"`
+	Effective *EffectiveCmd `arg:"subcommand:effective" help:"Show the settings in effect and where each value comes from"`
+	Restore   *RestoreCmd   `arg:"subcommand:restore" help:"List config backups, or replace the config with one of them"`
+	Schema    *SchemaCmd    `arg:"subcommand:schema" help:"Print the JSON Schema of the config file"`
+}
+
+type SchemaCmd struct{}
+
+type GroupCmd struct {
+	Add    *GroupAddCmd    `arg:"subcommand:add" help:"Create a group or add servers to it"`
+	Remove *GroupRemoveCmd `arg:"subcommand:remove" help:"Delete a group, keeping its servers"`
+	List   *GroupListCmd   `arg:"subcommand:list" help:"List groups and their servers"`
+}
+
+type GroupAddCmd struct {
+	Group   string   `arg:"positional,required" help:"Group name"`
+	Servers []string `arg:"positional,required" help:"Servers to add to the group"`
+}
+
+type GroupRemoveCmd struct {
+	Group string `arg:"positional,required" help:"Group name"`
+}
+
+type GroupListCmd struct{}
+
+type ProfilesCmd struct{}
+
+type FixPermsCmd struct{}
+
+type EditCmd struct{}
+
+type EffectiveCmd struct{}
+
+type RestoreCmd struct {
+	Backup string `arg:"positional" help:"Name of the backup to restore; lists the backups when omitted"`
+}
+
 type Args struct {
-	Version     *VersionCmd     `arg:"subcommand:version" help:"Show version information"`
-	Servers     *ServersCmd     `arg:"subcommand:servers" help:"Manage Outline servers"`
-	Keys        *KeysCmd        `arg:"subcommand:keys" help:"Manage access keys"`
-	PrintConfig *PrintConfigCmd `arg:"subcommand:print-config" help:"Print configuration in YAML format"`
-	Verbosity   string          `arg:"-v,--verbosity" default:"info" help:"verbosity level" placeholder:"[error, warning, info, debug]"`
+	Version       *VersionCmd     `arg:"subcommand:version" help:"Show version information"`
+	Servers       *ServersCmd     `arg:"subcommand:servers" help:"Manage Outline servers"`
+	Keys          *KeysCmd        `arg:"subcommand:keys" help:"Manage access keys"`
+	PrintConfig   *PrintConfigCmd `arg:"subcommand:print-config" help:"Print configuration in YAML format"`
+	Config        *ConfigCmd      `arg:"subcommand:config" help:"Manage config profiles and the config file"`
+	ParseURL      *ParseURLCmd    `arg:"subcommand:parse-url" help:"Decode an ss:// access URL without contacting a server"`
+	Verbosity     string          `arg:"-v,--verbosity" default:"info" help:"verbosity level" placeholder:"[error, warning, info, debug]"`
+	Output        OutputFormat    `arg:"-o,--output" default:"text" help:"output format" placeholder:"[text, json, yaml, csv]"`
+	MaxConns      PositiveInt     `arg:"--max-conns" default:"8" help:"maximum connections kept open to each server, which also caps how many requests to it run at once"`
+	Timeout       time.Duration   `arg:"--timeout" default:"30s" help:"overall timeout for each request"`
+	DialTimeout   time.Duration   `arg:"--dial-timeout" default:"10s" help:"timeout for connecting to a server"`
+	Retries       int             `arg:"--retries" default:"0" help:"retry failed requests this many times, waiting as the server asks; see --retry-on"`
+	MaxRetryWait  time.Duration   `arg:"--max-retry-wait" default:"1m" help:"longest wait before retrying a failed request"`
+	RetryOn       RetryOn         `arg:"--retry-on" default:"429" help:"comma-separated failures to retry: 429, 5xx, timeout, connreset"`
+	RetryBudget   time.Duration   `arg:"--retry-budget" help:"stop retrying a request once this much time has passed since its first attempt (default: no limit)"`
+	Template      string          `arg:"--template" help:"render each result of a list or get command with this Go template, e.g. '{{.ID}} {{.Name}}'"`
+	TemplateFile  string          `arg:"--template-file" help:"like --template, reading the template from this file"`
+	JSONArray     bool            `arg:"--json-array" help:"with --output json, print the result of keys get, keys describe, keys resolve, servers get and parse-url as a one-element array like list output"`
+	Units         UnitSystem      `arg:"--units" default:"si" help:"units for displayed sizes: si (GB) or iec (GiB)" placeholder:"[si, iec]"`
+	Separator     *string         `arg:"--separator" help:"line printed between the items of text output (default ---)"`
+	NoSeparator   bool            `arg:"--no-separator" help:"print no line between the items of text output"`
+	Strict        bool            `arg:"--strict" help:"treat warnings about questionable input as errors"`
+	MaxDataLimit  DataSize        `arg:"--max-data-limit" help:"refuse to create or edit keys with a data limit above this size, e.g. 500GB (default: no maximum)"`
+	StrictJSON    bool            `arg:"--strict-json" help:"debug: fail on response fields the client does not know"`
+	UserAgent     string          `arg:"--user-agent" help:"User-Agent header sent to servers (default outline-cli/<version>)"`
+	Resolve       []HostOverride  `arg:"--resolve,separate" help:"connect to host at ip instead of its DNS address, keeping SNI and pinning (repeatable)" placeholder:"HOST:IP"`
+	SkipCertCheck bool            `arg:"--skip-cert-check" help:"debug: accept any server certificate for this run instead of the pinned one"`
+	Quiet         bool            `arg:"-q,--quiet" help:"suppress informational messages"`
+	NoInput       bool            `arg:"--no-input" help:"never prompt; fail if an operation needs input"`
+	Yes           bool            `arg:"-y,--yes" help:"answer yes to confirmation prompts"`
+	Backups       int             `arg:"--backups" default:"5" help:"number of config backups to keep, taken before each change; 0 disables them"`
+	BackupDir     string          `arg:"--backup-dir" help:"directory for config backups (default: backups next to the config file)"`
+	Profile       ProfileName     `arg:"--profile" default:"default" help:"config profile, stored as <profile>.yaml (default uses config.yaml)"`
 }
 
 func (Args) Description() string {
@@ -33,21 +111,35 @@ func (Args) Epilogue() string {
   outline-cli keys create myserver -k mykey -l 1GB
   outline-cli keys list myserver
   outline-cli servers metrics myserver
+  outline-cli servers metrics --all
+  outline-cli servers health 'prod-*'
+  outline-cli servers list --probe --output json
 
 For more information, visit: https://github.com/art-shutter/outline-cli`
 }
 
 type ServersCmd struct {
-	List    *ListCmd    `arg:"subcommand:list" help:"List all configured servers"`
-	Add     *AddCmd     `arg:"subcommand:add" help:"Add a new server with individual parameters"`
-	AddJSON *AddJSONCmd `arg:"subcommand:add-json" help:"Add a new server from JSON input"`
-	Get     *GetCmd     `arg:"subcommand:get" help:"Get server details"`
-	Update  *UpdateCmd  `arg:"subcommand:update" help:"Update server details"`
-	Delete  *DeleteCmd  `arg:"subcommand:delete" help:"Delete a server"`
-	Metrics *MetricsCmd `arg:"subcommand:metrics" help:"View server metrics"`
+	List       *ListCmd       `arg:"subcommand:list" help:"List all configured servers"`
+	Add        *AddCmd        `arg:"subcommand:add" help:"Add a new server with individual parameters"`
+	AddJSON    *AddJSONCmd    `arg:"subcommand:add-json" help:"Add a new server from JSON input"`
+	Import     *ImportCmd     `arg:"subcommand:import" help:"Add the servers of a config file"`
+	Get        *GetCmd        `arg:"subcommand:get" help:"Get server details"`
+	Update     *UpdateCmd     `arg:"subcommand:update" help:"Update server details"`
+	Delete     *DeleteCmd     `arg:"subcommand:delete" help:"Delete a server"`
+	Metrics    *MetricsCmd    `arg:"subcommand:metrics" help:"View server metrics"`
+	Health     *HealthCmd     `arg:"subcommand:health" help:"Check that servers answer on their management API"`
+	Reachable  *ReachableCmd  `arg:"subcommand:reachable" help:"Exit 0 if servers answer with their pinned certificate, 1 otherwise, printing nothing"`
+	Raw        *RawCmd        `arg:"subcommand:raw" help:"Print the raw JSON returned by a management API endpoint"`
+	Test       *TestCmd       `arg:"subcommand:test" help:"Check the connection, certificate and API of a server"`
+	FetchCerts *FetchCertsCmd `arg:"subcommand:fetch-certs" help:"Fetch the certificate fingerprint of every server without one; saves them only with --yes"`
+	Diff       *DiffCmd       `arg:"subcommand:diff" help:"Compare the key names of two servers"`
 }
 
-type ListCmd struct{}
+type ListCmd struct {
+	Probe       bool `arg:"--probe" help:"Query each server for reachability, version and key count"`
+	ShowSecrets bool `arg:"--show-secrets" help:"Show the full management URLs in JSON and YAML output"`
+	FailOnEmpty bool `arg:"--fail-on-empty" help:"Exit with code 3 when no servers are configured"`
+}
 
 type AddCmd struct {
 	Name       string     `arg:"positional,required" help:"Server name/label"`
@@ -60,13 +152,46 @@ type AddJSONCmd struct {
 	JSON string `arg:"positional,required" help:"JSON input with apiUrl and certSha256 fields"`
 }
 
+type ImportCmd struct {
+	File     string      `arg:"positional,required" help:"Config file whose servers to add"`
+	Verify   bool        `arg:"--verify" help:"Only add servers that answer with their pinned certificate"`
+	Parallel PositiveInt `arg:"--parallel" default:"8" help:"With --verify, number of servers checked in parallel"`
+}
+
 type GetCmd struct {
-	Name string `arg:"positional,required" help:"Server name"`
+	Name         string `arg:"positional,required" help:"Server name"`
+	WithKeys     bool   `arg:"--with-keys" default:"true" help:"Also show the number of access keys (--with-keys=false to skip)"`
+	ShowSecrets  bool   `arg:"--show-secrets" help:"Show the full management URL in JSON and YAML output"`
+	FlattenLimit bool   `arg:"--flatten-limit" help:"In JSON and YAML output, give the default key data limit as a number of bytes in accessKeyDataLimitBytes"`
 }
 
 type UpdateCmd struct {
-	Name string    `arg:"positional,required" help:"Server name"`
-	URL  ServerURL `arg:"--url" help:"New server URL"`
+	Name       string     `arg:"positional,required" help:"Server name"`
+	URL        ServerURL  `arg:"--url" help:"New server URL"`
+	CertSha256 CertSHA256 `arg:"--cert-sha256" help:"New certificate SHA256 fingerprint, e.g. after the server's certificate was rotated"`
+	NewName    string     `arg:"--name" help:"Rename the server in the config and on the server itself"`
+	LocalOnly  bool       `arg:"--local-only" help:"With --name, only rename the server in the config"`
+	// EndpointPrefix is a pointer so that an empty value can remove the prefix
+	EndpointPrefix *string `arg:"--endpoint-prefix" help:"Path of the management API below the URL, e.g. 'outline/api'; '' removes it"`
+	SNI            *string `arg:"--sni" help:"TLS server name to send instead of the URL's host, e.g. when the URL uses an IP; '' removes it"`
+}
+
+type FetchCertsCmd struct {
+	Parallel PositiveInt `arg:"--parallel" default:"8" help:"Number of servers dialed in parallel"`
+}
+
+type TestCmd struct {
+	Name string `arg:"positional,required" help:"Server name"`
+}
+
+type DiffCmd struct {
+	Source string `arg:"-s,--source,required" help:"Server to compare from"`
+	Target string `arg:"-d,--target,required" help:"Server to compare with"`
+}
+
+type RawCmd struct {
+	Name     string      `arg:"positional,required" help:"Server name"`
+	Endpoint APIEndpoint `arg:"positional" default:"server" help:"Endpoint to fetch: server, access-keys or metrics/transfer"`
 }
 
 type DeleteCmd struct {
@@ -74,22 +199,104 @@ type DeleteCmd struct {
 }
 
 type KeysCmd struct {
-	List   *ListKeysCmd  `arg:"subcommand:list" help:"List access keys"`
-	Create *CreateKeyCmd `arg:"subcommand:create" help:"Create a new access key"`
-	Delete *DeleteKeyCmd `arg:"subcommand:delete" help:"Delete an access key"`
-	Edit   *EditKeyCmd   `arg:"subcommand:edit" help:"Edit an existing access key"`
+	List        *ListKeysCmd       `arg:"subcommand:list" help:"List access keys"`
+	Get         *GetKeyCmd         `arg:"subcommand:get" help:"Show a single access key"`
+	Describe    *DescribeKeyCmd    `arg:"subcommand:describe" help:"Show everything known about an access key, including usage and remaining allowance"`
+	Resolve     *ResolveKeyCmd     `arg:"subcommand:resolve" help:"Print the ID and name of an access key, failing if it is not found or its name is ambiguous"`
+	Create      *CreateKeyCmd      `arg:"subcommand:create" help:"Create a new access key"`
+	Import      *ImportKeysCmd     `arg:"subcommand:import" help:"Recreate keys from a keys list exported as JSON or CSV"`
+	Delete      *DeleteKeyCmd      `arg:"subcommand:delete" help:"Delete an access key"`
+	Edit        *EditKeyCmd        `arg:"subcommand:edit" help:"Edit an existing access key"`
+	SetLimit    *SetLimitKeyCmd    `arg:"subcommand:set-limit" help:"Set the data limit of an access key"`
+	RemoveLimit *RemoveLimitKeyCmd `arg:"subcommand:remove-limit" help:"Remove the data limit of an access key"`
+	CacheSync   *CacheSyncKeyCmd   `arg:"subcommand:cache-sync" help:"Refresh the local key cache of a server from the live key list"`
+	Move        *MoveKeyCmd        `arg:"subcommand:move" help:"Move a key's local cache entry to another server after migrating the key by hand"`
+	Usage       *UsageReportCmd    `arg:"subcommand:usage-report" help:"Rank the keys of a server by transfer, with shares and limit status"`
+	Watch       *WatchLimitsCmd    `arg:"subcommand:watch-limits" help:"Poll a server and alert when keys reach a share of their data limit"`
+	BulkRename  *BulkRenameKeysCmd `arg:"subcommand:bulk-rename" help:"Rename every key whose name starts with a prefix using a template; lists the new names unless --yes is given"`
+	Prune       *PruneKeysCmd      `arg:"subcommand:prune" help:"Delete keys that are unused and/or older than an age; lists them unless --yes is given"`
 }
 
 type ListKeysCmd struct {
-	ServerName string `arg:"positional,required" help:"Server name"`
+	ServerName      string   `arg:"positional,required" help:"Server name"`
+	Unused          bool     `arg:"--unused" help:"Only show keys with zero transfer according to server metrics"`
+	UnusedThreshold DataSize `arg:"--unused-threshold" help:"With --unused, also show keys that transferred less than this (e.g., '10MB')"`
+	CheckPorts      bool     `arg:"--check-ports" help:"Warn about ports shared by several keys or below 1024"`
+	WithURLsOnly    bool     `arg:"--with-urls-only" help:"Leave out keys without an access URL and warn how many there were"`
+	IDOnly          bool     `arg:"--id-only" help:"Print only the key IDs, one per line"`
+	NameOnly        bool     `arg:"--name-only" help:"Print only the key names, one per line"`
+	FailOnEmpty     bool     `arg:"--fail-on-empty" help:"Exit with code 3 when no keys are listed"`
+	FlattenLimit    bool     `arg:"--flatten-limit" help:"In JSON and YAML output, give each key's data limit as a number of bytes in dataLimitBytes"`
 }
 
 type CreateKeyCmd struct {
-	ServerName string           `arg:"positional,required" help:"Server name"`
-	Name       string           `arg:"-k,--key-name" help:"Access key name"`
-	Method     EncryptionMethod `arg:"-m,--method" default:"aes-192-gcm" help:"Encryption method"`
-	Port       Port             `arg:"-p,--port" help:"Port number"`
-	DataLimit  DataSize         `arg:"-l,--data-limit" help:"Data limit (e.g., '1GB', '500MB', '2TB')"`
+	ServerName       string           `arg:"positional,required" help:"Server name"`
+	Name             string           `arg:"-k,--key-name" help:"Access key name"`
+	Method           EncryptionMethod `arg:"-m,--method" default:"aes-192-gcm" help:"Encryption method"`
+	MethodFromServer bool             `arg:"--method-from-server" help:"Use the method most keys on the server use, or the server's default when it has none; -m takes precedence"`
+	UniqueName       bool             `arg:"--unique-name" help:"List the server's keys first and warn, or fail with --strict, if a name is already taken"`
+	Port             Port             `arg:"-p,--port" help:"Port number"`
+	DataLimit        DataSize         `arg:"-l,--data-limit" help:"Data limit (e.g., '1GB', '500MB', '2TB', or '50%' of the server default); '0' creates a key that cannot transfer data"`
+	Unlimited        bool             `arg:"--unlimited" help:"Create the key without a data limit of its own (the default)"`
+	Reconcile        bool             `arg:"--reconcile" help:"If the create request fails, look for a key the server created anyway and adopt it"`
+	Rollback         bool             `arg:"--rollback" help:"With --reconcile, delete such a key instead of adopting it"`
+	Count            int              `arg:"--count" help:"Create this many keys, named <key-name>-1 to <key-name>-N"`
+	NamesFromFile    string           `arg:"--names-from-file" help:"Create one key per line of this file, using the line as the key name"`
+	BatchOutput      string           `arg:"--batch-output" help:"Print created keys as 'csv' (name,accessUrl,port,method,dataLimit)"`
+	OutputFile       string           `arg:"--file" help:"With --batch-output, write to this file instead of stdout"`
+	Concurrency      PositiveInt      `arg:"--concurrency" default:"4" help:"Number of keys created in parallel, at most --max-conns"`
+	AccessURLOnly    bool             `arg:"--access-url-only" help:"Print only the access URL of each created key"`
+	IDOnly           bool             `arg:"--id-only" help:"Print only the ID of each created key"`
+	InviteLink       bool             `arg:"--invite-link" help:"Also print a link that opens the key in the Outline client"`
+}
+
+type ImportKeysCmd struct {
+	ServerName  string      `arg:"positional,required" help:"Server name"`
+	File        string      `arg:"positional,required" help:"Output of keys list --output json, or --output csv in a .csv file"`
+	IfNotExists bool        `arg:"--if-not-exists" help:"Skip keys whose name already exists on the server"`
+	Concurrency PositiveInt `arg:"--concurrency" default:"4" help:"Number of keys created in parallel, at most --max-conns"`
+	StripLimit  bool        `arg:"--strip-limit" help:"Create the keys without their data limits, so the server's default limit applies"`
+}
+
+type UsageReportCmd struct {
+	ServerName string `arg:"positional,required" help:"Server name"`
+}
+
+type WatchLimitsCmd struct {
+	ServerName string        `arg:"positional,required" help:"Server name"`
+	Threshold  float64       `arg:"--threshold" default:"90" help:"Alert when a key has used this percentage of its data limit"`
+	Interval   time.Duration `arg:"--interval" default:"1m" help:"Time between polls"`
+}
+
+type PruneKeysCmd struct {
+	ServerName string `arg:"positional,required" help:"Server name"`
+	Unused     bool   `arg:"--unused" help:"Select keys with zero transfer according to server metrics"`
+	OlderThan  Age    `arg:"--older-than" help:"Select keys first seen at least this long ago according to the key cache (e.g. '90d')"`
+}
+
+type BulkRenameKeysCmd struct {
+	ServerName   string       `arg:"positional,required" help:"Server name"`
+	NamePrefix   string       `arg:"--name-prefix,required" help:"Rename the keys whose name starts with this prefix"`
+	NameTemplate NameTemplate `arg:"--name-template,required" help:"Go template for the new name with the key as data, e.g. '2024-{{.Name}}' or '{{.Name | trimPrefix \"old-\"}}'"`
+}
+
+type GetKeyCmd struct {
+	ServerName    string `arg:"positional,required" help:"Server name"`
+	KeyID         string `arg:"-k,--key-id" help:"Access key ID"`
+	KeyName       string `arg:"-n,--key-name" help:"Access key name"`
+	AccessURLOnly bool   `arg:"--access-url-only" help:"Print only the access URL"`
+}
+
+type DescribeKeyCmd struct {
+	ServerName string `arg:"positional,required" help:"Server name"`
+	KeyID      string `arg:"-k,--key-id" help:"Access key ID"`
+	KeyName    string `arg:"-n,--key-name" help:"Access key name"`
+}
+
+type ResolveKeyCmd struct {
+	ServerName string `arg:"positional,required" help:"Server name"`
+	KeyID      string `arg:"-k,--key-id" help:"Access key ID"`
+	KeyName    string `arg:"-n,--key-name" help:"Access key name"`
 }
 
 type DeleteKeyCmd struct {
@@ -103,87 +310,536 @@ type EditKeyCmd struct {
 	KeyID       string   `arg:"-k,--key-id" help:"Access key ID (use this to edit by ID)"`
 	KeyName     string   `arg:"-n,--key-name" help:"Access key name (use this to edit by name)"`
 	NewName     string   `arg:"--new-name" help:"New name for the access key"`
-	DataLimit   DataSize `arg:"-l,--data-limit" help:"New data limit (e.g., '1GB', '500MB', '2TB')"`
+	DataLimit   DataSize `arg:"-l,--data-limit" help:"New data limit (e.g., '1GB', '500MB', '2TB', or '50%' of the server default)"`
 	RemoveLimit bool     `arg:"--remove-limit" help:"Remove data limit from the key"`
+	Cap         DataSize `arg:"--cap" help:"Lower the data limit to this ceiling, leaving keys already at or below it untouched"`
+	NamePrefix  string   `arg:"--name-prefix" help:"With --cap, apply to every key whose name starts with this prefix"`
+	CheckUsage  bool     `arg:"--check-usage" help:"With --data-limit, ask before setting a limit the key has already used up"`
 }
 
-type MetricsCmd struct {
+type SetLimitKeyCmd struct {
+	ServerName string   `arg:"positional,required" help:"Server name"`
+	KeyID      string   `arg:"-k,--key-id" help:"Access key ID"`
+	KeyName    string   `arg:"-n,--key-name" help:"Access key name"`
+	DataLimit  DataSize `arg:"-l,--data-limit,required" help:"Data limit (e.g., '1GB', '500MB', '2TB', or '50%' of the server default); '0' blocks the key"`
+	CheckUsage bool     `arg:"--check-usage" help:"Ask before setting a limit the key has already used up"`
+}
+
+type RemoveLimitKeyCmd struct {
 	ServerName string `arg:"positional,required" help:"Server name"`
+	KeyID      string `arg:"-k,--key-id" help:"Access key ID"`
+	KeyName    string `arg:"-n,--key-name" help:"Access key name"`
+}
+
+type CacheSyncKeyCmd struct {
+	ServerName string `arg:"positional,required" help:"Server name"`
+}
+
+type MoveKeyCmd struct {
+	Source   string `arg:"positional,required" help:"Server whose key cache has the key"`
+	Target   string `arg:"positional,required" help:"Server the key was migrated to"`
+	KeyID    string `arg:"-k,--key-id" help:"Access key ID on the source server"`
+	KeyName  string `arg:"-n,--key-name" help:"Access key name on the source server"`
+	TargetID string `arg:"--target-id" help:"ID of the key on the target server, if it differs"`
+}
+
+// FanOutArgs are shared by commands that can run against every configured server
+type FanOutArgs struct {
+	All             bool   `arg:"--all" help:"Run against all configured servers"`
+	Group           string `arg:"--group" help:"Run against the servers of a config group"`
+	FailFast        bool   `arg:"--fail-fast" help:"With --all or --group, abort on the first server that fails"`
+	SkipUnreachable bool   `arg:"--skip-unreachable" help:"With --all or --group, leave out servers that do not accept a connection within the dial timeout"`
+}
+
+func (f FanOutArgs) selection(serverName string) config.ServerSelection {
+	return config.ServerSelection{Name: serverName, All: f.All, Group: f.Group, FailFast: f.FailFast, SkipUnreachable: f.SkipUnreachable}
+}
+
+type MetricsCmd struct {
+	ServerName string `arg:"positional" help:"Server name or glob pattern (e.g. 'prod-*')"`
+	FanOutArgs
+	Top       PositiveInt `arg:"--top" help:"Show the N users with the most transfer by name and sum the rest into one line"`
+	Aggregate bool        `arg:"--aggregate" help:"Print the total transfer of each server and their sum instead of per-user metrics"`
+}
+
+type HealthCmd struct {
+	ServerName string `arg:"positional" help:"Server name or glob pattern (e.g. 'prod-*')"`
+	FanOutArgs
+	CheckDataPort bool `arg:"--check-data-port" help:"Also check that the port new access keys connect to accepts connections"`
+}
+
+type ReachableCmd struct {
+	ServerName string `arg:"positional" help:"Server name or glob pattern (e.g. 'prod-*')"`
+	FanOutArgs
+	Verbose bool `arg:"--verbose" help:"Print whether each server is reachable"`
 }
 
 func main() {
 	var args Args
 	parser := arg.MustParse(&args)
 
-	config.InitLogger(args.Verbosity)
+	verbosity := args.Verbosity
+	if args.Servers != nil && args.Servers.Reachable != nil && !args.Servers.Reachable.Verbose && !flagGiven(os.Args[1:], "-v", "--verbosity") {
+		// The exit code is the whole output of the probe
+		verbosity = "off"
+	}
+	config.InitLogger(verbosity)
 
 	if err := validateArgs(&args); err != nil {
 		parser.Fail(err.Error())
 	}
+	// Compiled before the config is loaded, so a broken template fails without contacting a server
+	outputTemplate, err := loadTemplate(args.Template, args.TemplateFile)
+	if err != nil {
+		parser.Fail(err.Error())
+	}
 
-	configManager, err := config.NewConfigManager()
+	if args.SkipCertCheck {
+		// Printed regardless of --verbosity and --quiet so that it cannot go unnoticed
+		fmt.Fprintln(os.Stderr, "WARNING: --skip-cert-check disables certificate pinning for this run. The server is not authenticated, so the connection and its secret URL could be intercepted.")
+	}
+
+	configManager, err := config.NewConfigManager(config.Options{
+		Client: api.ClientOptions{
+			Timeout:       args.Timeout,
+			DialTimeout:   args.DialTimeout,
+			MaxConns:      args.MaxConns.Number,
+			StrictJSON:    args.StrictJSON,
+			UserAgent:     userAgent(args.UserAgent),
+			SkipCertCheck: args.SkipCertCheck,
+			Retries:       args.Retries,
+			MaxRetryWait:  args.MaxRetryWait,
+			RetryOn:       args.RetryOn.Conditions(),
+			RetryBudget:   args.RetryBudget,
+			Resolve:       hostOverrides(args.Resolve),
+		},
+		Strict:       args.Strict,
+		Profile:      args.Profile.Name,
+		NoInput:      args.NoInput,
+		AssumeYes:    args.Yes,
+		Progress:     newProgress(args.Quiet),
+		BackupCount:  args.Backups,
+		BackupDir:    args.BackupDir,
+		MaxDataLimit: args.MaxDataLimit.Bytes,
+		Units:        args.Units.System,
+	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing config: %v\n", err)
 		os.Exit(1)
 	}
 
+	p := &printer{format: args.Output.Format, units: args.Units.System, quiet: args.Quiet, jsonArray: args.JSONArray, template: outputTemplate,
+		separator: itemSeparator(args.Separator, args.NoSeparator)}
+
 	switch {
 	case args.Version != nil:
-		fmt.Printf("outline-cli version %s\n", Version)
+		err = printVersion(args.Version.JSON || p.json())
 	case args.Servers != nil:
-		if err := handleServersCommand(args.Servers, configManager); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
+		err = handleServersCommand(args.Servers, p, configManager)
 	case args.Keys != nil:
-		if err := handleKeysCommand(args.Keys, configManager); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
+		err = handleKeysCommand(args.Keys, p, configManager)
 	case args.PrintConfig != nil:
-		if err := configManager.PrintConfig(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		var data []byte
+		if data, err = configManager.MarshalConfig(); err == nil {
+			fmt.Println(string(data))
+		}
+	case args.Config != nil:
+		err = handleConfigCommand(args.Config, &args, p, configManager)
+	case args.ParseURL != nil:
+		var parsed *api.ShadowsocksConfig
+		if parsed, err = api.ParseAccessURL(args.ParseURL.URL); err == nil {
+			err = p.printShadowsocksConfig(parsed)
 		}
 	default:
 		parser.WriteHelp(os.Stdout)
 	}
+
+	if errors.Is(err, errEmptyResult) {
+		os.Exit(exitEmpty)
+	}
+	if errors.Is(err, errUnreachable) {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", configManager.ExplainError(err))
+		os.Exit(1)
+	}
 }
 
-func handleServersCommand(cmd *ServersCmd, configManager *config.ConfigManager) error {
+func handleServersCommand(cmd *ServersCmd, p *printer, configManager *config.ConfigManager) error {
 	switch {
 	case cmd.List != nil:
-		return configManager.ListServers()
+		statuses := configManager.ListServers(cmd.List.Probe)
+		if err := p.printServers(statuses, configManager, cmd.List.ShowSecrets); err != nil {
+			return err
+		}
+		return checkEmpty(len(statuses), cmd.List.FailOnEmpty)
 	case cmd.Add != nil:
 		return configManager.AddServer(cmd.Add.Name, cmd.Add.URL.URL, cmd.Add.CertSha256.Hash)
 	case cmd.AddJSON != nil:
 		return configManager.AddServerFromJSON(cmd.AddJSON.Name, cmd.AddJSON.JSON)
+	case cmd.Import != nil:
+		results, err := configManager.ImportServers(cmd.Import.File, config.ImportOptions{
+			Verify:   cmd.Import.Verify,
+			Parallel: cmd.Import.Parallel.Number,
+		})
+		if results != nil {
+			if printErr := p.printImportResults(results); printErr != nil {
+				return printErr
+			}
+		}
+		return err
+	case cmd.FetchCerts != nil:
+		results, err := configManager.FetchMissingCerts(config.FetchCertsOptions{Parallel: cmd.FetchCerts.Parallel.Number})
+		if results != nil {
+			if printErr := p.printFetchCertResults(results); printErr != nil {
+				return printErr
+			}
+		}
+		return err
 	case cmd.Get != nil:
-		return configManager.GetServer(cmd.Get.Name)
+		details, err := configManager.GetServer(cmd.Get.Name, cmd.Get.WithKeys)
+		if err != nil {
+			return err
+		}
+		return p.printServerDetails(cmd.Get.Name, details, cmd.Get.ShowSecrets, cmd.Get.FlattenLimit)
 	case cmd.Update != nil:
-		return configManager.UpdateServer(cmd.Update.Name, cmd.Update.URL.URL)
+		return configManager.UpdateServer(cmd.Update.Name, config.ServerUpdate{
+			URL:            cmd.Update.URL.URL,
+			CertSha256:     cmd.Update.CertSha256.Hash,
+			NewName:        cmd.Update.NewName,
+			LocalOnly:      cmd.Update.LocalOnly,
+			EndpointPrefix: cmd.Update.EndpointPrefix,
+			SNI:            cmd.Update.SNI,
+		})
 	case cmd.Delete != nil:
 		return configManager.DeleteServer(cmd.Delete.Name)
+	case cmd.Raw != nil:
+		body, err := configManager.GetServerRaw(cmd.Raw.Name, cmd.Raw.Endpoint.Path)
+		if err != nil {
+			return err
+		}
+		return printRaw(body)
 	case cmd.Metrics != nil:
-		return configManager.GetMetrics(cmd.Metrics.ServerName)
+		sel := cmd.Metrics.selection(cmd.Metrics.ServerName)
+		if cmd.Metrics.Aggregate {
+			result, err := configManager.GetMetricsReports(sel)
+			if err != nil {
+				return err
+			}
+			return p.printFleetMetrics(result)
+		}
+		if p.json() || cmd.Metrics.Top.Number > 0 {
+			result, err := configManager.GetMetricsReports(sel)
+			if err != nil {
+				return err
+			}
+			for _, server := range result.Results {
+				if server.Err == nil {
+					server.Value.Top(cmd.Metrics.Top.Number)
+				}
+			}
+			if !p.json() {
+				return p.printTopMetrics(result)
+			}
+			return printMetricsReports(result, sel.Multiple())
+		}
+		result, err := configManager.GetMetrics(sel)
+		if err != nil {
+			return err
+		}
+		return p.printMetrics(result)
+	case cmd.Health != nil:
+		result, err := configManager.CheckHealth(cmd.Health.selection(cmd.Health.ServerName), config.HealthOptions{
+			CheckDataPort: cmd.Health.CheckDataPort,
+		})
+		if err != nil {
+			return err
+		}
+		return printHealth(result)
+	case cmd.Reachable != nil:
+		result, err := configManager.CheckHealth(cmd.Reachable.selection(cmd.Reachable.ServerName), config.HealthOptions{})
+		if err != nil {
+			return err
+		}
+		return printReachable(result, cmd.Reachable.Verbose)
+	case cmd.Test != nil:
+		report, err := configManager.TestServer(cmd.Test.Name)
+		if err != nil {
+			return err
+		}
+		return p.printChecks(report)
+	case cmd.Diff != nil:
+		diff, err := configManager.DiffServerKeys(cmd.Diff.Source, cmd.Diff.Target)
+		if err != nil {
+			return err
+		}
+		return p.printKeyDiff(diff)
 	default:
 		return fmt.Errorf("no subcommand specified")
 	}
 }
 
-func handleKeysCommand(cmd *KeysCmd, configManager *config.ConfigManager) error {
+func handleConfigCommand(cmd *ConfigCmd, args *Args, p *printer, configManager *config.ConfigManager) error {
+	switch {
+	case cmd.Profiles != nil:
+		profiles, err := configManager.ListProfiles()
+		if err != nil {
+			return err
+		}
+		return p.printProfiles(profiles)
+	case cmd.FixPerms != nil:
+		fix, err := configManager.FixConfigPermissions()
+		if err != nil {
+			return err
+		}
+		p.printPermissionsFix(fix)
+		return nil
+	case cmd.Edit != nil:
+		edit, err := configManager.EditConfig(runEditor)
+		if err != nil {
+			return err
+		}
+		p.printConfigEdit(edit)
+		return nil
+	case cmd.Group != nil:
+		return handleGroupCommand(cmd.Group, p, configManager)
+	case cmd.Restore != nil && cmd.Restore.Backup == "":
+		backups, err := configManager.ListConfigBackups()
+		if err != nil {
+			return err
+		}
+		return p.printConfigBackups(backups)
+	case cmd.Restore != nil:
+		backup, err := configManager.RestoreConfigBackup(cmd.Restore.Backup)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored the config from %s\n", backup.Name)
+		return nil
+	case cmd.Schema != nil:
+		fmt.Print(string(config.ConfigSchema()))
+		return nil
+	case cmd.Effective != nil:
+		return p.printEffectiveSettings(effectiveSettings(args, os.Args[1:], configManager.ConfigPath(), configManager.BackupDir()))
+	default:
+		return fmt.Errorf("no config subcommand specified")
+	}
+}
+
+func handleGroupCommand(cmd *GroupCmd, p *printer, configManager *config.ConfigManager) error {
+	switch {
+	case cmd.Add != nil:
+		return configManager.AddGroup(cmd.Add.Group, cmd.Add.Servers)
+	case cmd.Remove != nil:
+		return configManager.RemoveGroup(cmd.Remove.Group)
+	case cmd.List != nil:
+		p.printGroups(configManager.ListGroups())
+		return nil
+	default:
+		return fmt.Errorf("no group subcommand specified")
+	}
+}
+
+func handleKeysCommand(cmd *KeysCmd, p *printer, configManager *config.ConfigManager) error {
 	switch {
 	case cmd.List != nil:
-		return configManager.ListAccessKeys(cmd.List.ServerName)
+		entries, err := configManager.ListAccessKeys(cmd.List.ServerName, config.KeyListOptions{
+			Unused:          cmd.List.Unused,
+			UnusedThreshold: cmd.List.UnusedThreshold.Bytes,
+			CheckPorts:      cmd.List.CheckPorts,
+			WithURLsOnly:    cmd.List.WithURLsOnly,
+			// The CSV output has a usage column
+			WithUsage: p.format == "csv",
+		})
+		if err != nil {
+			return err
+		}
+		switch {
+		case cmd.List.IDOnly:
+			printKeyField(entries, func(entry config.KeyEntry) string { return entry.ID })
+		case cmd.List.NameOnly:
+			printKeyField(entries, func(entry config.KeyEntry) string { return entry.Name })
+		case cmd.List.FlattenLimit:
+			if err := p.printStructured(flattenKeyLimits(entries)); err != nil {
+				return err
+			}
+		default:
+			if err := p.printKeys(cmd.List.ServerName, entries, cmd.List.Unused); err != nil {
+				return err
+			}
+		}
+		return checkEmpty(len(entries), cmd.List.FailOnEmpty)
 	case cmd.Create != nil:
-		return configManager.CreateAccessKey(cmd.Create.ServerName, cmd.Create.Name, cmd.Create.Method.Method, cmd.Create.Port.Number, cmd.Create.DataLimit.String())
+		result, err := configManager.CreateAccessKey(cmd.Create.ServerName, config.CreateKeyOptions{
+			Name:             cmd.Create.Name,
+			Method:           createMethod(cmd.Create),
+			MethodFromServer: cmd.Create.MethodFromServer,
+			UniqueName:       cmd.Create.UniqueName,
+			Port:             cmd.Create.Port.Number,
+			DataLimit:        cmd.Create.DataLimit.Limit(),
+			DataLimitPercent: cmd.Create.DataLimit.Percent,
+			Reconcile:        cmd.Create.Reconcile,
+			Rollback:         cmd.Create.Rollback,
+			Count:            cmd.Create.Count,
+			NamesFile:        cmd.Create.NamesFromFile,
+			Concurrency:      cmd.Create.Concurrency.Number,
+		})
+		if result != nil {
+			if printErr := p.printCreatedKeys(result, createdKeysOutput{
+				batch:         cmd.Create.BatchOutput,
+				file:          cmd.Create.OutputFile,
+				accessURLOnly: cmd.Create.AccessURLOnly,
+				idOnly:        cmd.Create.IDOnly,
+				inviteLink:    cmd.Create.InviteLink,
+			}); printErr != nil {
+				return printErr
+			}
+		}
+		return err
+	case cmd.Import != nil:
+		result, err := configManager.ImportAccessKeys(cmd.Import.ServerName, cmd.Import.File, config.ImportKeyOptions{
+			IfNotExists: cmd.Import.IfNotExists,
+			Concurrency: cmd.Import.Concurrency.Number,
+			StripLimit:  cmd.Import.StripLimit,
+		})
+		if result != nil {
+			if printErr := p.printKeyImport(result); printErr != nil {
+				return printErr
+			}
+		}
+		return err
+	case cmd.Usage != nil:
+		report, err := configManager.GetUsageReport(cmd.Usage.ServerName)
+		if err != nil {
+			return err
+		}
+		return p.printUsageReport(report)
+	case cmd.Watch != nil:
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		p.hint("Watching the keys of '%s' for %g%% of their data limit every %s. Press Ctrl+C to stop.", cmd.Watch.ServerName, cmd.Watch.Threshold, cmd.Watch.Interval)
+		return configManager.WatchLimits(ctx, cmd.Watch.ServerName, config.WatchLimitsOptions{
+			Threshold: cmd.Watch.Threshold,
+			Interval:  cmd.Watch.Interval,
+		}, p.printLimitAlert)
+	case cmd.Get != nil:
+		accessKey, err := configManager.GetAccessKey(cmd.Get.ServerName, cmd.Get.KeyID, cmd.Get.KeyName)
+		if err != nil {
+			return err
+		}
+		switch {
+		case cmd.Get.AccessURLOnly:
+			fmt.Println(accessKey.AccessURL)
+		case p.structured():
+			return p.printItem(accessKey)
+		default:
+			p.printAccessKey(accessKey)
+		}
+		return nil
+	case cmd.Describe != nil:
+		description, err := configManager.DescribeAccessKey(cmd.Describe.ServerName, cmd.Describe.KeyID, cmd.Describe.KeyName)
+		if err != nil {
+			return err
+		}
+		return p.printKeyDescription(description)
+	case cmd.Resolve != nil:
+		accessKey, err := configManager.GetAccessKey(cmd.Resolve.ServerName, cmd.Resolve.KeyID, cmd.Resolve.KeyName)
+		if err != nil {
+			return err
+		}
+		return p.printResolvedKey(accessKey)
 	case cmd.Delete != nil:
 		if cmd.Delete.KeyName != "" {
 			return configManager.DeleteAccessKeyByName(cmd.Delete.ServerName, cmd.Delete.KeyName)
 		}
 		return configManager.DeleteAccessKey(cmd.Delete.ServerName, cmd.Delete.KeyID)
+	case cmd.Prune != nil:
+		report, err := configManager.PruneAccessKeys(cmd.Prune.ServerName, config.PruneKeyOptions{
+			Unused:    cmd.Prune.Unused,
+			OlderThan: cmd.Prune.OlderThan.Duration,
+		})
+		if report != nil {
+			if printErr := p.printPruneReport(report); printErr != nil {
+				return printErr
+			}
+		}
+		return err
+	case cmd.BulkRename != nil:
+		report, err := configManager.BulkRenameAccessKeys(cmd.BulkRename.ServerName, config.BulkRenameOptions{
+			NamePrefix:   cmd.BulkRename.NamePrefix,
+			NameTemplate: cmd.BulkRename.NameTemplate.Template,
+		})
+		if report != nil {
+			if printErr := p.printRenameReport(report); printErr != nil {
+				return printErr
+			}
+		}
+		return err
+	case cmd.Edit != nil && cmd.Edit.Cap.String() != "":
+		results, err := configManager.CapAccessKeys(cmd.Edit.ServerName, config.CapKeyOptions{
+			KeyID:      cmd.Edit.KeyID,
+			KeyName:    cmd.Edit.KeyName,
+			NamePrefix: cmd.Edit.NamePrefix,
+			Cap:        cmd.Edit.Cap.Bytes,
+			CapPercent: cmd.Edit.Cap.Percent,
+		})
+		p.printCapResults(results)
+		return err
 	case cmd.Edit != nil:
-		return configManager.EditAccessKey(cmd.Edit.ServerName, cmd.Edit.KeyID, cmd.Edit.KeyName, cmd.Edit.NewName, cmd.Edit.DataLimit.String(), cmd.Edit.RemoveLimit)
+		result, err := configManager.EditAccessKey(cmd.Edit.ServerName, config.EditKeyOptions{
+			KeyID:            cmd.Edit.KeyID,
+			KeyName:          cmd.Edit.KeyName,
+			NewName:          cmd.Edit.NewName,
+			DataLimit:        cmd.Edit.DataLimit.Limit(),
+			DataLimitPercent: cmd.Edit.DataLimit.Percent,
+			RemoveLimit:      cmd.Edit.RemoveLimit,
+			CheckUsage:       cmd.Edit.CheckUsage,
+		})
+		if result != nil {
+			p.printEditResult(result)
+		}
+		return err
+	case cmd.SetLimit != nil:
+		result, err := configManager.EditAccessKey(cmd.SetLimit.ServerName, config.EditKeyOptions{
+			KeyID:            cmd.SetLimit.KeyID,
+			KeyName:          cmd.SetLimit.KeyName,
+			DataLimit:        cmd.SetLimit.DataLimit.Limit(),
+			DataLimitPercent: cmd.SetLimit.DataLimit.Percent,
+			CheckUsage:       cmd.SetLimit.CheckUsage,
+		})
+		if result != nil {
+			p.printEditResult(result)
+		}
+		return err
+	case cmd.RemoveLimit != nil:
+		result, err := configManager.EditAccessKey(cmd.RemoveLimit.ServerName, config.EditKeyOptions{
+			KeyID:       cmd.RemoveLimit.KeyID,
+			KeyName:     cmd.RemoveLimit.KeyName,
+			RemoveLimit: true,
+		})
+		if result != nil {
+			p.printEditResult(result)
+		}
+		return err
+	case cmd.CacheSync != nil:
+		result, err := configManager.SyncKeyCache(cmd.CacheSync.ServerName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Synced key cache for '%s': %d added, %d removed, %d kept\n", cmd.CacheSync.ServerName, result.Added, result.Removed, result.Kept)
+		return nil
+	case cmd.Move != nil:
+		moved, err := configManager.MoveCachedKey(cmd.Move.Source, cmd.Move.Target, config.MoveKeyOptions{
+			KeyID:    cmd.Move.KeyID,
+			KeyName:  cmd.Move.KeyName,
+			TargetID: cmd.Move.TargetID,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Moved cached %s from '%s' to '%s'\n", describeKey(moved.AccessKey), cmd.Move.Source, cmd.Move.Target)
+		return nil
 	default:
 		return fmt.Errorf("no keys subcommand specified")
 	}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/art-shutter/outline-cli/internal/config"
+)
+
+// exitEmpty is the exit code of a list with --fail-on-empty that found nothing, so scripts can
+// tell it apart from a failure
+const exitEmpty = 3
+
+// errEmptyResult ends the command with exitEmpty after the normal empty output was printed
+var errEmptyResult = errors.New("empty result")
+
+// errUnreachable ends `servers reachable` with exit code 1 without printing an error, since the
+// exit code is its whole output
+var errUnreachable = errors.New("unreachable")
+
+// checkEmpty returns errEmptyResult for an empty result when failOnEmpty is set
+func checkEmpty(count int, failOnEmpty bool) error {
+	if failOnEmpty && count == 0 {
+		return errEmptyResult
+	}
+	return nil
+}
+
+// printer renders command results on stdout in the format selected by the global flags
+type printer struct {
+	// format is the --output format: text, json, yaml or csv
+	format string
+	// units selects SI or IEC units for human-readable sizes
+	units string
+	// quiet suppresses informational messages such as hints on empty results
+	quiet bool
+	// jsonArray prints the single result of a get command as a one-element JSON array
+	jsonArray bool
+	// template renders results instead of text output when --template or --template-file is given
+	template *template.Template
+	// separator is the line printed between the items of text output; empty prints none
+	separator string
+}
+
+// defaultSeparator is printed between the items of text output unless --separator or
+// --no-separator is given
+const defaultSeparator = "---"
+
+// itemSeparator is the separator selected by --separator and --no-separator
+func itemSeparator(separator *string, none bool) string {
+	switch {
+	case none:
+		return ""
+	case separator != nil:
+		return *separator
+	}
+	return defaultSeparator
+}
+
+func (p *printer) json() bool {
+	return p.format == "json"
+}
+
+func (p *printer) bytes(bytes int64) string {
+	return config.FormatBytes(bytes, p.units)
+}
+
+// hint prints an informational message for a person at the terminal unless quiet is set
+func (p *printer) hint(format string, args ...any) {
+	if p.quiet {
+		return
+	}
+	fmt.Printf(format+"\n", args...)
+}
+
+// printSeparator prints the line between two items of text output, if there is one
+func (p *printer) printSeparator() {
+	if p.separator != "" {
+		fmt.Println(p.separator)
+	}
+}
+
+// structured reports whether the output format is JSON or YAML, or the result goes to a template
+func (p *printer) structured() bool {
+	return p.format == "json" || p.format == "yaml" || p.template != nil
+}
+
+// printStructured prints v as YAML or JSON, using its JSON field names in both formats, or
+// renders it with the output template
+func (p *printer) printStructured(v any) error {
+	switch {
+	case p.template != nil:
+		return executeTemplate(os.Stdout, p.template, v)
+	case p.format == "yaml":
+		return printYAML(v)
+	}
+	return printJSON(v)
+}
+
+// printItem prints the single result of a get command like printStructured, wrapped in an array
+// when jsonArray is set so that its shape matches the list commands
+func (p *printer) printItem(v any) error {
+	if p.jsonArray && p.json() {
+		return printJSON([]any{v})
+	}
+	return p.printStructured(v)
+}
+
+// loadTemplate compiles the --template text or the contents of --template-file; nil when
+// neither is given
+func loadTemplate(text, file string) (*template.Template, error) {
+	name := "--template"
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read --template-file: %w", err)
+		}
+		text, name = string(data), file
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// executeTemplate renders each element of a list, or v itself, on a line of its own. The
+// template sees the Go fields of the result, e.g. {{.ID}} and {{.AccessURL}} for a key.
+func executeTemplate(w io.Writer, tmpl *template.Template, v any) error {
+	items := []any{v}
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Slice {
+		items = make([]any, value.Len())
+		for i := range items {
+			items[i] = value.Index(i).Interface()
+		}
+	}
+
+	for _, item := range items {
+		if err := tmpl.Execute(w, item); err != nil {
+			slog.Error("failed to execute output template", "error", err)
+			return err
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printYAML(v any) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		slog.Error("failed to marshal output", "error", err)
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		slog.Error("failed to marshal output", "error", err)
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// newProgress returns a ProgressFunc that keeps a status line such as "Creating keys 7/50..." on
+// stderr and clears it once the operation is over. It returns nil, so no progress is shown, when
+// quiet is set or stderr is not a terminal, so piped and logged output stays clean.
+func newProgress(quiet bool) config.ProgressFunc {
+	if quiet || !stderrIsTerminal() {
+		return nil
+	}
+	return func(task string, done, total int) {
+		if done == total {
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\r%s %d/%d...", task, done, total)
+	}
+}
+
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// reportFailures lists the skipped and failed servers of a fan-out run on stderr and returns its error.
+// A single-server run only returns the server's error.
+func reportFailures[T any](result *config.FanOutResult[T]) error {
+	if len(result.Skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped unreachable servers: %s\n", strings.Join(result.Skipped, ", "))
+	}
+
+	failed := result.Failed()
+	if len(failed) > 0 && result.Total > 1 {
+		fmt.Fprintln(os.Stderr, "Failed servers:")
+		for _, failure := range failed {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", failure.Server, failure.Err)
+		}
+	}
+	return result.Err()
+}
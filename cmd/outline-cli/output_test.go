@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexflint/go-arg"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestLoadTemplate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.tmpl")
+	if err := os.WriteFile(file, []byte("{{.ID}}: {{.Name}}"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		file    string
+		wantNil bool
+		wantErr bool
+	}{
+		{"none", "", "", true, false},
+		{"inline", "{{.ID}}", "", false, false},
+		{"file", "", file, false, false},
+		{"missing file", "", filepath.Join(t.TempDir(), "missing.tmpl"), false, true},
+		{"syntax error", "{{.ID", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := loadTemplate(tt.text, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (tmpl == nil) != tt.wantNil {
+				t.Errorf("loadTemplate() = %v, want nil = %v", tmpl, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestExecuteTemplate(t *testing.T) {
+	tmpl, err := loadTemplate("{{.ID}}: {{.Name}}", "")
+	if err != nil {
+		t.Fatalf("loadTemplate failed: %v", err)
+	}
+
+	var list strings.Builder
+	keys := []api.AccessKey{{ID: "1", Name: "alice"}, {ID: "2", Name: "bob"}}
+	if err := executeTemplate(&list, tmpl, keys); err != nil {
+		t.Fatalf("executeTemplate failed: %v", err)
+	}
+	if want := "1: alice\n2: bob\n"; list.String() != want {
+		t.Errorf("Expected one line per list element %q, got %q", want, list.String())
+	}
+
+	var item strings.Builder
+	if err := executeTemplate(&item, tmpl, &keys[0]); err != nil {
+		t.Fatalf("executeTemplate failed: %v", err)
+	}
+	if want := "1: alice\n"; item.String() != want {
+		t.Errorf("Expected %q for a single item, got %q", want, item.String())
+	}
+
+	unknown, _ := loadTemplate("{{.Unknown}}", "")
+	if err := executeTemplate(&item, unknown, &keys[0]); err == nil {
+		t.Error("Expected an error for a field the result does not have")
+	}
+}
+
+func TestItemSeparator(t *testing.T) {
+	tests := []struct {
+		name string
+		argv []string
+		want string
+	}{
+		{"default", []string{"keys", "list", "test"}, "---"},
+		{"custom", []string{"--separator", "===", "keys", "list", "test"}, "==="},
+		{"none", []string{"--no-separator", "keys", "list", "test"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args Args
+			parser, err := arg.NewParser(arg.Config{}, &args)
+			if err != nil {
+				t.Fatalf("NewParser failed: %v", err)
+			}
+			if err := parser.Parse(tt.argv); err != nil {
+				t.Fatalf("Parse(%v) failed: %v", tt.argv, err)
+			}
+			if got := itemSeparator(args.Separator, args.NoSeparator); got != tt.want {
+				t.Errorf("itemSeparator() for %v = %q, want %q", tt.argv, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+	"github.com/art-shutter/outline-cli/internal/config"
+)
+
+// printServers lists the servers. Structured output has the secret path of each URL redacted
+// unless showSecrets is set.
+func (p *printer) printServers(statuses []config.ServerStatus, configManager *config.ConfigManager, showSecrets bool) error {
+	if p.structured() {
+		if showSecrets {
+			for i := range statuses {
+				server, err := configManager.Server(statuses[i].Name)
+				if err != nil {
+					return err
+				}
+				statuses[i].URL = server.URL
+			}
+		}
+		return p.printStructured(statuses)
+	}
+
+	if len(statuses) == 0 {
+		p.hint("No servers configured. Add one with: outline-cli servers add <name> <url> --cert-sha256 <sha256>")
+		return nil
+	}
+
+	fmt.Println("Configured servers:")
+	fmt.Println("===================")
+	for _, status := range statuses {
+		server, err := configManager.Server(status.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Name: %s\n", status.Name)
+		fmt.Printf("URL:  %s\n", server.URL)
+		fmt.Printf("Cert: %s\n", server.CertSha256)
+		if status.Reachable != nil {
+			if *status.Reachable {
+				fmt.Printf("Status: reachable (version %s", status.Version)
+				if status.KeyCount != nil {
+					fmt.Printf(", %d keys", *status.KeyCount)
+				}
+				fmt.Println(")")
+			} else {
+				fmt.Printf("Status: unreachable (%s)\n", status.Error)
+			}
+		}
+		p.printSeparator()
+	}
+	return nil
+}
+
+// serverDetailsOutput is the JSON and YAML form of `servers get`: the server's config merged with
+// what its API reports, or with the reason the API could not be reached
+type serverDetailsOutput struct {
+	Name           string   `json:"name"`
+	URL            string   `json:"url"`
+	CertSha256     string   `json:"certSha256,omitempty"`
+	EndpointPrefix string   `json:"endpointPrefix,omitempty"`
+	SNI            string   `json:"sni,omitempty"`
+	Groups         []string `json:"groups"`
+	Reachable      bool     `json:"reachable"`
+	Error          string   `json:"error,omitempty"`
+	Info           any      `json:"info,omitempty"`
+	KeyCount       *int     `json:"keyCount,omitempty"`
+}
+
+// flatServerInfo is api.OutlineServer with the default key data limit as a plain number, for
+// consumers that cannot handle the nested object; AccessKeyDataLimitBytes is null without a limit
+type flatServerInfo struct {
+	Name                    string `json:"name"`
+	ServerID                string `json:"serverId"`
+	MetricsEnabled          bool   `json:"metricsEnabled"`
+	CreatedTimestampMs      int64  `json:"createdTimestampMs"`
+	Version                 string `json:"version"`
+	PortForNewAccessKeys    int    `json:"portForNewAccessKeys"`
+	HostnameForAccessKeys   string `json:"hostnameForAccessKeys"`
+	AccessKeyDataLimitBytes *int64 `json:"accessKeyDataLimitBytes"`
+}
+
+func flattenServerLimit(info *api.OutlineServer) *flatServerInfo {
+	flat := &flatServerInfo{
+		Name:                  info.Name,
+		ServerID:              info.ServerID,
+		MetricsEnabled:        info.MetricsEnabled,
+		CreatedTimestampMs:    info.CreatedTimestampMs,
+		Version:               info.Version,
+		PortForNewAccessKeys:  info.PortForNewAccessKeys,
+		HostnameForAccessKeys: info.HostnameForAccessKeys,
+	}
+	if info.AccessKeyDataLimit != nil {
+		flat.AccessKeyDataLimitBytes = &info.AccessKeyDataLimit.Bytes
+	}
+	return flat
+}
+
+func (p *printer) printServerDetails(name string, details *config.ServerDetails, showSecrets, flattenLimit bool) error {
+	if p.structured() {
+		output := serverDetailsOutput{
+			Name:           name,
+			URL:            config.RedactURL(details.Server.URL),
+			CertSha256:     details.Server.CertSha256,
+			EndpointPrefix: details.Server.EndpointPrefix,
+			SNI:            details.Server.SNI,
+			Groups:         details.Groups,
+			Reachable:      details.Info != nil,
+			KeyCount:       details.KeyCount,
+		}
+		switch {
+		case details.Info == nil:
+			// Left out rather than a typed nil, which would print as null
+		case flattenLimit:
+			output.Info = flattenServerLimit(details.Info)
+		default:
+			output.Info = details.Info
+		}
+		if details.Err != nil {
+			output.Error = details.Err.Error()
+		}
+		if showSecrets {
+			output.URL = details.Server.URL
+		}
+		return p.printItem(output)
+	}
+
+	fmt.Printf("Server: %s\n", name)
+	fmt.Printf("URL:   %s\n", details.Server.URL)
+	if details.Server.CertSha256 != "" {
+		fmt.Printf("Cert:  %s\n", details.Server.CertSha256)
+	}
+
+	if len(details.Groups) > 0 {
+		fmt.Printf("Groups: %s\n", strings.Join(details.Groups, ", "))
+	}
+
+	serverInfo := details.Info
+	if serverInfo == nil {
+		fmt.Printf("Status: unreachable (%v)\n", details.Err)
+		return nil
+	}
+	fmt.Printf("API Info:\n")
+	fmt.Printf("  Name:                    %s\n", serverInfo.Name)
+	fmt.Printf("  Server ID:               %s\n", serverInfo.ServerID)
+	fmt.Printf("  Version:                 %s\n", serverInfo.Version)
+	fmt.Printf("  Metrics Enabled:         %t\n", serverInfo.MetricsEnabled)
+	fmt.Printf("  Port for New Keys:       %d\n", serverInfo.PortForNewAccessKeys)
+	fmt.Printf("  Hostname for Keys:       %s\n", serverInfo.HostnameForAccessKeys)
+	if serverInfo.AccessKeyDataLimit != nil {
+		fmt.Printf("  Access Key Data Limit:   %s\n", p.bytes(serverInfo.AccessKeyDataLimit.Bytes))
+	}
+	if details.KeyCount != nil {
+		fmt.Printf("  Access Keys:             %d\n", *details.KeyCount)
+	}
+	return nil
+}
+
+func printRaw(body []byte) error {
+	if _, err := os.Stdout.Write(body); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+// printMetricsReports prints a single report for a single server, or a list when the selection
+// can match several servers
+func printMetricsReports(result *config.FanOutResult[*config.MetricsReport], multiple bool) error {
+	reports := make([]*config.MetricsReport, 0, len(result.Results))
+	for _, server := range result.Results {
+		if server.Err == nil {
+			reports = append(reports, server.Value)
+		}
+	}
+
+	if multiple {
+		if err := printJSON(reports); err != nil {
+			return err
+		}
+	} else if len(reports) == 1 {
+		if err := printJSON(reports[0]); err != nil {
+			return err
+		}
+	}
+	return reportFailures(result)
+}
+
+func (p *printer) printMetrics(result *config.FanOutResult[*config.ServerMetrics]) error {
+	for _, server := range result.Results {
+		if server.Err != nil {
+			continue
+		}
+		fmt.Printf("Transfer metrics for server '%s':\n", server.Server)
+		fmt.Println("==================================")
+		fmt.Println(config.MetricsWindowNote(server.Value.CreatedAt, time.Now()))
+		for _, user := range config.RankUsers(server.Value.Transfer.BytesTransferredByUserId, nil) {
+			fmt.Printf("User %s: %s\n", user.ID, p.bytes(user.Bytes))
+		}
+	}
+	return reportFailures(result)
+}
+
+// printTopMetrics prints the top users of each server by name, followed by the sum of the others
+func (p *printer) printTopMetrics(result *config.FanOutResult[*config.MetricsReport]) error {
+	for _, server := range result.Results {
+		if server.Err != nil {
+			continue
+		}
+		report := server.Value
+		fmt.Printf("Transfer metrics for server '%s':\n", report.Server)
+		fmt.Println("==================================")
+		if !report.MetricsEnabled {
+			fmt.Println("Metrics are disabled on this server.")
+			continue
+		}
+
+		var createdAt time.Time
+		if report.ServerCreatedAt != nil {
+			createdAt = *report.ServerCreatedAt
+		}
+		fmt.Println(config.MetricsWindowNote(createdAt, time.Now()))
+		for _, user := range report.Users {
+			if user.Name != "" {
+				fmt.Printf("%s (%s): %s\n", user.Name, user.ID, p.bytes(user.Bytes))
+			} else {
+				fmt.Printf("User %s: %s\n", user.ID, p.bytes(user.Bytes))
+			}
+		}
+		if report.Others != nil {
+			fmt.Printf("(others): %s (%d users)\n", p.bytes(report.Others.Bytes), report.Others.Count)
+		}
+	}
+	return reportFailures(result)
+}
+
+// printFleetMetrics prints the total transfer of each server and the fleet-wide sum. Servers that
+// failed are left out of the sum and listed at the end.
+func (p *printer) printFleetMetrics(result *config.FanOutResult[*config.MetricsReport]) error {
+	reports := make([]*config.MetricsReport, 0, len(result.Results))
+	for _, server := range result.Results {
+		if server.Err == nil {
+			reports = append(reports, server.Value)
+		}
+	}
+	fleet := config.AggregateMetrics(reports)
+
+	var err error
+	switch p.format {
+	case "json":
+		err = printJSON(fleet)
+	case "csv":
+		err = p.writeFleetMetricsCSV(os.Stdout, fleet)
+	default:
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "SERVER\tUSERS\tUSED")
+		for _, server := range fleet.Servers {
+			used := p.bytes(server.Total)
+			if !server.MetricsEnabled {
+				used = "metrics disabled"
+			}
+			fmt.Fprintf(table, "%s\t%d\t%s\n", server.Server, server.Users, used)
+		}
+		fmt.Fprintf(table, "Total\t\t%s\n", p.bytes(fleet.Total))
+		err = table.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	return reportFailures(result)
+}
+
+// writeFleetMetricsCSV writes a row per server followed by a row for the whole fleet
+func (p *printer) writeFleetMetricsCSV(out io.Writer, fleet *config.FleetMetrics) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"server", "metricsEnabled", "users", "bytes", "used"}); err != nil {
+		return err
+	}
+	for _, server := range fleet.Servers {
+		record := []string{server.Server, strconv.FormatBool(server.MetricsEnabled), strconv.Itoa(server.Users), strconv.FormatInt(server.Total, 10), p.bytes(server.Total)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := writer.Write([]string{"total", "", "", strconv.FormatInt(fleet.Total, 10), p.bytes(fleet.Total)}); err != nil {
+		return err
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		slog.Error("failed to write CSV output", "error", err)
+		return err
+	}
+	return nil
+}
+
+func printHealth(result *config.FanOutResult[config.ServerHealth]) error {
+	closed := 0
+	for _, server := range result.Results {
+		if server.Err != nil {
+			continue
+		}
+		health := server.Value
+		status, details := "ok", fmt.Sprintf("version %s, %s", health.Info.Version, health.Latency.Round(time.Millisecond))
+		switch {
+		case health.DataPort == "":
+		case health.DataPortErr != nil:
+			closed++
+			status = "data port closed"
+			details += fmt.Sprintf(", data port %s closed: %v", health.DataPort, health.DataPortErr)
+		default:
+			details += fmt.Sprintf(", data port %s open", health.DataPort)
+		}
+		fmt.Printf("%s: %s (%s)\n", server.Server, status, details)
+	}
+
+	if err := reportFailures(result); err != nil {
+		return err
+	}
+	if closed > 0 {
+		return fmt.Errorf("the data port of %d of %d servers is closed", closed, len(result.Results))
+	}
+	return nil
+}
+
+// printReachable prints nothing unless verbose, and returns errUnreachable when any selected
+// server did not answer, including servers a fail-fast run skipped
+func printReachable(result *config.FanOutResult[config.ServerHealth], verbose bool) error {
+	if verbose {
+		for _, server := range result.Results {
+			if server.Err != nil {
+				fmt.Printf("%s: unreachable (%v)\n", server.Server, server.Err)
+				continue
+			}
+			fmt.Printf("%s: reachable\n", server.Server)
+		}
+	}
+
+	if len(result.Failed()) > 0 || len(result.Results) < result.Total {
+		return errUnreachable
+	}
+	return nil
+}
+
+// printChecks prints the checks of `servers test` and fails if any of them failed; warnings do
+// not fail the command
+func (p *printer) printChecks(report *config.CheckReport) error {
+	if p.json() {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, check := range report.Checks {
+			status := "ok"
+			switch check.Status {
+			case config.CheckWarn:
+				status = "WARN"
+			case config.CheckFail:
+				status = "FAIL"
+			}
+			fmt.Printf("%-12s %-5s %s\n", check.Name, status, check.Detail)
+		}
+	}
+
+	if failed := report.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(report.Checks))
+	}
+	return nil
+}
+
+func (p *printer) printKeyDiff(diff *config.KeyDiff) error {
+	if p.json() {
+		return printJSON(diff)
+	}
+
+	printNames := func(title string, names []string) {
+		fmt.Printf("%s (%d):\n", title, len(names))
+		for _, name := range names {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	printNames(fmt.Sprintf("Only on '%s'", diff.Source), diff.OnlyInSource)
+	printNames(fmt.Sprintf("Only on '%s'", diff.Target), diff.OnlyInTarget)
+	printNames("On both", diff.Common)
+
+	if diff.UnnamedSource > 0 || diff.UnnamedTarget > 0 {
+		p.hint("Not compared: %d unnamed keys on '%s', %d on '%s'.", diff.UnnamedSource, diff.Source, diff.UnnamedTarget, diff.Target)
+	}
+	return nil
+}
+
+func (p *printer) printImportResults(results []config.ImportResult) error {
+	if p.json() {
+		return printJSON(results)
+	}
+
+	for _, result := range results {
+		switch {
+		case !result.Imported:
+			fmt.Printf("%s: skipped: %s\n", result.Name, result.Error)
+		case result.Version != "":
+			fmt.Printf("%s: imported (version %s)\n", result.Name, result.Version)
+		default:
+			fmt.Printf("%s: imported\n", result.Name)
+		}
+	}
+	return nil
+}
+
+// printFetchCertResults prints the fingerprint each server presented, or why it could not be fetched
+func (p *printer) printFetchCertResults(results []config.FetchCertResult) error {
+	if p.json() {
+		return printJSON(results)
+	}
+
+	saved := false
+	unsaved := 0
+	for _, result := range results {
+		switch {
+		case result.Error != "":
+			fmt.Printf("%s: failed: %s\n", result.Name, result.Error)
+		case result.Saved:
+			saved = true
+			fmt.Printf("%s: %s (%s), saved\n", result.Name, result.SHA256, result.Kind)
+		default:
+			unsaved++
+			fmt.Printf("%s: %s (%s)\n", result.Name, result.SHA256, result.Kind)
+		}
+	}
+
+	switch {
+	case len(results) == 0:
+		p.hint("Every server has a pinned certificate.")
+	case unsaved > 0:
+		p.hint("Nothing was saved. Verify the fingerprints, then pass --yes to pin these %d certificates.", unsaved)
+	case saved:
+		p.hint("The certificates were not verified; compare the fingerprints with the certSha256 your Outline Manager shows.")
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/config"
+)
+
+func TestPrintReachable(t *testing.T) {
+	ok := config.ServerResult[config.ServerHealth]{Server: "a"}
+	down := config.ServerResult[config.ServerHealth]{Server: "b", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name   string
+		result *config.FanOutResult[config.ServerHealth]
+		want   error
+	}{
+		{"all reachable", &config.FanOutResult[config.ServerHealth]{Results: []config.ServerResult[config.ServerHealth]{ok}, Total: 1}, nil},
+		{"one down", &config.FanOutResult[config.ServerHealth]{Results: []config.ServerResult[config.ServerHealth]{ok, down}, Total: 2}, errUnreachable},
+		{"stopped early", &config.FanOutResult[config.ServerHealth]{Results: []config.ServerResult[config.ServerHealth]{ok}, Total: 2}, errUnreachable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := printReachable(tt.result, false); !errors.Is(err, tt.want) {
+				t.Errorf("printReachable() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFleetMetricsCSV(t *testing.T) {
+	fleet := &config.FleetMetrics{
+		Servers: []config.ServerTotal{
+			{Server: "a", MetricsEnabled: true, Users: 2, Total: 1500000},
+			{Server: "b", Users: 0, Total: 0},
+		},
+		Total: 1500000,
+	}
+
+	var buf bytes.Buffer
+	p := &printer{}
+	if err := p.writeFleetMetricsCSV(&buf, fleet); err != nil {
+		t.Fatalf("writeFleetMetricsCSV failed: %v", err)
+	}
+
+	expected := "server,metricsEnabled,users,bytes,used\n" +
+		"a,true,2,1500000,1.5 MB\n" +
+		"b,false,0,0,0 B\n" +
+		"total,,,1500000,1.5 MB\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected CSV output:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
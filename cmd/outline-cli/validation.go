@@ -1,40 +1,431 @@
 package main
 
 import (
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net/url"
+	"math"
+	"net"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/dustin/go-humanize"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+	"github.com/art-shutter/outline-cli/internal/config"
 )
 
+// validateArgs checks the flags that go-arg cannot check by itself. Every problem is reported, not
+// only the first, so that several mistakes can be fixed in one go.
 func validateArgs(args *Args) error {
+	var errs []error
+
+	if args.Timeout < 0 || args.DialTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--timeout and --dial-timeout cannot be negative"))
+	}
+
+	if args.Retries < 0 || args.MaxRetryWait < 0 || args.RetryBudget < 0 {
+		errs = append(errs, fmt.Errorf("--retries, --max-retry-wait and --retry-budget cannot be negative"))
+	}
+
+	if args.Backups < 0 {
+		errs = append(errs, fmt.Errorf("--backups cannot be negative"))
+	}
+
+	errs = append(errs, checkDataSizes(args))
+	errs = append(errs, checkConcurrency(args))
+
+	if args.MaxDataLimit.Percent > 0 {
+		errs = append(errs, fmt.Errorf("--max-data-limit must be a size, not a percentage"))
+	} else if args.MaxDataLimit.Set && args.MaxDataLimit.Bytes == 0 {
+		errs = append(errs, fmt.Errorf("--max-data-limit must be greater than 0"))
+	}
+
+	if args.Output.Format == "csv" && !supportsCSV(args) {
+		errs = append(errs, fmt.Errorf("--output csv is only supported by keys list, keys usage-report and servers metrics --aggregate"))
+	}
+
+	if args.Output.Format == "yaml" && !supportsYAML(args) {
+		errs = append(errs, fmt.Errorf("--output yaml is only supported by servers list, servers get, keys list, keys get, keys describe, keys resolve and parse-url"))
+	}
+
+	if args.Template != "" || args.TemplateFile != "" {
+		if args.Template != "" && args.TemplateFile != "" {
+			errs = append(errs, fmt.Errorf("--template and --template-file cannot be used together"))
+		}
+		if args.Output.Format != "text" {
+			errs = append(errs, fmt.Errorf("--template cannot be used with --output %s", args.Output.Format))
+		}
+		if !supportsYAML(args) {
+			errs = append(errs, fmt.Errorf("--template is only supported by servers list, servers get, keys list, keys get, keys describe, keys resolve and parse-url"))
+		}
+	}
+
+	if args.NoSeparator && args.Separator != nil {
+		errs = append(errs, fmt.Errorf("--separator and --no-separator cannot be used together"))
+	}
+
+	if args.JSONArray {
+		if args.Output.Format != "json" {
+			errs = append(errs, fmt.Errorf("--json-array requires --output json"))
+		}
+		if !singleItemCommand(args) {
+			errs = append(errs, fmt.Errorf("--json-array is only supported by keys get, keys describe, keys resolve, servers get and parse-url"))
+		}
+	}
+
+	if args.Servers != nil {
+		if args.Servers.Add != nil {
+			errs = append(errs, config.ValidateServerName(args.Servers.Add.Name))
+		}
+
+		if args.Servers.AddJSON != nil {
+			errs = append(errs, config.ValidateServerName(args.Servers.AddJSON.Name))
+		}
+
+		if args.Servers.Update != nil {
+			if args.Servers.Update.LocalOnly && args.Servers.Update.NewName == "" {
+				errs = append(errs, fmt.Errorf("--local-only requires --name"))
+			}
+			if args.Servers.Update.NewName != "" {
+				errs = append(errs, config.ValidateServerName(args.Servers.Update.NewName))
+			}
+		}
+
+		if args.Servers.Metrics != nil {
+			errs = append(errs, validateFanOut(args.Servers.Metrics.ServerName, args.Servers.Metrics.FanOutArgs, "metrics"))
+
+			if args.Servers.Metrics.Aggregate && args.Servers.Metrics.Top.Number > 0 {
+				errs = append(errs, fmt.Errorf("--aggregate and --top cannot be used together"))
+			}
+		}
+
+		if args.Servers.Get != nil && args.Servers.Get.FlattenLimit {
+			errs = append(errs, validateFlattenLimit(args))
+		}
+
+		if args.Servers.Health != nil {
+			errs = append(errs, validateFanOut(args.Servers.Health.ServerName, args.Servers.Health.FanOutArgs, "health"))
+		}
+
+		if args.Servers.Reachable != nil {
+			errs = append(errs, validateFanOut(args.Servers.Reachable.ServerName, args.Servers.Reachable.FanOutArgs, "reachable"))
+
+			if args.Servers.Reachable.SkipUnreachable {
+				errs = append(errs, fmt.Errorf("--skip-unreachable cannot be used for reachable operation, which reports unreachable servers itself"))
+			}
+		}
+	}
+
 	if args.Keys != nil {
+		if args.Keys.List != nil {
+			if args.Keys.List.UnusedThreshold.Bytes > 0 && !args.Keys.List.Unused {
+				errs = append(errs, fmt.Errorf("--unused-threshold requires --unused"))
+			}
+
+			if args.Keys.List.UnusedThreshold.Percent > 0 {
+				errs = append(errs, fmt.Errorf("--unused-threshold must be a size, not a percentage"))
+			}
+
+			if args.Keys.List.IDOnly && args.Keys.List.NameOnly {
+				errs = append(errs, fmt.Errorf("--id-only and --name-only cannot be used together"))
+			}
+
+			if (args.Keys.List.IDOnly || args.Keys.List.NameOnly) && args.Output.Format != "" && args.Output.Format != "text" {
+				errs = append(errs, fmt.Errorf("--id-only and --name-only cannot be used with --output %s", args.Output.Format))
+			}
+
+			if args.Keys.List.FlattenLimit {
+				errs = append(errs, validateFlattenLimit(args))
+				if args.Keys.List.IDOnly || args.Keys.List.NameOnly {
+					errs = append(errs, fmt.Errorf("--flatten-limit cannot be used with --id-only or --name-only"))
+				}
+			}
+		}
+
+		if args.Keys.Prune != nil && !args.Keys.Prune.Unused && args.Keys.Prune.OlderThan.Duration == 0 {
+			errs = append(errs, fmt.Errorf("keys prune requires --unused, --older-than or both"))
+		}
+
+		if args.Keys.Create != nil {
+			if args.Keys.Create.Rollback && !args.Keys.Create.Reconcile {
+				errs = append(errs, fmt.Errorf("--rollback requires --reconcile"))
+			}
+
+			if args.Keys.Create.Count < 0 {
+				errs = append(errs, fmt.Errorf("--count cannot be negative"))
+			}
+
+			if args.Keys.Create.Count > 0 && args.Keys.Create.NamesFromFile != "" {
+				errs = append(errs, fmt.Errorf("--count and --names-from-file cannot be used together"))
+			}
+
+			if args.Keys.Create.BatchOutput != "" && args.Keys.Create.BatchOutput != "csv" {
+				errs = append(errs, fmt.Errorf("invalid batch output format '%s', only 'csv' is supported", args.Keys.Create.BatchOutput))
+			}
+
+			if args.Keys.Create.OutputFile != "" && args.Keys.Create.BatchOutput == "" {
+				errs = append(errs, fmt.Errorf("--file requires --batch-output"))
+			}
+
+			if args.Keys.Create.AccessURLOnly && args.Keys.Create.BatchOutput != "" {
+				errs = append(errs, fmt.Errorf("--access-url-only and --batch-output cannot be used together"))
+			}
+
+			if args.Keys.Create.Unlimited && args.Keys.Create.DataLimit.Set {
+				errs = append(errs, fmt.Errorf("--unlimited and --data-limit cannot be used together"))
+			}
+
+			if args.Keys.Create.InviteLink && (args.Keys.Create.AccessURLOnly || args.Keys.Create.BatchOutput != "") {
+				errs = append(errs, fmt.Errorf("--invite-link cannot be used with --access-url-only or --batch-output"))
+			}
+
+			if args.Keys.Create.IDOnly && (args.Keys.Create.AccessURLOnly || args.Keys.Create.BatchOutput != "" || args.Keys.Create.InviteLink) {
+				errs = append(errs, fmt.Errorf("--id-only cannot be used with --access-url-only, --batch-output or --invite-link"))
+			}
+		}
+
+		if args.Keys.Get != nil {
+			if args.Keys.Get.KeyID == "" && args.Keys.Get.KeyName == "" {
+				errs = append(errs, fmt.Errorf("either --key-id or --key-name must be specified for get operation"))
+			}
+
+			if args.Keys.Get.KeyID != "" && args.Keys.Get.KeyName != "" {
+				errs = append(errs, fmt.Errorf("--key-id and --key-name cannot be used together for get operation"))
+			}
+		}
+
+		if args.Keys.Describe != nil {
+			if args.Keys.Describe.KeyID == "" && args.Keys.Describe.KeyName == "" {
+				errs = append(errs, fmt.Errorf("either --key-id or --key-name must be specified for describe operation"))
+			}
+
+			if args.Keys.Describe.KeyID != "" && args.Keys.Describe.KeyName != "" {
+				errs = append(errs, fmt.Errorf("--key-id and --key-name cannot be used together for describe operation"))
+			}
+		}
+
+		if args.Keys.Resolve != nil {
+			if args.Keys.Resolve.KeyID == "" && args.Keys.Resolve.KeyName == "" {
+				errs = append(errs, fmt.Errorf("either --key-id or --key-name must be specified for resolve operation"))
+			}
+
+			if args.Keys.Resolve.KeyID != "" && args.Keys.Resolve.KeyName != "" {
+				errs = append(errs, fmt.Errorf("--key-id and --key-name cannot be used together for resolve operation"))
+			}
+		}
+
+		if move := args.Keys.Move; move != nil {
+			if move.KeyID == "" && move.KeyName == "" {
+				errs = append(errs, fmt.Errorf("either --key-id or --key-name must be specified for move operation"))
+			}
+
+			if move.KeyID != "" && move.KeyName != "" {
+				errs = append(errs, fmt.Errorf("--key-id and --key-name cannot be used together for move operation"))
+			}
+		}
+
+		if watch := args.Keys.Watch; watch != nil {
+			if watch.Threshold <= 0 || watch.Threshold > 100 {
+				errs = append(errs, fmt.Errorf("--threshold must be a percentage above 0 and at most 100"))
+			}
+
+			if watch.Interval < time.Second {
+				errs = append(errs, fmt.Errorf("--interval must be at least 1s"))
+			}
+		}
+
+		if setLimit := args.Keys.SetLimit; setLimit != nil {
+			errs = append(errs, validateKeySelector(setLimit.KeyID, setLimit.KeyName, "set-limit"))
+		}
+
+		if removeLimit := args.Keys.RemoveLimit; removeLimit != nil {
+			errs = append(errs, validateKeySelector(removeLimit.KeyID, removeLimit.KeyName, "remove-limit"))
+		}
+
 		if args.Keys.Delete != nil {
 			if args.Keys.Delete.KeyID == "" && args.Keys.Delete.KeyName == "" {
-				return fmt.Errorf("either --key-id or --key-name must be specified for delete operation")
+				errs = append(errs, fmt.Errorf("either --key-id or --key-name must be specified for delete operation"))
 			}
 		}
 
-		if args.Keys.Edit != nil {
-			if args.Keys.Edit.KeyID == "" && args.Keys.Edit.KeyName == "" {
-				return fmt.Errorf("either --key-id or --key-name must be specified for edit operation")
+		if edit := args.Keys.Edit; edit != nil {
+			if edit.NamePrefix != "" && edit.Cap.String() == "" {
+				errs = append(errs, fmt.Errorf("--name-prefix can only be used with --cap"))
+			}
+			if edit.CheckUsage && !edit.DataLimit.Set {
+				errs = append(errs, fmt.Errorf("--check-usage can only be used with --data-limit"))
+			}
+
+			if edit.KeyID == "" && edit.KeyName == "" && edit.NamePrefix == "" {
+				errs = append(errs, fmt.Errorf("either --key-id or --key-name must be specified for edit operation"))
+			}
+			if edit.NamePrefix != "" && (edit.KeyID != "" || edit.KeyName != "") {
+				errs = append(errs, fmt.Errorf("--name-prefix cannot be combined with --key-id or --key-name"))
 			}
 
-			if args.Keys.Edit.NewName == "" && args.Keys.Edit.DataLimit.String() == "" && !args.Keys.Edit.RemoveLimit {
-				return fmt.Errorf("at least one of --new-name, --data-limit, or --remove-limit must be specified for edit operation")
+			if edit.Cap.String() != "" {
+				if edit.NewName != "" || edit.DataLimit.Set || edit.RemoveLimit {
+					errs = append(errs, fmt.Errorf("--cap cannot be combined with --new-name, --data-limit or --remove-limit"))
+				}
+			} else if edit.NewName == "" && !edit.DataLimit.Set && !edit.RemoveLimit {
+				errs = append(errs, fmt.Errorf("at least one of --new-name, --data-limit, --remove-limit or --cap must be specified for edit operation"))
 			}
 		}
 	}
 
+	return errors.Join(errs...)
+}
+
+// validateFlattenLimit rejects --flatten-limit unless the output is JSON, YAML or a template,
+// since text and CSV output already show data limits as sizes
+func validateFlattenLimit(args *Args) error {
+	if args.Output.Format != "json" && args.Output.Format != "yaml" && args.Template == "" && args.TemplateFile == "" {
+		return fmt.Errorf("--flatten-limit requires --output json or yaml")
+	}
+	return nil
+}
+
+func supportsCSV(args *Args) bool {
+	if args.Servers != nil {
+		return args.Servers.Metrics != nil && args.Servers.Metrics.Aggregate
+	}
+	return args.Keys != nil && (args.Keys.List != nil || args.Keys.Usage != nil)
+}
+
+func supportsYAML(args *Args) bool {
+	if args.ParseURL != nil {
+		return true
+	}
+	if args.Servers != nil {
+		return args.Servers.List != nil || args.Servers.Get != nil
+	}
+	if args.Keys != nil {
+		return args.Keys.List != nil || args.Keys.Get != nil || args.Keys.Describe != nil || args.Keys.Resolve != nil
+	}
+	return false
+}
+
+// singleItemCommand reports whether the command prints a single object in JSON output
+func singleItemCommand(args *Args) bool {
+	if args.ParseURL != nil {
+		return true
+	}
+	if args.Servers != nil {
+		return args.Servers.Get != nil
+	}
+	if args.Keys != nil {
+		return (args.Keys.Get != nil && !args.Keys.Get.AccessURLOnly) || args.Keys.Describe != nil || args.Keys.Resolve != nil
+	}
+	return false
+}
+
+// checkConcurrency warns, or fails with --strict, when --concurrency asks for more parallel
+// requests than --max-conns lets the client send to a server at once
+func checkConcurrency(args *Args) error {
+	if args.Keys == nil {
+		return nil
+	}
+	var concurrency PositiveInt
+	switch {
+	case args.Keys.Create != nil:
+		concurrency = args.Keys.Create.Concurrency
+	case args.Keys.Import != nil:
+		concurrency = args.Keys.Import.Concurrency
+	}
+	// MaxConns is only unset when the args were not parsed from a command line
+	if args.MaxConns.Number == 0 || concurrency.Number <= args.MaxConns.Number {
+		return nil
+	}
+
+	warning := fmt.Sprintf("--concurrency %d is above --max-conns %d, so only %d keys are created at once", concurrency.Number, args.MaxConns.Number, args.MaxConns.Number)
+	if args.Strict {
+		return fmt.Errorf("%s", warning)
+	}
+	slog.Warn(warning)
+	return nil
+}
+
+// checkDataSizes warns about sizes that were probably mistyped, or rejects them with --strict
+func checkDataSizes(args *Args) error {
+	sizes := []DataSize{args.MaxDataLimit}
+	if args.Keys != nil {
+		if args.Keys.Create != nil {
+			sizes = append(sizes, args.Keys.Create.DataLimit)
+		}
+		if args.Keys.Edit != nil {
+			sizes = append(sizes, args.Keys.Edit.DataLimit, args.Keys.Edit.Cap)
+		}
+		if args.Keys.SetLimit != nil {
+			sizes = append(sizes, args.Keys.SetLimit.DataLimit)
+		}
+		if args.Keys.List != nil {
+			sizes = append(sizes, args.Keys.List.UnusedThreshold)
+		}
+	}
+
+	var errs []error
+	for _, size := range sizes {
+		if size.Warning == "" {
+			continue
+		}
+		if args.Strict {
+			errs = append(errs, fmt.Errorf("%s", size.Warning))
+			continue
+		}
+		slog.Warn(size.Warning)
+	}
+	return errors.Join(errs...)
+}
+
+// validateKeySelector checks that exactly one of --key-id and --key-name was given
+func validateKeySelector(keyID, keyName, operation string) error {
+	if keyID == "" && keyName == "" {
+		return fmt.Errorf("either --key-id or --key-name must be specified for %s operation", operation)
+	}
+	if keyID != "" && keyName != "" {
+		return fmt.Errorf("--key-id and --key-name cannot be used together for %s operation", operation)
+	}
+	return nil
+}
+
+func validateFanOut(serverName string, fanOut FanOutArgs, operation string) error {
+	selectors := 0
+	for _, set := range []bool{serverName != "", fanOut.All, fanOut.Group != ""} {
+		if set {
+			selectors++
+		}
+	}
+
+	if selectors == 0 {
+		return fmt.Errorf("either a server name, --all or --group must be specified for %s operation", operation)
+	}
+
+	if selectors > 1 {
+		return fmt.Errorf("only one of a server name, --all and --group can be used for %s operation", operation)
+	}
+
+	if fanOut.FailFast && !fanOut.All && fanOut.Group == "" && !config.IsServerPattern(serverName) {
+		return fmt.Errorf("--fail-fast can only be used with --all, --group or a server pattern for %s operation", operation)
+	}
+
+	if fanOut.SkipUnreachable && !fanOut.All && fanOut.Group == "" && !config.IsServerPattern(serverName) {
+		return fmt.Errorf("--skip-unreachable can only be used with --all, --group or a server pattern for %s operation", operation)
+	}
+
 	return nil
 }
 
 type DataSize struct {
 	Bytes int64
+	// Percent is set instead of Bytes for sizes like '50%', relative to the server's default limit
+	Percent float64
+	// Warning is set when the size parsed but was probably a mistake, like '1.5' without a unit
+	Warning string
+	// Set tells an explicit '0' apart from a size that was not given
+	Set bool
 }
 
 func (d *DataSize) UnmarshalText(text []byte) error {
@@ -42,20 +433,45 @@ func (d *DataSize) UnmarshalText(text []byte) error {
 		d.Bytes = 0
 		return nil
 	}
+	d.Set = true
 
 	sizeStr := strings.TrimSpace(string(text))
 
-	bytes, err := humanize.ParseBytes(sizeStr)
+	if number, found := strings.CutSuffix(sizeStr, "%"); found {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil || percent <= 0 || math.IsInf(percent, 0) {
+			slog.Error("invalid data size percentage", "size", sizeStr)
+			return fmt.Errorf("invalid data size percentage. Expected a positive number like '50%%'. Got: %s", sizeStr)
+		}
+		d.Percent = percent
+		return nil
+	}
+
+	bytes, err := config.ParseDataSize(sizeStr)
 	if err != nil {
-		slog.Error("invalid data size format", "error", err, "size", sizeStr, "expected", "like 1GB, 500MB, 2TB", "got", sizeStr)
-		return fmt.Errorf("invalid data size format. Expected format like '1GB', '500MB', '2TB'. Got: %s", sizeStr)
+		slog.Error("invalid data size", "error", err, "size", sizeStr)
+		return err
 	}
 
-	d.Bytes = int64(bytes)
+	d.Bytes = bytes
+	d.Warning = config.AmbiguousSizeWarning(sizeStr, d.Bytes)
 	return nil
 }
 
+// Limit returns the size in bytes, including an explicit zero, or nil when no size or a
+// percentage was given
+func (d DataSize) Limit() *int64 {
+	if !d.Set || d.Percent > 0 {
+		return nil
+	}
+	bytes := d.Bytes
+	return &bytes
+}
+
 func (d DataSize) MarshalText() ([]byte, error) {
+	if d.Percent > 0 {
+		return []byte(d.String()), nil
+	}
 	if d.Bytes == 0 {
 		return []byte(""), nil
 	}
@@ -63,6 +479,9 @@ func (d DataSize) MarshalText() ([]byte, error) {
 }
 
 func (d DataSize) String() string {
+	if d.Percent > 0 {
+		return strconv.FormatFloat(d.Percent, 'f', -1, 64) + "%"
+	}
 	if d.Bytes == 0 {
 		return ""
 	}
@@ -81,20 +500,9 @@ func (s *ServerURL) UnmarshalText(text []byte) error {
 
 	urlStr := strings.TrimSpace(string(text))
 
-	parsedURL, err := url.Parse(urlStr)
-	if err != nil {
-		slog.Error("invalid URL format", "error", err, "url", urlStr)
-		return fmt.Errorf("invalid URL format: %v", err)
-	}
-
-	if parsedURL.Scheme == "" {
-		slog.Error("URL must include a scheme (e.g., https://)")
-		return fmt.Errorf("URL must include a scheme (e.g., https://)")
-	}
-
-	if parsedURL.Host == "" {
-		slog.Error("URL must include a host")
-		return fmt.Errorf("URL must include a host")
+	if err := config.ValidateServerURL(urlStr); err != nil {
+		slog.Error("invalid server URL", "error", err, "url", urlStr)
+		return err
 	}
 
 	s.URL = urlStr
@@ -120,10 +528,9 @@ func (c *CertSHA256) UnmarshalText(text []byte) error {
 
 	hash := strings.TrimSpace(string(text))
 
-	_, err := hex.DecodeString(hash)
-	if err != nil {
+	if err := config.ValidateCertSHA256(hash); err != nil {
 		slog.Error("invalid SHA256 hash format", "error", err, "hash", hash)
-		return fmt.Errorf("invalid SHA256 hash format: %v", err)
+		return err
 	}
 
 	c.Hash = hash
@@ -173,6 +580,117 @@ func (p Port) String() string {
 	return strconv.Itoa(p.Number)
 }
 
+type PositiveInt struct {
+	Number int
+}
+
+func (p *PositiveInt) UnmarshalText(text []byte) error {
+	numStr := strings.TrimSpace(string(text))
+
+	number, err := strconv.Atoi(numStr)
+	if err != nil {
+		slog.Error("invalid number", "error", err, "number", numStr)
+		return fmt.Errorf("invalid number: %v", err)
+	}
+
+	if number < 1 {
+		slog.Error("number must be at least 1", "number", number)
+		return fmt.Errorf("number must be at least 1, got: %d", number)
+	}
+
+	p.Number = number
+	return nil
+}
+
+func (p PositiveInt) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(p.Number)), nil
+}
+
+func (p PositiveInt) String() string {
+	return strconv.Itoa(p.Number)
+}
+
+// RetryOn is the comma-separated list of failures given to --retry-on. It keeps the normalized
+// list as text because go-arg only applies the default to a comparable type.
+type RetryOn struct {
+	List string
+}
+
+func (r *RetryOn) UnmarshalText(text []byte) error {
+	conditions, err := api.ParseRetryConditions(string(text))
+	if err != nil {
+		return err
+	}
+	tokens := make([]string, len(conditions))
+	for i, condition := range conditions {
+		tokens[i] = string(condition)
+	}
+	r.List = strings.Join(tokens, ",")
+	return nil
+}
+
+// Conditions is the parsed list; nil when the flag was never set, leaving the client's default
+func (r RetryOn) Conditions() []api.RetryCondition {
+	conditions, err := api.ParseRetryConditions(r.List)
+	if err != nil {
+		return nil
+	}
+	return conditions
+}
+
+func (r RetryOn) MarshalText() ([]byte, error) {
+	return []byte(r.List), nil
+}
+
+func (r RetryOn) String() string {
+	return r.List
+}
+
+// HostOverride is a host:ip pair given to --resolve
+type HostOverride struct {
+	Host string
+	IP   string
+}
+
+func (h *HostOverride) UnmarshalText(text []byte) error {
+	host, ip, found := strings.Cut(strings.TrimSpace(string(text)), ":")
+	// An IPv6 address may be given in brackets, as in a URL
+	ip = strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+	if !found || host == "" || net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid host override '%s'. Expected host:ip, e.g. outline.example.com:203.0.113.7", text)
+	}
+	h.Host = strings.ToLower(host)
+	h.IP = ip
+	return nil
+}
+
+func (h HostOverride) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+func (h HostOverride) String() string {
+	if h.Host == "" {
+		return ""
+	}
+	if strings.Contains(h.IP, ":") {
+		return h.Host + ":[" + h.IP + "]"
+	}
+	return h.Host + ":" + h.IP
+}
+
+// hostOverrides turns the --resolve flags into the map the API client takes; a later flag for
+// the same host wins
+func hostOverrides(overrides []HostOverride) map[string]string {
+	if len(overrides) == 0 {
+		return nil
+	}
+	resolve := make(map[string]string, len(overrides))
+	for _, override := range overrides {
+		resolve[override.Host] = override.IP
+	}
+	return resolve
+}
+
 type EncryptionMethod struct {
 	Method string
 }
@@ -213,18 +731,205 @@ func (e EncryptionMethod) String() string {
 	return e.Method
 }
 
-func ParseDataSize(sizeStr string) (int64, error) {
-	if sizeStr == "" {
-		return 0, nil
+type OutputFormat struct {
+	Format string
+}
+
+var validOutputFormats = []string{"text", "json", "yaml", "csv"}
+
+func (o *OutputFormat) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		o.Format = "text"
+		return nil
 	}
 
-	sizeStr = strings.TrimSpace(sizeStr)
+	format := strings.ToLower(strings.TrimSpace(string(text)))
+
+	for _, f := range validOutputFormats {
+		if f == format {
+			o.Format = format
+			return nil
+		}
+	}
+
+	slog.Error("invalid output format", "format", format, "valid_formats", strings.Join(validOutputFormats, ", "))
+	return fmt.Errorf("invalid output format. Valid formats are: %s", strings.Join(validOutputFormats, ", "))
+}
+
+func (o OutputFormat) MarshalText() ([]byte, error) {
+	return []byte(o.Format), nil
+}
+
+func (o OutputFormat) String() string {
+	return o.Format
+}
+
+type UnitSystem struct {
+	System string
+}
+
+func (u *UnitSystem) UnmarshalText(text []byte) error {
+	system := strings.ToLower(strings.TrimSpace(string(text)))
+
+	switch system {
+	case "", config.UnitsSI:
+		u.System = config.UnitsSI
+	case config.UnitsIEC:
+		u.System = config.UnitsIEC
+	default:
+		slog.Error("invalid unit system", "units", system)
+		return fmt.Errorf("invalid unit system. Valid systems are: %s, %s", config.UnitsSI, config.UnitsIEC)
+	}
+
+	return nil
+}
 
-	bytes, err := humanize.ParseBytes(sizeStr)
+func (u UnitSystem) MarshalText() ([]byte, error) {
+	return []byte(u.System), nil
+}
+
+func (u UnitSystem) String() string {
+	return u.System
+}
+
+type APIEndpoint struct {
+	Path string
+}
+
+func (e *APIEndpoint) UnmarshalText(text []byte) error {
+	path := strings.Trim(strings.TrimSpace(string(text)), "/")
+	if path == "" {
+		path = "server"
+	}
+
+	if !api.IsRawEndpoint(path) {
+		slog.Error("endpoint not allowed", "endpoint", path, "allowed", strings.Join(api.RawEndpoints, ", "))
+		return fmt.Errorf("endpoint not allowed. Allowed endpoints are: %s", strings.Join(api.RawEndpoints, ", "))
+	}
+
+	e.Path = path
+	return nil
+}
+
+func (e APIEndpoint) MarshalText() ([]byte, error) {
+	return []byte(e.Path), nil
+}
+
+func (e APIEndpoint) String() string {
+	return e.Path
+}
+
+type ProfileName struct {
+	Name string
+}
+
+func (p *ProfileName) UnmarshalText(text []byte) error {
+	name := strings.TrimSpace(string(text))
+	if name == "" {
+		p.Name = config.DefaultProfile
+		return nil
+	}
+
+	if err := config.ValidateProfileName(name); err != nil {
+		slog.Error("invalid profile name", "profile", name)
+		return err
+	}
+
+	p.Name = name
+	return nil
+}
+
+func (p ProfileName) MarshalText() ([]byte, error) {
+	return []byte(p.Name), nil
+}
+
+func (p ProfileName) String() string {
+	return p.Name
+}
+
+// Age is a duration that also accepts whole days, e.g. '90d', for ages measured in days
+type Age struct {
+	Duration time.Duration
+}
+
+func (a *Age) UnmarshalText(text []byte) error {
+	ageStr := strings.TrimSpace(string(text))
+
+	var age time.Duration
+	if days, ok := strings.CutSuffix(ageStr, "d"); ok {
+		number, err := strconv.Atoi(days)
+		if err != nil || number > math.MaxInt64/int(24*time.Hour) {
+			slog.Error("invalid age", "age", ageStr)
+			return fmt.Errorf("invalid age '%s': use a number of days like '90d' or a duration like '36h'", ageStr)
+		}
+		age = time.Duration(number) * 24 * time.Hour
+	} else {
+		duration, err := time.ParseDuration(ageStr)
+		if err != nil {
+			slog.Error("invalid age", "age", ageStr, "error", err)
+			return fmt.Errorf("invalid age '%s': use a number of days like '90d' or a duration like '36h'", ageStr)
+		}
+		age = duration
+	}
+
+	if age <= 0 {
+		slog.Error("age must be positive", "age", ageStr)
+		return fmt.Errorf("age must be positive, got: %s", ageStr)
+	}
+
+	a.Duration = age
+	return nil
+}
+
+func (a Age) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a Age) String() string {
+	if a.Duration > 0 && a.Duration%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", a.Duration/(24*time.Hour))
+	}
+	return a.Duration.String()
+}
+
+// nameTemplateFuncs help a name template replace part of a name; the string to change comes last
+// so they can be used in pipelines, e.g. {{.Name | trimPrefix "old-"}}
+var nameTemplateFuncs = template.FuncMap{
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+}
+
+// NameTemplate is a Go template that computes a new key name with the key as its data, compiled
+// when the flag is parsed
+type NameTemplate struct {
+	Source   string
+	Template *template.Template
+}
+
+func (t *NameTemplate) UnmarshalText(text []byte) error {
+	source := string(text)
+	if strings.TrimSpace(source) == "" {
+		return fmt.Errorf("name template cannot be empty")
+	}
+
+	tmpl, err := template.New("name-template").Funcs(nameTemplateFuncs).Parse(source)
 	if err != nil {
-		slog.Error("invalid data size format", "error", err, "size", sizeStr, "expected", "like 1GB, 500MB, 2TB", "got", sizeStr)
-		return 0, fmt.Errorf("invalid data size format. Expected format like '1GB', '500MB', '2TB'. Got: %s", sizeStr)
+		slog.Error("invalid name template", "template", source, "error", err)
+		return fmt.Errorf("invalid name template: %w", err)
 	}
 
-	return int64(bytes), nil
+	t.Source = source
+	t.Template = tmpl
+	return nil
+}
+
+func (t NameTemplate) MarshalText() ([]byte, error) {
+	return []byte(t.Source), nil
+}
+
+func ParseDataSize(sizeStr string) (int64, error) {
+	return config.ParseDataSize(sizeStr)
 }
@@ -1,7 +1,15 @@
 package main
 
 import (
+	"math"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/alexflint/go-arg"
+
+	"github.com/art-shutter/outline-cli/internal/api"
 )
 
 func TestDataSize_UnmarshalText(t *testing.T) {
@@ -27,11 +35,21 @@ func TestDataSize_UnmarshalText(t *testing.T) {
 		{"tebibyte", "1TiB", 1099511627776, false},
 		{"zero", "0GB", 0, false},
 		{"number without unit", "1000", 1000, false},
+		{"percentage is resolved later", "50%", 0, false},
+		// humanize parses through a float64, so this is the largest size below the int64 boundary
+		{"largest size below int64", "9223372036854774784", math.MaxInt64 - 1023, false},
+		{"just below the int64 boundary", "9EB", 9000000000000000000, false},
 
 		// Invalid inputs
 		{"invalid format", "invalid", 0, true},
 		{"unknown unit", "1ZB", 0, true},
 		{"negative number", "-1GB", 0, true},
+		{"zero percent", "0%", 0, true},
+		{"negative percent", "-5%", 0, true},
+		{"invalid percent", "half%", 0, true},
+		{"one above int64", "9223372036854775808", 0, true},
+		{"above int64", "10EB", 0, true},
+		{"above uint64", "100EB", 0, true},
 	}
 
 	for _, tt := range tests {
@@ -55,6 +73,48 @@ func TestDataSize_UnmarshalText(t *testing.T) {
 	}
 }
 
+func TestDataSize_Percent(t *testing.T) {
+	var ds DataSize
+	if err := ds.UnmarshalText([]byte("12.5%")); err != nil {
+		t.Fatalf("DataSize.UnmarshalText(%q) unexpected error: %v", "12.5%", err)
+	}
+	if ds.Percent != 12.5 {
+		t.Errorf("Expected 12.5 percent, got %v", ds.Percent)
+	}
+	if ds.String() != "12.5%" {
+		t.Errorf("Expected String() to round-trip, got %q", ds.String())
+	}
+}
+
+func TestDataSize_Limit(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  *int64
+	}{
+		{"not given", "", nil},
+		{"explicit zero", "0", new(int64)},
+		{"size", "1KB", func() *int64 { b := int64(1000); return &b }()},
+		{"percentage", "50%", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ds DataSize
+			if tt.input != "" {
+				if err := ds.UnmarshalText([]byte(tt.input)); err != nil {
+					t.Fatalf("DataSize.UnmarshalText(%q) unexpected error: %v", tt.input, err)
+				}
+			}
+
+			got := ds.Limit()
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("DataSize.Limit() for %q = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestServerURL_UnmarshalText(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -178,6 +238,208 @@ func TestPort_UnmarshalText(t *testing.T) {
 	}
 }
 
+func TestPositiveInt_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+		hasError bool
+	}{
+		{"one", "1", 1, false},
+		{"large", "128", 128, false},
+		{"with spaces", " 8 ", 8, false},
+
+		// Invalid inputs
+		{"empty string", "", 0, true},
+		{"zero", "0", 0, true},
+		{"negative", "-4", 0, true},
+		{"not a number", "many", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p PositiveInt
+			err := p.UnmarshalText([]byte(tt.input))
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("PositiveInt.UnmarshalText(%q) expected error, got nil", tt.input)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("PositiveInt.UnmarshalText(%q) unexpected error: %v", tt.input, err)
+				}
+				if p.Number != tt.expected {
+					t.Errorf("PositiveInt.UnmarshalText(%q) = %d, want %d", tt.input, p.Number, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestAge_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+		hasError bool
+	}{
+		{"days", "90d", 90 * 24 * time.Hour, false},
+		{"duration", "36h", 36 * time.Hour, false},
+		{"with spaces", " 7d ", 7 * 24 * time.Hour, false},
+
+		// Invalid inputs
+		{"empty string", "", 0, true},
+		{"zero days", "0d", 0, true},
+		{"negative duration", "-1h", 0, true},
+		{"fractional days", "1.5d", 0, true},
+		{"weeks", "2w", 0, true},
+		{"too many days", "999999999d", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a Age
+			err := a.UnmarshalText([]byte(tt.input))
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Age.UnmarshalText(%q) expected error, got nil", tt.input)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("Age.UnmarshalText(%q) unexpected error: %v", tt.input, err)
+				}
+				if a.Duration != tt.expected {
+					t.Errorf("Age.UnmarshalText(%q) = %v, want %v", tt.input, a.Duration, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestNameTemplate_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		hasError bool
+	}{
+		{"prefix", "2024-{{.Name}}", "2024-old-alice", false},
+		{"trim prefix", `{{.Name | trimPrefix "old-"}}`, "alice", false},
+		{"replace", `{{.Name | replace "old" "new" | upper}}`, "NEW-ALICE", false},
+		{"with ID", "{{.Name}}-{{.ID}}", "old-alice-7", false},
+
+		// Invalid inputs
+		{"empty string", "", "", true},
+		{"unclosed action", "{{.Name", "", true},
+		{"unknown function", "{{.Name | shout}}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var nt NameTemplate
+			err := nt.UnmarshalText([]byte(tt.input))
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("NameTemplate.UnmarshalText(%q) expected error, got nil", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NameTemplate.UnmarshalText(%q) unexpected error: %v", tt.input, err)
+			}
+			var name strings.Builder
+			if err := nt.Template.Execute(&name, api.AccessKey{ID: "7", Name: "old-alice"}); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if name.String() != tt.expected {
+				t.Errorf("NameTemplate %q gave %q, want %q", tt.input, name.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestAPIEndpoint_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		hasError bool
+	}{
+		{"default", "", "server", false},
+		{"server", "server", "server", false},
+		{"access keys", "access-keys", "access-keys", false},
+		{"metrics with slashes", "/metrics/transfer/", "metrics/transfer", false},
+
+		// Invalid inputs
+		{"mutating endpoint", "access-keys/1/name", "", true},
+		{"unknown endpoint", "experimental", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e APIEndpoint
+			err := e.UnmarshalText([]byte(tt.input))
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("APIEndpoint.UnmarshalText(%q) expected error, got nil", tt.input)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("APIEndpoint.UnmarshalText(%q) unexpected error: %v", tt.input, err)
+				}
+				if e.Path != tt.expected {
+					t.Errorf("APIEndpoint.UnmarshalText(%q) = %q, want %q", tt.input, e.Path, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestProfileName_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		hasError bool
+	}{
+		{"default", "", "default", false},
+		{"named", "work", "work", false},
+		{"default by name", "default", "default", false},
+
+		// Invalid inputs
+		{"path traversal", "../work", "", true},
+		{"subdirectory", "work/eu", "", true},
+		{"hidden file", ".work", "", true},
+		{"reserved config", "config", "", true},
+		{"reserved config in other case", "Config", "", true},
+		{"default in other case", "Default", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p ProfileName
+			err := p.UnmarshalText([]byte(tt.input))
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("ProfileName.UnmarshalText(%q) expected error, got nil", tt.input)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("ProfileName.UnmarshalText(%q) unexpected error: %v", tt.input, err)
+				}
+				if p.Name != tt.expected {
+					t.Errorf("ProfileName.UnmarshalText(%q) = %q, want %q", tt.input, p.Name, tt.expected)
+				}
+			}
+		})
+	}
+}
+
 func TestValidateArgs(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -253,7 +515,7 @@ func TestValidateArgs(t *testing.T) {
 					Edit: &EditKeyCmd{
 						ServerName: "test",
 						KeyID:      "key123",
-						DataLimit:  DataSize{Bytes: 1024 * 1024 * 1024}, // 1GB
+						DataLimit:  DataSize{Bytes: 1024 * 1024 * 1024, Set: true}, // 1GB
 					},
 				},
 			},
@@ -284,24 +546,670 @@ func TestValidateArgs(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "invalid args - edit without any changes",
+			name: "valid args - metrics for one server",
+			args: &Args{
+				Servers: &ServersCmd{
+					Metrics: &MetricsCmd{ServerName: "test"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid args - parse-url as yaml",
+			args: &Args{
+				ParseURL: &ParseURLCmd{URL: "ss://YWVzLTEyOC1nY206cGFzcw@example.com:443"},
+				Output:   OutputFormat{Format: "yaml"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - prune without a criterion",
 			args: &Args{
 				Keys: &KeysCmd{
-					Edit: &EditKeyCmd{
-						ServerName: "test",
-						KeyID:      "key123",
-					},
+					Prune: &PruneKeysCmd{ServerName: "test"},
 				},
 			},
 			wantErr: true,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateArgs(tt.args)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("validateArgs() error = %v, wantErr %v", err, tt.wantErr)
+		{
+			name: "valid args - metrics for all servers with fail-fast",
+			args: &Args{
+				Servers: &ServersCmd{
+					Metrics: &MetricsCmd{FanOutArgs: FanOutArgs{All: true, FailFast: true}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - metrics without server or --all",
+			args: &Args{
+				Servers: &ServersCmd{
+					Metrics: &MetricsCmd{},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - health with server and --all",
+			args: &Args{
+				Servers: &ServersCmd{
+					Health: &HealthCmd{ServerName: "test", FanOutArgs: FanOutArgs{All: true}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - metrics with --group and --fail-fast",
+			args: &Args{
+				Servers: &ServersCmd{
+					Metrics: &MetricsCmd{FanOutArgs: FanOutArgs{Group: "eu", FailFast: true}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - health with --all and --group",
+			args: &Args{
+				Servers: &ServersCmd{
+					Health: &HealthCmd{FanOutArgs: FanOutArgs{All: true, Group: "eu"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - fail-fast without --all",
+			args: &Args{
+				Servers: &ServersCmd{
+					Health: &HealthCmd{ServerName: "test", FanOutArgs: FanOutArgs{FailFast: true}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - health for a pattern with --skip-unreachable",
+			args: &Args{
+				Servers: &ServersCmd{
+					Health: &HealthCmd{ServerName: "prod-*", FanOutArgs: FanOutArgs{SkipUnreachable: true}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - skip-unreachable for a single server",
+			args: &Args{
+				Servers: &ServersCmd{
+					Metrics: &MetricsCmd{ServerName: "test", FanOutArgs: FanOutArgs{SkipUnreachable: true}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - skip-unreachable for reachable",
+			args: &Args{
+				Servers: &ServersCmd{
+					Reachable: &ReachableCmd{FanOutArgs: FanOutArgs{All: true, SkipUnreachable: true}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - update with --local-only but no --name",
+			args: &Args{
+				Servers: &ServersCmd{
+					Update: &UpdateCmd{Name: "test", LocalOnly: true},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - get without key ID or name",
+			args: &Args{
+				Keys: &KeysCmd{
+					Get: &GetKeyCmd{ServerName: "test"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - template for keys list",
+			args: &Args{
+				Output:   OutputFormat{Format: "text"},
+				Template: "{{.ID}}",
+				Keys:     &KeysCmd{List: &ListKeysCmd{ServerName: "test"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - template and template file",
+			args: &Args{
+				Output:       OutputFormat{Format: "text"},
+				Template:     "{{.ID}}",
+				TemplateFile: "keys.tmpl",
+				Keys:         &KeysCmd{List: &ListKeysCmd{ServerName: "test"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - template with --output json",
+			args: &Args{
+				Output:   OutputFormat{Format: "json"},
+				Template: "{{.ID}}",
+				Keys:     &KeysCmd{List: &ListKeysCmd{ServerName: "test"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - template for keys create",
+			args: &Args{
+				Output:       OutputFormat{Format: "text"},
+				TemplateFile: "keys.tmpl",
+				Keys:         &KeysCmd{Create: &CreateKeyCmd{ServerName: "test"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - describe with both key ID and name",
+			args: &Args{
+				Keys: &KeysCmd{
+					Describe: &DescribeKeyCmd{ServerName: "test", KeyID: "1", KeyName: "alice"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - resolve by name",
+			args: &Args{
+				Keys: &KeysCmd{
+					Resolve: &ResolveKeyCmd{ServerName: "test", KeyName: "alice"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - resolve without key ID or name",
+			args: &Args{
+				Keys: &KeysCmd{
+					Resolve: &ResolveKeyCmd{ServerName: "test"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - create with --unlimited and --data-limit 0",
+			args: &Args{
+				Keys: &KeysCmd{
+					Create: &CreateKeyCmd{ServerName: "test", Unlimited: true, DataLimit: DataSize{Set: true}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - create with --access-url-only and --batch-output",
+			args: &Args{
+				Keys: &KeysCmd{
+					Create: &CreateKeyCmd{ServerName: "test", AccessURLOnly: true, BatchOutput: "csv"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - create with --id-only",
+			args: &Args{
+				Keys: &KeysCmd{
+					Create: &CreateKeyCmd{ServerName: "test", IDOnly: true, Count: 3},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - create with --id-only and --access-url-only",
+			args: &Args{
+				Keys: &KeysCmd{
+					Create: &CreateKeyCmd{ServerName: "test", IDOnly: true, AccessURLOnly: true},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - list with --id-only and --name-only",
+			args: &Args{
+				Keys: &KeysCmd{
+					List: &ListKeysCmd{ServerName: "test", IDOnly: true, NameOnly: true},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - list with --id-only and json output",
+			args: &Args{
+				Output: OutputFormat{Format: "json"},
+				Keys: &KeysCmd{
+					List: &ListKeysCmd{ServerName: "test", IDOnly: true},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - yaml output for keys get",
+			args: &Args{
+				Output: OutputFormat{Format: "yaml"},
+				Keys: &KeysCmd{
+					Get: &GetKeyCmd{ServerName: "test", KeyID: "1"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - yaml output for servers health",
+			args: &Args{
+				Output:  OutputFormat{Format: "yaml"},
+				Servers: &ServersCmd{Health: &HealthCmd{ServerName: "test"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - csv output for aggregated metrics",
+			args: &Args{
+				Output:  OutputFormat{Format: "csv"},
+				Servers: &ServersCmd{Metrics: &MetricsCmd{FanOutArgs: FanOutArgs{All: true}, Aggregate: true}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - csv output for per-user metrics",
+			args: &Args{
+				Output:  OutputFormat{Format: "csv"},
+				Servers: &ServersCmd{Metrics: &MetricsCmd{FanOutArgs: FanOutArgs{All: true}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - aggregate with top",
+			args: &Args{
+				Servers: &ServersCmd{Metrics: &MetricsCmd{FanOutArgs: FanOutArgs{All: true}, Aggregate: true, Top: PositiveInt{Number: 5}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - server name for servers add",
+			args: &Args{
+				Servers: &ServersCmd{Add: &AddCmd{Name: "prod-1"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - whitespace server name for servers add",
+			args: &Args{
+				Servers: &ServersCmd{Add: &AddCmd{Name: "  "}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - server name with a slash for servers add-json",
+			args: &Args{
+				Servers: &ServersCmd{AddJSON: &AddJSONCmd{Name: "prod/1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - server rename to a name with a space",
+			args: &Args{
+				Servers: &ServersCmd{Update: &UpdateCmd{Name: "prod", NewName: "prod 1"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - max data limit",
+			args: &Args{
+				MaxDataLimit: DataSize{Bytes: 500000000000, Set: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - max data limit as a percentage",
+			args: &Args{
+				MaxDataLimit: DataSize{Percent: 50, Set: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - zero max data limit",
+			args: &Args{
+				MaxDataLimit: DataSize{Set: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - separator with no separator",
+			args: &Args{
+				Separator:   new(string),
+				NoSeparator: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - csv output outside keys list",
+			args: &Args{
+				Output:  OutputFormat{Format: "csv"},
+				Servers: &ServersCmd{List: &ListCmd{}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - ambiguous data limit only warns",
+			args: &Args{
+				Keys: &KeysCmd{
+					Create: &CreateKeyCmd{ServerName: "test", DataLimit: DataSize{Bytes: 1, Warning: "no unit"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - ambiguous data limit with --strict",
+			args: &Args{
+				Strict: true,
+				Keys: &KeysCmd{
+					Create: &CreateKeyCmd{ServerName: "test", DataLimit: DataSize{Bytes: 1, Warning: "no unit"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - edit with cap and name prefix",
+			args: &Args{
+				Keys: &KeysCmd{
+					Edit: &EditKeyCmd{
+						ServerName: "test",
+						NamePrefix: "team-",
+						Cap:        DataSize{Bytes: 100 * 1000 * 1000 * 1000},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - edit with name prefix but no cap",
+			args: &Args{
+				Keys: &KeysCmd{
+					Edit: &EditKeyCmd{
+						ServerName: "test",
+						NamePrefix: "team-",
+						DataLimit:  DataSize{Bytes: 1024, Set: true},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - edit with cap and data limit",
+			args: &Args{
+				Keys: &KeysCmd{
+					Edit: &EditKeyCmd{
+						ServerName: "test",
+						KeyID:      "key123",
+						Cap:        DataSize{Bytes: 1024, Set: true},
+						DataLimit:  DataSize{Bytes: 1024, Set: true},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - edit with a zero data limit",
+			args: &Args{
+				Keys: &KeysCmd{
+					Edit: &EditKeyCmd{ServerName: "test", KeyID: "key123", DataLimit: DataSize{Set: true}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid args - edit with a zero data limit and a new name",
+			args: &Args{
+				Keys: &KeysCmd{
+					Edit: &EditKeyCmd{ServerName: "test", KeyID: "key123", NewName: "blocked", DataLimit: DataSize{Set: true}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid args - edit with a zero data limit and check usage",
+			args: &Args{
+				Keys: &KeysCmd{
+					Edit: &EditKeyCmd{ServerName: "test", KeyID: "key123", CheckUsage: true, DataLimit: DataSize{Set: true}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - edit with cap and a zero data limit",
+			args: &Args{
+				Keys: &KeysCmd{
+					Edit: &EditKeyCmd{ServerName: "test", KeyID: "key123", Cap: DataSize{Bytes: 1024, Set: true}, DataLimit: DataSize{Set: true}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - edit without any changes",
+			args: &Args{
+				Keys: &KeysCmd{
+					Edit: &EditKeyCmd{
+						ServerName: "test",
+						KeyID:      "key123",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - json-array with keys get",
+			args: &Args{
+				JSONArray: true,
+				Output:    OutputFormat{Format: "json"},
+				Keys:      &KeysCmd{Get: &GetKeyCmd{ServerName: "test", KeyID: "1"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - json-array without json output",
+			args: &Args{
+				JSONArray: true,
+				Output:    OutputFormat{Format: "text"},
+				Servers:   &ServersCmd{Get: &GetCmd{Name: "test"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - json-array with a list command",
+			args: &Args{
+				JSONArray: true,
+				Output:    OutputFormat{Format: "json"},
+				Keys:      &KeysCmd{List: &ListKeysCmd{ServerName: "test"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid args - negative backups",
+			args:    &Args{Backups: -1},
+			wantErr: true,
+		},
+		{
+			name: "valid args - set-limit by name",
+			args: &Args{
+				Keys: &KeysCmd{SetLimit: &SetLimitKeyCmd{ServerName: "test", KeyName: "alice", DataLimit: DataSize{Set: true}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - set-limit without a key",
+			args: &Args{
+				Keys: &KeysCmd{SetLimit: &SetLimitKeyCmd{ServerName: "test", DataLimit: DataSize{Bytes: 1000, Set: true}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - remove-limit with both key ID and name",
+			args: &Args{
+				Keys: &KeysCmd{RemoveLimit: &RemoveLimitKeyCmd{ServerName: "test", KeyID: "1", KeyName: "alice"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - list keys with --flatten-limit and JSON output",
+			args: &Args{
+				Output: OutputFormat{Format: "json"},
+				Keys:   &KeysCmd{List: &ListKeysCmd{ServerName: "test", FlattenLimit: true}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - list keys with --flatten-limit and text output",
+			args: &Args{
+				Output: OutputFormat{Format: "text"},
+				Keys:   &KeysCmd{List: &ListKeysCmd{ServerName: "test", FlattenLimit: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - servers get with --flatten-limit and CSV output",
+			args: &Args{
+				Output:  OutputFormat{Format: "csv"},
+				Servers: &ServersCmd{Get: &GetCmd{Name: "test", FlattenLimit: true}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - create concurrency above max-conns only warns",
+			args: &Args{
+				MaxConns: PositiveInt{Number: 8},
+				Keys:     &KeysCmd{Create: &CreateKeyCmd{ServerName: "test", Count: 32, Concurrency: PositiveInt{Number: 32}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - import concurrency above max-conns with --strict",
+			args: &Args{
+				MaxConns: PositiveInt{Number: 8},
+				Strict:   true,
+				Keys:     &KeysCmd{Import: &ImportKeysCmd{ServerName: "test", File: "keys.json", Concurrency: PositiveInt{Number: 16}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid args - watch-limits",
+			args: &Args{
+				Keys: &KeysCmd{
+					Watch: &WatchLimitsCmd{ServerName: "test", Threshold: 90, Interval: time.Minute},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid args - watch-limits threshold above 100",
+			args: &Args{
+				Keys: &KeysCmd{
+					Watch: &WatchLimitsCmd{ServerName: "test", Threshold: 150, Interval: time.Minute},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid args - watch-limits interval below a second",
+			args: &Args{
+				Keys: &KeysCmd{
+					Watch: &WatchLimitsCmd{ServerName: "test", Threshold: 90, Interval: time.Millisecond},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateArgsReportsEveryError(t *testing.T) {
+	args := &Args{
+		Timeout: -1,
+		Output:  OutputFormat{Format: "csv"},
+		Keys: &KeysCmd{
+			Edit: &EditKeyCmd{ServerName: "test", KeyID: "1", KeyName: "alice", NamePrefix: "team-"},
+		},
+	}
+
+	err := validateArgs(args)
+	if err == nil {
+		t.Fatal("validateArgs() expected errors, got nil")
+	}
+
+	for _, want := range []string{
+		"--timeout and --dial-timeout cannot be negative",
+		"--output csv is only supported",
+		"--name-prefix can only be used with --cap",
+		"--name-prefix cannot be combined with --key-id or --key-name",
+		"at least one of --new-name",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("validateArgs() error %q does not report %q", err, want)
+		}
+	}
+}
+
+func TestRetryOnDefault(t *testing.T) {
+	var args Args
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		t.Fatalf("NewParser failed: %v", err)
+	}
+	if err := parser.Parse([]string{"servers", "list"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := args.RetryOn.Conditions(); !slices.Equal(got, []api.RetryCondition{api.RetryTooManyRequests}) {
+		t.Errorf("Expected --retry-on to default to 429, got %v", got)
+	}
+
+	if err := parser.Parse([]string{"--retry-on", "5xx,TIMEOUT", "servers", "list"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if args.RetryOn.String() != "5xx,timeout" {
+		t.Errorf("Expected the normalized list 5xx,timeout, got %q", args.RetryOn)
+	}
+
+	if err := parser.Parse([]string{"--retry-on", "5xx,teapot", "servers", "list"}); err == nil {
+		t.Error("Expected an unknown retry condition to be rejected")
+	}
+}
+
+func TestHostOverride_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    HostOverride
+		wantErr bool
+	}{
+		{"outline.example.com:203.0.113.7", HostOverride{"outline.example.com", "203.0.113.7"}, false},
+		{"Outline.Example.com:2001:db8::1", HostOverride{"outline.example.com", "2001:db8::1"}, false},
+		{"outline.example.com:[2001:db8::1]", HostOverride{"outline.example.com", "2001:db8::1"}, false},
+		{"outline.example.com", HostOverride{}, true},
+		{"outline.example.com:new-host", HostOverride{}, true},
+		{":203.0.113.7", HostOverride{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got HostOverride
+			err := got.UnmarshalText([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalText(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("UnmarshalText(%q) = %+v, want %+v", tt.input, got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// Build metadata, set with -ldflags "-X main.Version=... -X main.Commit=... -X main.BuildDate=..."
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+}
+
+func buildInfo() BuildInfo {
+	return BuildInfo{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		Commit:    Commit,
+		BuildDate: BuildDate,
+	}
+}
+
+func printVersion(asJSON bool) error {
+	info := buildInfo()
+	if !asJSON {
+		fmt.Printf("outline-cli version %s\n", info.Version)
+		return nil
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// userAgent returns the User-Agent for API requests: the --user-agent value, or the tool name
+// with its version
+func userAgent(override string) string {
+	if override != "" {
+		return override
+	}
+	return api.DefaultUserAgent + "/" + Version
+}
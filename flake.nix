@@ -32,7 +32,7 @@
           env.CGO_ENABLED = 0;
 
           ldflags = [
-            "-s -w -X main.Version=${version}"
+            "-s -w -X main.Version=${version} -X main.Commit=${self.shortRev or "dirty"}"
           ];
 
           meta = with pkgs.lib; {
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ShadowsocksConfig holds the connection details encoded in an ss:// access URL
+type ShadowsocksConfig struct {
+	Method   string `json:"method"`
+	Password string `json:"password"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Name     string `json:"name,omitempty"`
+}
+
+// ParseAccessURL decodes an ss:// URL in the SIP002 form Outline uses
+// (ss://base64(method:password)@host:port/#name) or the legacy form (ss://base64(method:password@host:port))
+func ParseAccessURL(accessURL string) (*ShadowsocksConfig, error) {
+	parsed, err := url.Parse(accessURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "ss" {
+		return nil, fmt.Errorf("not an ss:// URL")
+	}
+
+	config := &ShadowsocksConfig{Name: parsed.Fragment}
+
+	hostPort := parsed.Host
+	var userInfo string
+	if password, plain := parsed.User.Password(); plain {
+		// SIP002 allows plain method:password, used by Shadowsocks 2022 ciphers
+		userInfo = parsed.User.Username() + ":" + password
+	} else if parsed.User != nil {
+		decoded, err := decodeBase64(parsed.User.Username())
+		if err != nil {
+			return nil, fmt.Errorf("invalid user info: %w", err)
+		}
+		userInfo = decoded
+	} else {
+		decoded, err := decodeBase64(parsed.Host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid legacy URL: %w", err)
+		}
+		at := strings.LastIndex(decoded, "@")
+		if at < 0 {
+			return nil, fmt.Errorf("invalid legacy URL: missing host")
+		}
+		userInfo, hostPort = decoded[:at], decoded[at+1:]
+	}
+
+	method, password, found := strings.Cut(userInfo, ":")
+	if !found || method == "" {
+		return nil, fmt.Errorf("user info is not method:password")
+	}
+	config.Method, config.Password = method, password
+
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	config.Host = host
+	if config.Port, err = strconv.Atoi(port); err != nil {
+		return nil, fmt.Errorf("invalid port '%s'", port)
+	}
+
+	return config, nil
+}
+
+// decodeBase64 accepts standard and URL-safe base64, with or without padding
+func decodeBase64(encoded string) (string, error) {
+	if unescaped, err := url.PathUnescape(encoded); err == nil {
+		encoded = unescaped
+	}
+	encoded = strings.TrimRight(encoded, "=")
+
+	for _, encoding := range []*base64.Encoding{base64.RawURLEncoding, base64.RawStdEncoding} {
+		if decoded, err := encoding.DecodeString(encoded); err == nil {
+			return string(decoded), nil
+		}
+	}
+	return "", fmt.Errorf("not base64")
+}
+
+// fillFromAccessURL fills in method, password and port that older servers leave empty;
+// a malformed access URL leaves them as they are
+func (key *AccessKey) fillFromAccessURL() {
+	if key.Method != "" && key.Password != "" && key.Port != 0 {
+		return
+	}
+
+	config, err := ParseAccessURL(key.AccessURL)
+	if err != nil {
+		return
+	}
+
+	if key.Method == "" {
+		key.Method = config.Method
+	}
+	if key.Password == "" {
+		key.Password = config.Password
+	}
+	if key.Port == 0 {
+		key.Port = config.Port
+	}
+}
+
+// inviteBaseURL is the page the Outline client uses to share a key; the access URL goes in the fragment
+const inviteBaseURL = "https://s3.amazonaws.com/outline-vpn/invite.html#"
+
+// InviteLink wraps an access URL into the shareable invite link of the Outline client
+func InviteLink(accessURL string) string {
+	return inviteBaseURL + strings.ReplaceAll(url.QueryEscape(accessURL), "+", "%20")
+}
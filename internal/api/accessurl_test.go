@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestParseAccessURL(t *testing.T) {
+	userInfo := base64.RawURLEncoding.EncodeToString([]byte("chacha20-ietf-poly1305:secret"))
+	legacy := base64.StdEncoding.EncodeToString([]byte("aes-192-gcm:p@ss@203.0.113.5:443"))
+
+	tests := []struct {
+		name     string
+		input    string
+		expected ShadowsocksConfig
+		hasError bool
+	}{
+		{"SIP002", "ss://" + userInfo + "@203.0.113.5:12345/?outline=1#My%20Key", ShadowsocksConfig{"chacha20-ietf-poly1305", "secret", "203.0.113.5", 12345, "My Key"}, false},
+		{"SIP002 padded", "ss://" + base64.URLEncoding.EncodeToString([]byte("aes-256-gcm:pw")) + "@example.com:443", ShadowsocksConfig{"aes-256-gcm", "pw", "example.com", 443, ""}, false},
+		{"plain user info", "ss://2022-blake3-aes-128-gcm:a%2Bb%3D@[2001:db8::1]:8388", ShadowsocksConfig{"2022-blake3-aes-128-gcm", "a+b=", "2001:db8::1", 8388, ""}, false},
+		{"legacy", "ss://" + legacy, ShadowsocksConfig{"aes-192-gcm", "p@ss", "203.0.113.5", 443, ""}, false},
+
+		// Invalid inputs
+		{"wrong scheme", "https://example.com", ShadowsocksConfig{}, true},
+		{"not base64", "ss://%%%@example.com:443", ShadowsocksConfig{}, true},
+		{"missing port", "ss://" + userInfo + "@example.com", ShadowsocksConfig{}, true},
+		{"missing method", "ss://" + base64.RawURLEncoding.EncodeToString([]byte("secret")) + "@example.com:443", ShadowsocksConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseAccessURL(tt.input)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("ParseAccessURL(%q) expected error, got nil", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAccessURL(%q) unexpected error: %v", tt.input, err)
+			}
+			if *config != tt.expected {
+				t.Errorf("ParseAccessURL(%q) = %+v, want %+v", tt.input, *config, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFillFromAccessURL(t *testing.T) {
+	userInfo := base64.RawURLEncoding.EncodeToString([]byte("aes-192-gcm:secret"))
+
+	key := AccessKey{ID: "1", Port: 443, AccessURL: "ss://" + userInfo + "@203.0.113.5:12345"}
+	key.fillFromAccessURL()
+	if key.Method != "aes-192-gcm" || key.Password != "secret" {
+		t.Errorf("Expected method and password from the access URL, got %+v", key)
+	}
+	if key.Port != 443 {
+		t.Errorf("Expected port from the server to be kept, got %d", key.Port)
+	}
+
+	malformed := AccessKey{ID: "2", AccessURL: "ss://garbage"}
+	malformed.fillFromAccessURL()
+	if malformed.Method != "" || malformed.Password != "" || malformed.Port != 0 {
+		t.Errorf("Expected malformed URL to leave fields blank, got %+v", malformed)
+	}
+}
+
+func TestInviteLink(t *testing.T) {
+	link := InviteLink("ss://YWVzOnB3@203.0.113.5:443/?outline=1#My Key")
+	expected := "https://s3.amazonaws.com/outline-vpn/invite.html#ss%3A%2F%2FYWVzOnB3%40203.0.113.5%3A443%2F%3Foutline%3D1%23My%20Key"
+	if link != expected {
+		t.Errorf("InviteLink() = %s, want %s", link, expected)
+	}
+}
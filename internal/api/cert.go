@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// CertKind classifies the certificate a server presents
+type CertKind string
+
+const (
+	// CertCASigned chains to a trusted root, so standard TLS verification would work
+	CertCASigned CertKind = "ca-signed"
+	// CertSelfSigned is signed by its own key, as generated by the Outline installer; it can only be pinned
+	CertSelfSigned CertKind = "self-signed"
+	// CertUntrusted is issued by a CA that is not in the trusted roots
+	CertUntrusted CertKind = "untrusted-ca"
+)
+
+// CertInfo describes the leaf certificate a server presents
+type CertInfo struct {
+	SHA256    string    `json:"sha256"`
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+	Kind      CertKind  `json:"kind"`
+	HostMatch bool      `json:"hostMatch"`
+}
+
+// InspectCertificate connects to the server without verifying it and classifies the presented
+// certificate against the system roots. Of opts, only the dial timeout, ServerName and Resolve
+// apply.
+func InspectCertificate(serverURL string, opts ClientOptions) (*CertInfo, error) {
+	dialTimeout := opts.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	rawConn, parsed, err := dialServer(ctx, serverURL, opts)
+	if err != nil {
+		slog.Error("failed to connect for certificate inspection", "error", err)
+		return nil, err
+	}
+
+	host := parsed.Hostname()
+	if opts.ServerName != "" {
+		host = opts.ServerName
+	}
+	// The SNI is set explicitly because addr may have been resolved to an IP
+	conn := tls.Client(rawConn, &tls.Config{InsecureSkipVerify: true, ServerName: host})
+	defer conn.Close()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		slog.Error("failed to connect for certificate inspection", "error", err)
+		return nil, err
+	}
+
+	chain := conn.ConnectionState().PeerCertificates
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificates provided")
+	}
+
+	return classifyCertificate(chain, host, nil), nil
+}
+
+// classifyCertificate inspects the leaf of chain; nil roots means the system pool
+func classifyCertificate(chain []*x509.Certificate, host string, roots *x509.CertPool) *CertInfo {
+	leaf := chain[0]
+	hash := sha256.Sum256(leaf.Raw)
+
+	info := &CertInfo{
+		SHA256:    strings.ToUpper(hex.EncodeToString(hash[:])),
+		Subject:   leaf.Subject.String(),
+		Issuer:    leaf.Issuer.String(),
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+		HostMatch: leaf.VerifyHostname(host) == nil,
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	// Hostname and validity period are reported separately, so a CA-signed cert reached by IP
+	// or outside its validity period is still classified by its chain
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: intermediates}
+	switch now := time.Now(); {
+	case now.After(leaf.NotAfter):
+		opts.CurrentTime = leaf.NotAfter
+	case now.Before(leaf.NotBefore):
+		opts.CurrentTime = leaf.NotBefore
+	}
+
+	_, err := leaf.Verify(opts)
+	switch {
+	case err == nil:
+		info.Kind = CertCASigned
+	case bytes.Equal(leaf.RawIssuer, leaf.RawSubject) && leaf.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature) == nil:
+		info.Kind = CertSelfSigned
+	default:
+		slog.Debug("certificate does not chain to a trusted root", "error", err)
+		info.Kind = CertUntrusted
+	}
+
+	return info
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestCert issues a certificate for 127.0.0.1, self-signed when parent is nil
+func newTestCert(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func TestClassifyCertificate(t *testing.T) {
+	ca, caKey := newTestCert(t, "Test CA", true, nil, nil)
+	leaf, _ := newTestCert(t, "server", false, ca, caKey)
+	selfSigned, _ := newTestCert(t, "outline", false, nil, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	tests := []struct {
+		name      string
+		chain     []*x509.Certificate
+		host      string
+		roots     *x509.CertPool
+		expected  CertKind
+		hostMatch bool
+	}{
+		{"CA-signed", []*x509.Certificate{leaf}, "127.0.0.1", roots, CertCASigned, true},
+		{"CA-signed reached by other name", []*x509.Certificate{leaf}, "example.com", roots, CertCASigned, false},
+		{"self-signed", []*x509.Certificate{selfSigned}, "127.0.0.1", roots, CertSelfSigned, true},
+		{"unknown CA", []*x509.Certificate{leaf}, "127.0.0.1", x509.NewCertPool(), CertUntrusted, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := classifyCertificate(tt.chain, tt.host, tt.roots)
+			if info.Kind != tt.expected {
+				t.Errorf("Expected kind %s, got %s", tt.expected, info.Kind)
+			}
+			if info.HostMatch != tt.hostMatch {
+				t.Errorf("Expected host match %v, got %v", tt.hostMatch, info.HostMatch)
+			}
+		})
+	}
+}
+
+func TestInspectCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	info, err := InspectCertificate(server.URL, ClientOptions{})
+	if err != nil {
+		t.Fatalf("InspectCertificate failed: %v", err)
+	}
+
+	if info.Kind != CertSelfSigned {
+		t.Errorf("Expected httptest certificate to be self-signed, got %s", info.Kind)
+	}
+	if len(info.SHA256) != 64 {
+		t.Errorf("Expected a SHA256 fingerprint, got %q", info.SHA256)
+	}
+}
@@ -1,10 +1,20 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewAPIClient(t *testing.T) {
@@ -24,6 +34,112 @@ func TestNewAPIClient(t *testing.T) {
 	}
 }
 
+func TestEndpointURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverURL string
+		prefix    string
+		elem      []string
+		expected  string
+	}{
+		{"no prefix", "https://example.com:8443/Secret", "", []string{"server"}, "https://example.com:8443/Secret/server"},
+		{"trailing slash on URL", "https://example.com:8443/Secret/", "", []string{"access-keys"}, "https://example.com:8443/Secret/access-keys"},
+		{"prefix", "https://example.com:8443/Secret", "outline/api", []string{"server"}, "https://example.com:8443/Secret/outline/api/server"},
+		{"prefix with slashes", "https://example.com:8443/Secret/", "/outline/api/", []string{"server"}, "https://example.com:8443/Secret/outline/api/server"},
+		{"prefix and key endpoint", "https://example.com:8443/Secret", "outline/api", []string{"access-keys", "7", "name"}, "https://example.com:8443/Secret/outline/api/access-keys/7/name"},
+		{"multi-segment endpoint", "https://example.com:8443/Secret", "/api", []string{"metrics/transfer"}, "https://example.com:8443/Secret/api/metrics/transfer"},
+		{"URL without path", "https://example.com:8443", "api", []string{"server"}, "https://example.com:8443/api/server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewAPIClientWithOptions("dummy-cert-sha256", ClientOptions{EndpointPrefix: tt.prefix})
+			got, err := client.endpointURL(tt.serverURL, tt.elem...)
+			if err != nil {
+				t.Fatalf("endpointURL() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("endpointURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKeyEndpointsEscapeIDs(t *testing.T) {
+	tests := []struct {
+		keyID   string
+		wantURI string
+	}{
+		{"7", "/Secret/access-keys/7/name"},
+		{"a/b", "/Secret/access-keys/a%2Fb/name"},
+		{"key 1", "/Secret/access-keys/key%201/name"},
+		{"50%", "/Secret/access-keys/50%25/name"},
+		{"what?#", "/Secret/access-keys/what%3F%23/name"},
+		{"..", "/Secret/access-keys/%2E%2E/name"},
+		{"ключ", "/Secret/access-keys/%D0%BA%D0%BB%D1%8E%D1%87/name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyID, func(t *testing.T) {
+			var gotURI string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotURI = r.RequestURI
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			client := NewAPIClient("dummy-cert-sha256")
+			// A trailing slash on the stored URL must not produce an empty path segment
+			if err := client.RenameAccessKey(server.URL+"/Secret/", tt.keyID, "new"); err != nil {
+				t.Fatalf("RenameAccessKey() error = %v", err)
+			}
+			if gotURI != tt.wantURI {
+				t.Errorf("request URI = %q, want %q", gotURI, tt.wantURI)
+			}
+		})
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{"default", "", DefaultUserAgent},
+		{"custom", "outline-cli/1.4.0", "outline-cli/1.4.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = append(got, r.UserAgent())
+				if r.Method == http.MethodDelete {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				w.Write([]byte(`{"name": "test"}`))
+			}))
+			defer server.Close()
+
+			client := NewAPIClientWithOptions("dummy-cert-sha256", ClientOptions{UserAgent: tt.userAgent})
+			if _, err := client.GetServerInfo(server.URL); err != nil {
+				t.Fatalf("GetServerInfo failed: %v", err)
+			}
+			if err := client.DeleteAccessKey(server.URL, "1"); err != nil {
+				t.Fatalf("DeleteAccessKey failed: %v", err)
+			}
+
+			for _, userAgent := range got {
+				if userAgent != tt.expected {
+					t.Errorf("Expected User-Agent %q, got %q", tt.expected, userAgent)
+				}
+			}
+		})
+	}
+}
+
 func TestGetServerInfo(t *testing.T) {
 	// Create a test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -199,6 +315,111 @@ func TestDeleteAccessKey(t *testing.T) {
 	}
 }
 
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"json error body", `{"code":"Conflict","message":"port 8443 is already in use."}`, "server rejected request: port 8443 is already in use."},
+		{"json without message", `{"code":"Conflict"}`, `server returned status 409: {"code":"Conflict"}`},
+		{"plain text body", "conflict\n", "server returned status 409: conflict"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := NewAPIClient("dummy-cert-sha256")
+			_, err := client.CreateAccessKey(server.URL, CreateAccessKeyRequest{})
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("Expected error %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+}
+
+func TestCreateAccessKeyPortConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"code":"Conflict","message":"The requested port was already in use by another service."}`))
+	}))
+	defer server.Close()
+
+	client := NewAPIClient("dummy-cert-sha256")
+	key, err := client.CreateAccessKey(server.URL, CreateAccessKeyRequest{Name: "alice", Port: 8443})
+	if err == nil {
+		t.Fatalf("Expected an error, got key %+v", key)
+	}
+	if want := "port 8443 is already in use on this server"; err.Error() != want {
+		t.Errorf("Expected error %q, got %q", want, err.Error())
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "test", "version": "1.9.0", "futureField": true}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		strict   bool
+		hasError bool
+	}{
+		{"tolerant by default", false, false},
+		{"strict rejects unknown fields", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewAPIClientWithOptions("dummy-cert-sha256", ClientOptions{StrictJSON: tt.strict})
+			_, err := client.GetServerInfo(server.URL)
+			if (err != nil) != tt.hasError {
+				t.Errorf("GetServerInfo() error = %v, hasError %v", err, tt.hasError)
+			}
+			if tt.hasError && err != nil && !strings.Contains(err.Error(), "futureField") {
+				t.Errorf("Expected error to name the unknown field, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRenameServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/name" {
+			t.Errorf("Expected path /name, got %s", r.URL.Path)
+		}
+
+		if r.Method != "PUT" {
+			t.Errorf("Expected PUT method, got %s", r.Method)
+		}
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["name"] != "New Name" {
+			t.Errorf("Expected name 'New Name', got %q", body["name"])
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewAPIClient("dummy-cert-sha256")
+	err := client.RenameServer(server.URL, "New Name")
+
+	if err != nil {
+		t.Fatalf("RenameServer failed: %v", err)
+	}
+}
+
 func TestGetTransferMetrics(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/metrics/transfer" {
@@ -253,3 +474,265 @@ func TestRemoveAccessKeyDataLimit(t *testing.T) {
 		t.Fatalf("RemoveAccessKeyDataLimit failed: %v", err)
 	}
 }
+
+func TestGetServerInfoErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewAPIClient("dummy-cert-sha256")
+	serverInfo, err := client.GetServerInfo(server.URL)
+
+	if err == nil {
+		t.Fatal("Expected error for non-200 status, got nil")
+	}
+
+	if serverInfo != nil {
+		t.Errorf("Expected nil server info, got %+v", serverInfo)
+	}
+}
+
+func TestNewAPIClientWithOptions(t *testing.T) {
+	client := NewAPIClientWithOptions("dummy-cert-sha256", ClientOptions{MaxConns: 2, DisableKeepAlives: true})
+
+	transport, ok := client.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("Expected *http.Transport")
+	}
+
+	if transport.MaxConnsPerHost != 2 || transport.MaxIdleConnsPerHost != 2 {
+		t.Errorf("Expected connection limits of 2, got %d/%d", transport.MaxConnsPerHost, transport.MaxIdleConnsPerHost)
+	}
+
+	if !transport.DisableKeepAlives {
+		t.Error("Expected keep-alives to be disabled")
+	}
+
+	if client.client.Timeout != DefaultTimeout {
+		t.Errorf("Expected default timeout, got %v", client.client.Timeout)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewAPIClientWithOptions("dummy-cert-sha256", ClientOptions{Timeout: 50 * time.Millisecond, DialTimeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := client.GetServerInfo(server.URL); err == nil {
+		t.Fatal("Expected timeout error, got nil")
+	}
+
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Expected request to give up after the overall timeout, took %v", elapsed)
+	}
+}
+
+func TestClientDialTimeout(t *testing.T) {
+	client := NewAPIClientWithOptions("dummy-cert-sha256", ClientOptions{Timeout: time.Second, DialTimeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	// 192.0.2.0/24 is reserved for documentation and never routed
+	if _, err := client.GetServerInfo("http://192.0.2.1:81"); err == nil {
+		t.Fatal("Expected dial error, got nil")
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected dial to give up after the dial timeout, took %v", elapsed)
+	}
+}
+
+func TestGetRaw(t *testing.T) {
+	const body = `{"name":"Test Server","unmodeledField":true}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/server" {
+			t.Errorf("Expected path /server, got %s", r.URL.Path)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := NewAPIClient("dummy-cert-sha256")
+	raw, err := client.GetRaw(server.URL, "server")
+
+	if err != nil {
+		t.Fatalf("GetRaw failed: %v", err)
+	}
+
+	if string(raw) != body {
+		t.Errorf("Expected body %s, got %s", body, raw)
+	}
+
+	if _, err := client.GetRaw(server.URL, "access-keys/1/name"); err == nil {
+		t.Error("Expected error for endpoint outside the allowlist")
+	}
+}
+
+func TestGetRawLogsEndpointName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serverURL := server.URL + "/Secret"
+	server.Close()
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	if _, err := NewAPIClient("dummy-cert-sha256").GetRaw(serverURL, "server"); err == nil {
+		t.Fatal("Expected an error for an unreachable server")
+	}
+	if !strings.Contains(logs.String(), "endpoint=server ") {
+		t.Errorf("Expected the endpoint name to be logged, got %q", logs.String())
+	}
+}
+
+func TestCertMismatchError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := NewAPIClient("abcd")
+	_, err := client.GetServerInfo(server.URL)
+
+	var mismatch *CertMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a CertMismatchError, got %v", err)
+	}
+
+	hash := sha256.Sum256(server.Certificate().Raw)
+	if mismatch.Expected != "ABCD" || mismatch.Got != strings.ToUpper(hex.EncodeToString(hash[:])) {
+		t.Errorf("Unexpected fingerprints in %+v", mismatch)
+	}
+}
+
+func TestSkipCertCheck(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OutlineServer{Name: "Test Server"})
+	}))
+	defer server.Close()
+
+	client := NewAPIClientWithOptions("abcd", ClientOptions{SkipCertCheck: true})
+	info, err := client.GetServerInfo(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the unpinned certificate to be accepted, got %v", err)
+	}
+	if info.Name != "Test Server" {
+		t.Errorf("Expected server name 'Test Server', got %q", info.Name)
+	}
+}
+
+func TestServerName(t *testing.T) {
+	var mu sync.Mutex
+	var sentName string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OutlineServer{Name: "Test Server"})
+	}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			sentName = hello.ServerName
+			mu.Unlock()
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	hash := sha256.Sum256(server.Certificate().Raw)
+	certSha256 := hex.EncodeToString(hash[:])
+
+	tests := []struct {
+		name       string
+		serverName string
+		want       string
+	}{
+		// The test server listens on an IP, which is never sent as SNI
+		{"URL host", "", ""},
+		{"override", "outline.example.com", "outline.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewAPIClientWithOptions(certSha256, ClientOptions{ServerName: tt.serverName, DisableKeepAlives: true})
+			if _, err := client.GetServerInfo(server.URL); err != nil {
+				t.Fatalf("GetServerInfo failed: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if sentName != tt.want {
+				t.Errorf("Expected SNI %q, got %q", tt.want, sentName)
+			}
+		})
+	}
+}
+
+func TestGzipResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Like a proxy, compress only when the client says it accepts gzip
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("Expected the request to accept gzip, got Accept-Encoding %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Type", "application/json")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		switch r.URL.Path {
+		case "/server":
+			json.NewEncoder(gz).Encode(OutlineServer{Name: "Gzipped", Version: "1.9.0"})
+		case "/access-keys":
+			json.NewEncoder(gz).Encode(AccessKeysResponse{AccessKeys: []AccessKey{{ID: "1", Name: "alice"}}})
+		}
+	}))
+	defer server.Close()
+
+	client := NewAPIClientWithOptions("dummy", ClientOptions{})
+	serverInfo, err := client.GetServerInfo(server.URL)
+	if err != nil {
+		t.Fatalf("GetServerInfo failed on a gzipped response: %v", err)
+	}
+	if serverInfo.Name != "Gzipped" {
+		t.Errorf("Expected the decompressed server info, got %+v", serverInfo)
+	}
+
+	keys, err := client.ListAccessKeys(server.URL)
+	if err != nil || len(keys) != 1 || keys[0].Name != "alice" {
+		t.Errorf("Expected the decompressed key list, got %+v, %v", keys, err)
+	}
+
+	// servers raw prints the body as it is, so it must be decompressed as well
+	body, err := client.GetRaw(server.URL, "server")
+	if err != nil || !json.Valid(body) {
+		t.Errorf("Expected a decompressed raw body, got %q, %v", body, err)
+	}
+}
+
+func TestPinnedCertIgnoresHostname(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OutlineServer{Name: "Test Server"})
+	}))
+	defer server.Close()
+
+	// The test cert is only valid for example.com and the loopback IPs, so standard
+	// verification would reject it for localhost
+	if err := server.Certificate().VerifyHostname("localhost"); err == nil {
+		t.Fatal("Expected the test cert not to be valid for localhost")
+	}
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	hash := sha256.Sum256(server.Certificate().Raw)
+	client := NewAPIClientWithOptions(hex.EncodeToString(hash[:]), ClientOptions{DisableKeepAlives: true})
+	if _, err := client.GetServerInfo(url); err != nil {
+		t.Errorf("Expected the pinned cert to be accepted for a host it is not valid for, got %v", err)
+	}
+
+	client = NewAPIClientWithOptions(strings.Repeat("AB", 32), ClientOptions{DisableKeepAlives: true})
+	var mismatch *CertMismatchError
+	if _, err := client.GetServerInfo(url); !errors.As(err, &mismatch) {
+		t.Errorf("Expected a CertMismatchError for a wrong pin, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// dialServer opens a TCP connection to the host of a management API URL, honoring opts.Resolve.
+// The port defaults to 443 when the URL has none.
+func dialServer(ctx context.Context, serverURL string, opts ClientOptions) (net.Conn, *url.URL, error) {
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	addr := parsed.Host
+	if parsed.Port() == "" {
+		addr = net.JoinHostPort(parsed.Hostname(), "443")
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := resolveDial(dialer.DialContext, opts.Resolve)(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, parsed, nil
+}
+
+// ProbeServer reports whether the server accepts a TCP connection within the dial timeout of
+// opts. It does not speak TLS or HTTP, so it is much cheaper than a request, but a server that
+// accepts connections may still fail to answer one.
+func ProbeServer(serverURL string, opts ClientOptions) error {
+	dialTimeout := opts.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	conn, _, err := dialServer(ctx, serverURL, opts)
+	if err != nil {
+		slog.Debug("server did not accept a connection", "error", err)
+		return err
+	}
+	return conn.Close()
+}
+
+// ProbeAddress reports whether host and port accept a TCP connection within the dial timeout of
+// opts, like ProbeServer but for an address other than the management API, such as the port
+// access keys connect to
+func ProbeAddress(host string, port int, opts ClientOptions) error {
+	dialTimeout := opts.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := resolveDial(dialer.DialContext, opts.Resolve)(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		slog.Debug("address did not accept a connection", "host", host, "port", port, "error", err)
+		return err
+	}
+	return conn.Close()
+}
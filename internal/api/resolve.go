@@ -0,0 +1,29 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+)
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// resolveDial wraps dial so that a host listed in resolve is connected to at the given IP
+// instead of the address DNS returns. Only the TCP destination changes: the URL's host is still
+// sent as SNI and in the Host header, and the certificate is still checked against its pin.
+func resolveDial(dial dialFunc, resolve map[string]string) dialFunc {
+	if len(resolve) == 0 {
+		return dial
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err == nil {
+			if ip, ok := resolve[strings.ToLower(host)]; ok {
+				slog.Debug("resolving host to the configured address", "host", host, "ip", ip)
+				addr = net.JoinHostPort(ip, port)
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	var mu sync.Mutex
+	var sentName, sentHost string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sentHost = r.Host
+		mu.Unlock()
+		json.NewEncoder(w).Encode(OutlineServer{Name: "Test Server"})
+	}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			sentName = hello.ServerName
+			mu.Unlock()
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	hash := sha256.Sum256(server.Certificate().Raw)
+	certSha256 := hex.EncodeToString(hash[:])
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	// The .invalid TLD never resolves, so the request can only reach the server through the override
+	serverURL := "https://outline.invalid:" + port
+
+	client := NewAPIClientWithOptions(certSha256, ClientOptions{Resolve: map[string]string{"outline.invalid": "127.0.0.1"}})
+	info, err := client.GetServerInfo(serverURL)
+	if err != nil {
+		t.Fatalf("GetServerInfo through the override failed: %v", err)
+	}
+	if info.Name != "Test Server" {
+		t.Errorf("Unexpected server info %+v", info)
+	}
+
+	mu.Lock()
+	if sentName != "outline.invalid" || sentHost != "outline.invalid:"+port {
+		t.Errorf("Expected the URL's host as SNI and Host header, got %q and %q", sentName, sentHost)
+	}
+	mu.Unlock()
+
+	// The pin is still checked at the overridden address
+	wrongPin := NewAPIClientWithOptions("00", ClientOptions{Resolve: map[string]string{"outline.invalid": "127.0.0.1"}})
+	if _, err := wrongPin.GetServerInfo(serverURL); err == nil {
+		t.Error("Expected a certificate mismatch through the override")
+	}
+
+	cert, err := InspectCertificate(serverURL, ClientOptions{Resolve: map[string]string{"outline.invalid": "127.0.0.1"}})
+	if err != nil {
+		t.Fatalf("InspectCertificate through the override failed: %v", err)
+	}
+	if cert.SHA256 == "" {
+		t.Error("Expected the certificate of the overridden address")
+	}
+}
+
+func TestResolveDial(t *testing.T) {
+	var dialed string
+	dial := resolveDial(func(_ context.Context, _, addr string) (net.Conn, error) {
+		dialed = addr
+		return nil, nil
+	}, map[string]string{"outline.example.com": "2001:db8::1"})
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"outline.example.com:443", "[2001:db8::1]:443"},
+		{"OUTLINE.example.com:8443", "[2001:db8::1]:8443"},
+		{"other.example.com:443", "other.example.com:443"},
+	}
+	for _, tt := range tests {
+		dial(context.Background(), "tcp", tt.addr)
+		if dialed != tt.want {
+			t.Errorf("Dialing %s connected to %s, want %s", tt.addr, dialed, tt.want)
+		}
+	}
+}
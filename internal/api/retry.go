@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultMaxRetryWait caps how long a failed request waits before it is sent again
+const DefaultMaxRetryWait = time.Minute
+
+// RetryCondition is a kind of failure after which a request may be sent again
+type RetryCondition string
+
+const (
+	RetryTooManyRequests RetryCondition = "429"
+	RetryServerError     RetryCondition = "5xx"
+	RetryTimeout         RetryCondition = "timeout"
+	RetryConnReset       RetryCondition = "connreset"
+)
+
+// RetryConditions lists every condition in the order they are documented
+var RetryConditions = []RetryCondition{RetryTooManyRequests, RetryServerError, RetryTimeout, RetryConnReset}
+
+// DefaultRetryOn only retries rate-limited requests, which the server has not acted on
+var DefaultRetryOn = []RetryCondition{RetryTooManyRequests}
+
+// ParseRetryConditions parses a comma-separated list of retry conditions such as "5xx,timeout"
+func ParseRetryConditions(s string) ([]RetryCondition, error) {
+	var conditions []RetryCondition
+	for token := range strings.SplitSeq(s, ",") {
+		condition := RetryCondition(strings.ToLower(strings.TrimSpace(token)))
+		if !slices.Contains(RetryConditions, condition) {
+			return nil, fmt.Errorf("unknown retry condition '%s' (valid conditions are 429, 5xx, timeout and connreset)", strings.TrimSpace(token))
+		}
+		if !slices.Contains(conditions, condition) {
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions, nil
+}
+
+// RateLimitedError is returned for a 429 response that was not, or no longer, retried
+type RateLimitedError struct {
+	// RetryAfter is how long the server asked to wait; zero when it did not say
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("server is rate limiting requests (asked to retry after %s)", e.RetryAfter)
+	}
+	return "server is rate limiting requests"
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date; zero means
+// the header is missing or invalid
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
+
+// retryWait is the Retry-After of a failed response, or an exponential backoff starting at one
+// second when the server did not say or did not answer, capped at maxWait
+func retryWait(resp *http.Response, attempt int, maxWait time.Duration, now time.Time) time.Duration {
+	var wait time.Duration
+	if resp != nil {
+		wait = parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	}
+	if wait == 0 {
+		wait = time.Second << min(attempt, 10)
+	}
+	return min(wait, maxWait)
+}
+
+// do sends a request with the client's User-Agent and sends it again, up to api.retries times,
+// while it fails in a way listed in api.retryOn. A request whose body cannot be replayed is
+// not retried, and neither is one whose next attempt would start after api.retryBudget.
+func (api *APIClient) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", api.userAgent)
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		resp, err := api.client.Do(req)
+		condition := api.retryCondition(req, resp, err)
+		if condition == "" || attempt >= api.retries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		wait := retryWait(resp, attempt, api.maxRetryWait, time.Now())
+		if api.retryBudget > 0 && time.Since(start)+wait > api.retryBudget {
+			slog.Warn("retry budget exhausted, giving up", "reason", condition, "error", err, "budget", api.retryBudget, "retries", attempt)
+			return resp, err
+		}
+		if resp != nil {
+			closeResponseBody(resp)
+		}
+		slog.Warn("request failed, waiting before retrying", "reason", condition, "error", err, "wait", wait, "retry", attempt+1, "of", api.retries)
+		if err := sleepContext(req.Context(), wait); err != nil {
+			return nil, err
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// retryCondition is the condition a failed request matches, or empty when it did not fail or
+// the condition is not retried. Only 429 is retried for requests that are not idempotent, since
+// the server may already have acted on a request that timed out or failed with a 5xx.
+func (api *APIClient) retryCondition(req *http.Request, resp *http.Response, err error) RetryCondition {
+	var condition RetryCondition
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// Cancelled by the caller, not by the network
+		return ""
+	case err != nil && isTimeout(err):
+		condition = RetryTimeout
+	case err != nil && isConnReset(err):
+		condition = RetryConnReset
+	case err != nil:
+		return ""
+	case resp.StatusCode == http.StatusTooManyRequests:
+		condition = RetryTooManyRequests
+	case resp.StatusCode >= 500 && resp.StatusCode <= 599:
+		condition = RetryServerError
+	default:
+		return ""
+	}
+
+	if !slices.Contains(api.retryOn, condition) {
+		return ""
+	}
+	if condition != RetryTooManyRequests && !isIdempotent(req.Method) {
+		return ""
+	}
+	return condition
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func isConnReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{"missing", "", 0},
+		{"seconds", "30", 30 * time.Second},
+		{"negative seconds", "-5", 0},
+		{"HTTP date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"invalid", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	if got := retryWait(resp, 2, time.Minute, time.Now()); got != 4*time.Second {
+		t.Errorf("Expected a 4s backoff on the third attempt without Retry-After, got %v", got)
+	}
+
+	resp.Header.Set("Retry-After", "3600")
+	if got := retryWait(resp, 0, time.Minute, time.Now()); got != time.Minute {
+		t.Errorf("Expected the wait to be capped at 1m, got %v", got)
+	}
+}
+
+func TestRetryRateLimited(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		wantRequests int
+		wantErr      bool
+	}{
+		{"retries disabled", 0, 1, true},
+		{"too few retries", 1, 2, true},
+		{"enough retries", 2, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				request := requests.Add(1)
+				// The request body has to be sent again on every retry
+				var req CreateAccessKeyRequest
+				body, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(body, &req); err != nil || req.Name != "alice" {
+					t.Errorf("Request %d has body %q", request, body)
+				}
+
+				if request <= 2 {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(AccessKey{ID: "1", Name: "alice"})
+			}))
+			defer server.Close()
+
+			client := NewAPIClientWithOptions("dummy", ClientOptions{Retries: tt.retries, MaxRetryWait: time.Millisecond})
+			_, err := client.CreateAccessKey(server.URL, CreateAccessKeyRequest{Name: "alice"})
+
+			if got := int(requests.Load()); got != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, got)
+			}
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Expected the retry to succeed, got %v", err)
+				}
+				return
+			}
+
+			var rateLimited *RateLimitedError
+			if !errors.As(err, &rateLimited) {
+				t.Fatalf("Expected a RateLimitedError, got %v", err)
+			}
+			if rateLimited.RetryAfter != time.Second {
+				t.Errorf("Expected the server's Retry-After of 1s, got %v", rateLimited.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestParseRetryConditions(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []RetryCondition
+		wantErr bool
+	}{
+		{"429", []RetryCondition{RetryTooManyRequests}, false},
+		{"5xx, Timeout,connreset", []RetryCondition{RetryServerError, RetryTimeout, RetryConnReset}, false},
+		{"5xx,5xx", []RetryCondition{RetryServerError}, false},
+		{"", nil, true},
+		{"500", nil, true},
+		{"5xx,", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRetryConditions(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRetryConditions(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseRetryConditions(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryOn(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryOn      []RetryCondition
+		failure      string
+		create       bool
+		wantRequests int
+	}{
+		{"5xx not retried by default", nil, "5xx", false, 1},
+		{"5xx retried when selected", []RetryCondition{RetryServerError}, "5xx", false, 2},
+		{"5xx not retried for a create", []RetryCondition{RetryServerError}, "5xx", true, 1},
+		{"connreset retried when selected", []RetryCondition{RetryConnReset}, "connreset", false, 2},
+		{"connreset not retried for 5xx only", []RetryCondition{RetryServerError}, "connreset", false, 1},
+		{"timeout retried when selected", []RetryCondition{RetryTimeout}, "timeout", false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) == 1 {
+					switch tt.failure {
+					case "5xx":
+						w.WriteHeader(http.StatusBadGateway)
+					case "connreset":
+						conn, _, _ := w.(http.Hijacker).Hijack()
+						conn.Close()
+					case "timeout":
+						// Held until the client gives up on the request
+						<-r.Context().Done()
+					}
+					return
+				}
+				if tt.create {
+					w.WriteHeader(http.StatusCreated)
+					json.NewEncoder(w).Encode(AccessKey{ID: "1"})
+					return
+				}
+				json.NewEncoder(w).Encode(AccessKeysResponse{})
+			}))
+			defer server.Close()
+
+			client := NewAPIClientWithOptions("dummy", ClientOptions{
+				Retries:      1,
+				MaxRetryWait: time.Millisecond,
+				RetryOn:      tt.retryOn,
+				Timeout:      50 * time.Millisecond,
+			})
+			var err error
+			if tt.create {
+				_, err = client.CreateAccessKey(server.URL, CreateAccessKeyRequest{Name: "alice"})
+			} else {
+				_, err = client.ListAccessKeys(server.URL)
+			}
+
+			if got := int(requests.Load()); got != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, got)
+			}
+			if (err == nil) != (tt.wantRequests == 2) {
+				t.Errorf("Expected the request to succeed only after a retry, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// Every retry waits a second, so only one fits in the budget although many are allowed
+	client := NewAPIClientWithOptions("dummy", ClientOptions{
+		Retries:     100,
+		RetryOn:     []RetryCondition{RetryServerError},
+		RetryBudget: 1500 * time.Millisecond,
+	})
+	start := time.Now()
+	_, err := client.ListAccessKeys(server.URL)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("Expected the request to fail once the budget is exhausted")
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected 2 requests within the budget, got %d", got)
+	}
+	if elapsed > 1500*time.Millisecond {
+		t.Errorf("Expected the request to give up within the 1.5s budget, took %v", elapsed)
+	}
+}
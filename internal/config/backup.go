@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat sorts by time when compared as a string
+const backupTimeFormat = "20060102-150405.000000000"
+
+// ConfigBackup is a copy of the config file taken before it was changed
+type ConfigBackup struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Time    time.Time `json:"time"`
+	Size    int64     `json:"size"`
+	Current bool      `json:"current"`
+}
+
+// BackupDir is where backups are kept: Options.BackupDir, or a backups directory next to the
+// config file
+func (cm *ConfigManager) BackupDir() string {
+	if cm.options.BackupDir != "" {
+		return cm.options.BackupDir
+	}
+	return filepath.Join(filepath.Dir(cm.configPath), "backups")
+}
+
+// backupPrefix starts the name of every backup of the active profile, e.g. "config-"
+func (cm *ConfigManager) backupPrefix() string {
+	return strings.TrimSuffix(filepath.Base(cm.configPath), ".yaml") + "-"
+}
+
+// backupConfig saves previous, the config as it was before a change, as a timestamped backup and
+// prunes the oldest backups beyond Options.BackupCount. Nothing is kept when BackupCount is zero.
+func (cm *ConfigManager) backupConfig(previous []byte) error {
+	if cm.options.BackupCount <= 0 {
+		return nil
+	}
+
+	dir := cm.BackupDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		slog.Error("failed to create backup directory", "error", err)
+		return err
+	}
+
+	name := cm.backupPrefix() + time.Now().UTC().Format(backupTimeFormat) + ".yaml"
+	// Backups hold the server URLs just like the config file
+	if err := os.WriteFile(filepath.Join(dir, name), previous, configFileMode); err != nil {
+		slog.Error("failed to write config backup", "error", err)
+		return err
+	}
+	slog.Debug("backed up config", "path", filepath.Join(dir, name))
+
+	backups, err := cm.ListConfigBackups()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups[min(cm.options.BackupCount, len(backups)):] {
+		if err := os.Remove(backup.Path); err != nil {
+			slog.Warn("failed to prune old config backup", "path", backup.Path, "error", err)
+		}
+	}
+	return nil
+}
+
+// ListConfigBackups returns the backups of the active profile's config, newest first. Current
+// marks a backup with the same content as the config file.
+func (cm *ConfigManager) ListConfigBackups() ([]ConfigBackup, error) {
+	entries, err := os.ReadDir(cm.BackupDir())
+	if os.IsNotExist(err) {
+		return []ConfigBackup{}, nil
+	}
+	if err != nil {
+		slog.Error("failed to read backup directory", "error", err)
+		return nil, err
+	}
+
+	current, _ := os.ReadFile(cm.configPath)
+	prefix := cm.backupPrefix()
+	backups := []ConfigBackup{}
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		// The prefix of one profile can start the name of another, e.g. "work-" and "work-eu-"
+		taken, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ".yaml"))
+		if err != nil {
+			continue
+		}
+
+		backup := ConfigBackup{Name: entry.Name(), Path: filepath.Join(cm.BackupDir(), entry.Name()), Time: taken}
+		if data, err := os.ReadFile(backup.Path); err == nil {
+			backup.Size = int64(len(data))
+			backup.Current = string(data) == string(current)
+		}
+		backups = append(backups, backup)
+	}
+
+	slices.SortFunc(backups, func(a, b ConfigBackup) int {
+		return strings.Compare(b.Name, a.Name)
+	})
+	return backups, nil
+}
+
+// RestoreConfigBackup replaces the config file with the named backup after confirmation. The
+// backup must be a valid config; the config it replaces is backed up first, so a restore can
+// itself be undone.
+func (cm *ConfigManager) RestoreConfigBackup(name string) (*ConfigBackup, error) {
+	backups, err := cm.ListConfigBackups()
+	if err != nil {
+		return nil, err
+	}
+	index := slices.IndexFunc(backups, func(backup ConfigBackup) bool { return backup.Name == name })
+	if index < 0 {
+		slog.Error("config backup not found", "name", name, "dir", cm.BackupDir())
+		return nil, fmt.Errorf("backup '%s' not found in %s", name, cm.BackupDir())
+	}
+	backup := backups[index]
+
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		slog.Error("failed to read config backup", "error", err)
+		return nil, err
+	}
+	config, err := parseConfig(data)
+	if err == nil {
+		err = cm.expandServerEnv(config)
+	}
+	if err == nil {
+		err = validateConfig(config)
+	}
+	if err != nil {
+		slog.Error("config backup is invalid", "name", name, "error", err)
+		return nil, fmt.Errorf("backup '%s' is not a valid config: %w", name, err)
+	}
+
+	confirmed, err := cm.confirm(fmt.Sprintf("Replace %s (%d servers) with the backup from %s (%d servers)?",
+		cm.configPath, len(cm.config.Servers), backup.Time.Local().Format(time.DateTime), len(config.Servers)))
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, fmt.Errorf("config not restored")
+	}
+
+	cm.config = config
+	if err := cm.saveConfig(); err != nil {
+		slog.Error("failed to save config", "error", err)
+		return nil, err
+	}
+	return &backup, nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupConfig(t *testing.T) {
+	dir := t.TempDir()
+	cm := &ConfigManager{
+		configPath: filepath.Join(dir, "config.yaml"),
+		config:     &Config{Servers: map[string]Server{}},
+		options:    Options{BackupCount: 2, AssumeYes: true},
+	}
+
+	// The first save has no previous version to back up
+	if err := cm.AddServer("a", "https://a.example.com/S", "AA"); err != nil {
+		t.Fatalf("AddServer failed: %v", err)
+	}
+	for _, name := range []string{"b", "c", "d"} {
+		if err := cm.AddServer(name, "https://"+name+".example.com/S", "AA"); err != nil {
+			t.Fatalf("AddServer failed: %v", err)
+		}
+	}
+
+	backups, err := cm.ListConfigBackups()
+	if err != nil {
+		t.Fatalf("ListConfigBackups failed: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected the backups to be pruned to 2, got %d", len(backups))
+	}
+	if backups[0].Name <= backups[1].Name {
+		t.Errorf("Expected the newest backup first, got %s then %s", backups[0].Name, backups[1].Name)
+	}
+
+	// Saving the same config again does not push out a useful backup
+	if err := cm.saveConfig(); err != nil {
+		t.Fatalf("saveConfig failed: %v", err)
+	}
+	if again, _ := cm.ListConfigBackups(); again[0].Name != backups[0].Name {
+		t.Errorf("Expected no backup for an unchanged config, got %s", again[0].Name)
+	}
+
+	// The newest backup is the config before d was added
+	restored, err := cm.RestoreConfigBackup(backups[0].Name)
+	if err != nil {
+		t.Fatalf("RestoreConfigBackup failed: %v", err)
+	}
+	if restored.Name != backups[0].Name || len(cm.config.Servers) != 3 {
+		t.Errorf("Expected 3 servers after restoring %s, got %d", restored.Name, len(cm.config.Servers))
+	}
+	if _, exists := cm.config.Servers["d"]; exists {
+		t.Error("Expected server d to be gone after the restore")
+	}
+
+	// The config from before the restore was backed up in turn
+	afterRestore, _ := cm.ListConfigBackups()
+	undo, err := os.ReadFile(afterRestore[0].Path)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if config, err := parseConfig(undo); err != nil || len(config.Servers) != 4 {
+		t.Errorf("Expected the newest backup to hold the 4 servers from before the restore")
+	}
+}
+
+func TestRestoreConfigBackupRejects(t *testing.T) {
+	dir := t.TempDir()
+	cm := &ConfigManager{
+		configPath: filepath.Join(dir, "work.yaml"),
+		config:     &Config{Servers: map[string]Server{}},
+		options:    Options{BackupCount: 5, NoInput: true},
+	}
+	backupDir := cm.BackupDir()
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"work-20260101-000000.000000000.yaml":    "servers:\n  a:\n    url: https://a.example.com/S\n",
+		"work-20260102-000000.000000000.yaml":    validConfig,
+		"work-eu-20260103-000000.000000000.yaml": validConfig,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(backupDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := cm.ListConfigBackups()
+	if err != nil {
+		t.Fatalf("ListConfigBackups failed: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Errorf("Expected only the 2 backups of the work profile, got %+v", backups)
+	}
+
+	if _, err := cm.RestoreConfigBackup("work-20260101-000000.000000000.yaml"); err == nil {
+		t.Error("Expected an invalid backup to be rejected")
+	}
+	if _, err := cm.RestoreConfigBackup("work-20260102-000000.000000000.yaml"); err == nil {
+		t.Error("Expected a restore without confirmation to fail with --no-input")
+	}
+	if _, err := cm.RestoreConfigBackup("missing.yaml"); err == nil {
+		t.Error("Expected an unknown backup to be rejected")
+	}
+	if _, err := os.Stat(cm.configPath); !os.IsNotExist(err) {
+		t.Error("Expected the config to be left alone")
+	}
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// KeyCache is the local copy of a server's access keys, stored next to the config under cache/
+type KeyCache struct {
+	SyncedAt time.Time `json:"syncedAt"`
+	// Keys by access key ID
+	Keys map[string]CachedKey `json:"keys"`
+}
+
+// CachedKey is an access key as last seen on the server plus metadata only kept locally
+type CachedKey struct {
+	api.AccessKey
+
+	// FirstSeen is when the key was first cached, the closest thing to a creation time the API offers
+	FirstSeen time.Time `json:"firstSeen"`
+	// ExpiresAt is a local expiry date for the key
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// DisabledLimit is the data limit to restore when a disabled key is enabled again
+	DisabledLimit *api.DataLimit `json:"disabledLimit,omitempty"`
+}
+
+// CacheSyncResult counts the changes made to a key cache by a sync
+type CacheSyncResult struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Kept    int `json:"kept"`
+}
+
+// keyCachePath keeps the caches of other profiles in a subdirectory named after the profile
+func (cm *ConfigManager) keyCachePath(serverName string) string {
+	dir := filepath.Join(filepath.Dir(cm.configPath), "cache")
+	if profile := profileFromFileName(filepath.Base(cm.configPath)); profile != DefaultProfile {
+		dir = filepath.Join(dir, profile)
+	}
+	return filepath.Join(dir, url.PathEscape(serverName)+".json")
+}
+
+// loadKeyCache returns an empty cache if the server has not been synced yet
+func (cm *ConfigManager) loadKeyCache(serverName string) (*KeyCache, error) {
+	cache := &KeyCache{Keys: make(map[string]CachedKey)}
+
+	data, err := os.ReadFile(cm.keyCachePath(serverName))
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, fmt.Errorf("invalid key cache for server '%s': %w", serverName, err)
+	}
+	if cache.Keys == nil {
+		cache.Keys = make(map[string]CachedKey)
+	}
+	return cache, nil
+}
+
+func (cm *ConfigManager) saveKeyCache(serverName string, cache *KeyCache) error {
+	path := cm.keyCachePath(serverName)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// SyncKeyCache replaces the cached keys of a server with the live key list, keeping the local
+// metadata of keys that still exist
+func (cm *ConfigManager) SyncKeyCache(serverName string) (*CacheSyncResult, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "error", err)
+		return nil, err
+	}
+
+	cache, err := cm.loadKeyCache(serverName)
+	if err != nil {
+		slog.Error("failed to load key cache", "error", err)
+		return nil, err
+	}
+
+	synced, result := syncKeyCache(cache, accessKeys, time.Now())
+	if err := cm.saveKeyCache(serverName, synced); err != nil {
+		slog.Error("failed to save key cache", "error", err)
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// syncKeyCache builds the cache for the live keys, carrying over local metadata by key ID
+func syncKeyCache(cache *KeyCache, live []api.AccessKey, now time.Time) (*KeyCache, CacheSyncResult) {
+	synced := &KeyCache{SyncedAt: now, Keys: make(map[string]CachedKey, len(live))}
+	var result CacheSyncResult
+
+	for _, key := range live {
+		entry, cached := cache.Keys[key.ID]
+		if cached {
+			result.Kept++
+		} else {
+			result.Added++
+			entry.FirstSeen = now
+		}
+		entry.AccessKey = key
+		synced.Keys[key.ID] = entry
+	}
+
+	result.Removed = len(cache.Keys) - result.Kept
+	return synced, result
+}
+
+// MoveKeyOptions selects the cached key to move and its ID on the target server
+type MoveKeyOptions struct {
+	KeyID   string
+	KeyName string
+	// TargetID is the key's ID on the target server; empty keeps the source ID
+	TargetID string
+}
+
+// MoveCachedKey moves a key's entry, with its local metadata, from the key cache of one server to
+// that of another, for keys that were migrated between servers by hand. Only the local caches
+// change. If the target cache already has the key, for example from a cache-sync, its key fields
+// are kept and only the local metadata is taken from the source.
+func (cm *ConfigManager) MoveCachedKey(sourceName, targetName string, opts MoveKeyOptions) (*CachedKey, error) {
+	for _, name := range []string{sourceName, targetName} {
+		if _, exists := cm.config.Servers[name]; !exists {
+			slog.Error("server not found", "server", name)
+			return nil, serverNotFound(name)
+		}
+	}
+
+	source, err := cm.loadKeyCache(sourceName)
+	if err != nil {
+		slog.Error("failed to load key cache", "error", err)
+		return nil, err
+	}
+	// Moving within one server only changes the key's ID
+	target := source
+	if targetName != sourceName {
+		if target, err = cm.loadKeyCache(targetName); err != nil {
+			slog.Error("failed to load key cache", "error", err)
+			return nil, err
+		}
+	}
+
+	entry, err := findCachedKey(source, opts.KeyID, opts.KeyName)
+	if err != nil {
+		return nil, fmt.Errorf("%w in the key cache of server '%s'", err, sourceName)
+	}
+
+	targetID := opts.TargetID
+	if targetID == "" {
+		targetID = entry.ID
+	}
+	if sourceName == targetName && targetID == entry.ID {
+		return nil, fmt.Errorf("source and target of the move are the same key")
+	}
+
+	moved := entry
+	if existing, cached := target.Keys[targetID]; cached {
+		moved.AccessKey = existing.AccessKey
+	}
+	moved.ID = targetID
+
+	delete(source.Keys, entry.ID)
+	target.Keys[targetID] = moved
+
+	// Save the target first so that a failure leaves the key in both caches rather than in neither
+	if err := cm.saveKeyCache(targetName, target); err != nil {
+		slog.Error("failed to save key cache", "error", err)
+		return nil, err
+	}
+	if sourceName != targetName {
+		if err := cm.saveKeyCache(sourceName, source); err != nil {
+			slog.Error("failed to save key cache", "error", err)
+			return nil, err
+		}
+	}
+
+	slog.Debug("moved cached key", "from", sourceName, "to", targetName, "keyID", entry.ID, "targetID", targetID)
+	return &moved, nil
+}
+
+// findCachedKey returns the cached key with the given ID, or with the given name when keyID is
+// empty; a name has to be unique
+func findCachedKey(cache *KeyCache, keyID, keyName string) (CachedKey, error) {
+	if keyID != "" {
+		entry, cached := cache.Keys[keyID]
+		if !cached {
+			return CachedKey{}, fmt.Errorf("access key with ID '%s' not found", keyID)
+		}
+		return entry, nil
+	}
+
+	var matches []CachedKey
+	for _, entry := range cache.Keys {
+		if entry.Name == keyName {
+			matches = append(matches, entry)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return CachedKey{}, fmt.Errorf("access key with name '%s' not found", keyName)
+	case 1:
+		return matches[0], nil
+	default:
+		return CachedKey{}, fmt.Errorf("several access keys are named '%s'; use the key ID", keyName)
+	}
+}
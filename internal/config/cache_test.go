@@ -0,0 +1,154 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestSyncKeyCache(t *testing.T) {
+	lastWeek := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := lastWeek.Add(7 * 24 * time.Hour)
+	expiry := now.Add(30 * 24 * time.Hour)
+
+	cache := &KeyCache{Keys: map[string]CachedKey{
+		"1": {AccessKey: api.AccessKey{ID: "1", Name: "old name"}, FirstSeen: lastWeek, ExpiresAt: &expiry},
+		"2": {AccessKey: api.AccessKey{ID: "2", Name: "deleted"}, FirstSeen: lastWeek},
+	}}
+	live := []api.AccessKey{
+		{ID: "1", Name: "new name"},
+		{ID: "3", Name: "created elsewhere"},
+	}
+
+	synced, result := syncKeyCache(cache, live, now)
+
+	if result != (CacheSyncResult{Added: 1, Removed: 1, Kept: 1}) {
+		t.Errorf("Expected 1 added, 1 removed, 1 kept, got %+v", result)
+	}
+	if _, exists := synced.Keys["2"]; exists {
+		t.Error("Expected stale key 2 to be dropped")
+	}
+
+	kept := synced.Keys["1"]
+	if kept.Name != "new name" {
+		t.Errorf("Expected live name to replace cached name, got %q", kept.Name)
+	}
+	if kept.ExpiresAt == nil || !kept.ExpiresAt.Equal(expiry) || !kept.FirstSeen.Equal(lastWeek) {
+		t.Errorf("Expected local metadata to be preserved, got %+v", kept)
+	}
+
+	if added := synced.Keys["3"]; !added.FirstSeen.Equal(now) {
+		t.Errorf("Expected new key first seen now, got %v", added.FirstSeen)
+	}
+}
+
+func TestKeyCacheRoundTrip(t *testing.T) {
+	cm := &ConfigManager{configPath: filepath.Join(t.TempDir(), "config.yaml")}
+
+	empty, err := cm.loadKeyCache("prod/eu")
+	if err != nil {
+		t.Fatalf("loadKeyCache failed for missing cache: %v", err)
+	}
+	if len(empty.Keys) != 0 {
+		t.Errorf("Expected empty cache, got %d keys", len(empty.Keys))
+	}
+
+	cache := &KeyCache{Keys: map[string]CachedKey{
+		"1": {AccessKey: api.AccessKey{ID: "1", Name: "key"}, DisabledLimit: &api.DataLimit{Bytes: 1000}},
+	}}
+	if err := cm.saveKeyCache("prod/eu", cache); err != nil {
+		t.Fatalf("saveKeyCache failed: %v", err)
+	}
+
+	loaded, err := cm.loadKeyCache("prod/eu")
+	if err != nil {
+		t.Fatalf("loadKeyCache failed: %v", err)
+	}
+	if loaded.Keys["1"].DisabledLimit == nil || loaded.Keys["1"].DisabledLimit.Bytes != 1000 {
+		t.Errorf("Expected cached metadata to round-trip, got %+v", loaded.Keys["1"])
+	}
+}
+
+func TestMoveCachedKey(t *testing.T) {
+	firstSeen := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiry := firstSeen.Add(30 * 24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		target     string
+		opts       MoveKeyOptions
+		targetKeys map[string]CachedKey
+		wantID     string
+		wantName   string
+		wantErr    bool
+	}{
+		{"by ID", "new", MoveKeyOptions{KeyID: "1"}, nil, "1", "alice", false},
+		{"by name to another ID", "new", MoveKeyOptions{KeyName: "alice", TargetID: "7"}, nil, "7", "alice", false},
+		{
+			name:       "keeps the synced key fields of the target",
+			target:     "new",
+			opts:       MoveKeyOptions{KeyID: "1", TargetID: "7"},
+			targetKeys: map[string]CachedKey{"7": {AccessKey: api.AccessKey{ID: "7", Name: "alice (migrated)"}, FirstSeen: time.Now()}},
+			wantID:     "7",
+			wantName:   "alice (migrated)",
+		},
+		{"within one server", "old", MoveKeyOptions{KeyID: "1", TargetID: "7"}, nil, "7", "alice", false},
+		{"unknown key", "new", MoveKeyOptions{KeyID: "9"}, nil, "", "", true},
+		{"unknown server", "missing", MoveKeyOptions{KeyID: "1"}, nil, "", "", true},
+		{"same key", "old", MoveKeyOptions{KeyID: "1"}, nil, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &ConfigManager{
+				configPath: filepath.Join(t.TempDir(), "config.yaml"),
+				config: &Config{Servers: map[string]Server{
+					"old": {Name: "old", URL: "https://old.example.com/Secret"},
+					"new": {Name: "new", URL: "https://new.example.com/Secret"},
+				}},
+			}
+			source := &KeyCache{Keys: map[string]CachedKey{
+				"1": {AccessKey: api.AccessKey{ID: "1", Name: "alice"}, FirstSeen: firstSeen, ExpiresAt: &expiry},
+				"2": {AccessKey: api.AccessKey{ID: "2", Name: "bob"}, FirstSeen: firstSeen},
+			}}
+			if err := cm.saveKeyCache("old", source); err != nil {
+				t.Fatalf("saveKeyCache failed: %v", err)
+			}
+			if tt.targetKeys != nil {
+				if err := cm.saveKeyCache(tt.target, &KeyCache{Keys: tt.targetKeys}); err != nil {
+					t.Fatalf("saveKeyCache failed: %v", err)
+				}
+			}
+
+			_, err := cm.MoveCachedKey("old", tt.target, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MoveCachedKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			source, _ = cm.loadKeyCache("old")
+			if tt.wantErr {
+				if len(source.Keys) != 2 {
+					t.Errorf("Expected the source cache to be unchanged, got %v", source.Keys)
+				}
+				return
+			}
+
+			target, _ := cm.loadKeyCache(tt.target)
+			moved, cached := target.Keys[tt.wantID]
+			if !cached || moved.ID != tt.wantID || moved.Name != tt.wantName {
+				t.Fatalf("Expected key %s named %q in the target cache, got %v", tt.wantID, tt.wantName, target.Keys)
+			}
+			if !moved.FirstSeen.Equal(firstSeen) || moved.ExpiresAt == nil || !moved.ExpiresAt.Equal(expiry) {
+				t.Errorf("Expected the local metadata to move with the key, got %+v", moved)
+			}
+			if _, still := source.Keys["1"]; still {
+				t.Error("Expected the key to be removed from the source cache")
+			}
+			if _, kept := source.Keys["2"]; !kept {
+				t.Error("Expected other keys to stay in the source cache")
+			}
+		})
+	}
+}
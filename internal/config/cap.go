@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// CapKeyOptions selects the keys whose data limit is lowered to a ceiling
+type CapKeyOptions struct {
+	KeyID      string
+	KeyName    string
+	NamePrefix string
+	// Cap in bytes
+	Cap int64
+	// CapPercent sets the cap relative to the server's default limit instead
+	CapPercent float64
+}
+
+// CapResult is what CapAccessKeys did with one key
+type CapResult struct {
+	Key api.AccessKey
+	// Previous is the limit that applied before, nil if the key was unlimited
+	Previous *api.DataLimit
+	// PreviousSource says whether Previous is the key's own limit ("key") or the "server default"
+	PreviousSource string
+	// Limit is the cap in bytes that now applies to the key
+	Limit int64
+	// Skipped is set when the key was already at or below the cap
+	Skipped bool
+	Err     error
+}
+
+// CapAccessKeys lowers the data limit of every selected key whose limit is above the cap, and gives
+// every key without its own limit an explicit one. Keys that are already at or below the cap are
+// never raised: a key without its own limit falls under the server's default limit, which it gets
+// as its own limit when that default is at or below the cap.
+// It returns a result per selected key, also when some keys failed.
+func (cm *ConfigManager) CapAccessKeys(serverName string, opts CapKeyOptions) ([]CapResult, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	serverInfo, err := apiClient.GetServerInfo(server.URL)
+	if err != nil {
+		slog.Error("failed to get server info", "error", err)
+		return nil, err
+	}
+
+	limit := opts.Cap
+	if opts.CapPercent > 0 {
+		if serverInfo.AccessKeyDataLimit == nil {
+			return nil, fmt.Errorf("server '%s' has no default data limit, so the cap cannot be a percentage", serverName)
+		}
+		limit = percentOf(serverInfo.AccessKeyDataLimit.Bytes, opts.CapPercent)
+	}
+	if err := cm.checkMaxDataLimit(limit); err != nil {
+		slog.Error("cap above the maximum data limit", "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "error", err)
+		return nil, err
+	}
+
+	selected := selectKeysToCap(accessKeys, opts)
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no access keys on server '%s' match the selection", serverName)
+	}
+
+	results := make([]CapResult, 0, len(selected))
+	failed := 0
+	progress := cm.startProgress("Capping keys", len(selected))
+	for _, key := range selected {
+		current, source := effectiveDataLimit(key, serverInfo.AccessKeyDataLimit)
+		result := CapResult{Key: key, Previous: current, PreviousSource: source}
+		// A key under a server default at or below the cap keeps that default as its own limit,
+		// so it is not left unlimited when the default is later raised or removed
+		keyLimit := limit
+		if current != nil {
+			keyLimit = min(current.Bytes, limit)
+		}
+		if key.DataLimit != nil && key.DataLimit.Bytes <= limit {
+			result.Skipped = true
+		} else if err := apiClient.SetAccessKeyDataLimit(server.URL, key.ID, api.DataLimit{Bytes: keyLimit}); err != nil {
+			slog.Error("failed to set data limit", "keyID", key.ID, "error", err)
+			result.Err = err
+			failed++
+		} else {
+			result.Limit = keyLimit
+		}
+		results = append(results, result)
+		progress.tick()
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("failed to cap %d of %d keys", failed, len(selected))
+	}
+	return results, nil
+}
+
+// selectKeysToCap picks the key with the given ID or name, or every key whose name has the prefix
+func selectKeysToCap(keys []api.AccessKey, opts CapKeyOptions) []api.AccessKey {
+	var selected []api.AccessKey
+	for _, key := range keys {
+		switch {
+		case opts.KeyID != "":
+			if key.ID != opts.KeyID {
+				continue
+			}
+		case opts.KeyName != "":
+			if key.Name != opts.KeyName {
+				continue
+			}
+		case !strings.HasPrefix(key.Name, opts.NamePrefix):
+			continue
+		}
+		selected = append(selected, key)
+	}
+	return selected
+}
+
+// effectiveDataLimit returns the limit that applies to a key and where it comes from;
+// nil means the key is unlimited
+func effectiveDataLimit(key api.AccessKey, serverDefault *api.DataLimit) (*api.DataLimit, string) {
+	if key.DataLimit != nil {
+		return key.DataLimit, "key"
+	}
+	if serverDefault != nil {
+		return serverDefault, "server default"
+	}
+	return nil, ""
+}
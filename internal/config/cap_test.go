@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestCapAccessKeys(t *testing.T) {
+	keys := []api.AccessKey{
+		{ID: "1", Name: "team-high", DataLimit: &api.DataLimit{Bytes: 200}},
+		{ID: "2", Name: "team-low", DataLimit: &api.DataLimit{Bytes: 50}},
+		{ID: "3", Name: "team-default"},
+		{ID: "4", Name: "other", DataLimit: &api.DataLimit{Bytes: 500}},
+	}
+
+	tests := []struct {
+		name          string
+		serverDefault *api.DataLimit
+		// expected lists the capped keys as id=limit
+		expected []string
+	}{
+		{"no server default", nil, []string{"1=100", "3=100"}},
+		{"server default above cap", &api.DataLimit{Bytes: 150}, []string{"1=100", "3=100"}},
+		// The key without a limit keeps the lower default, but as its own limit
+		{"server default below cap", &api.DataLimit{Bytes: 80}, []string{"1=100", "3=80"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var capped []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/server":
+					json.NewEncoder(w).Encode(api.OutlineServer{AccessKeyDataLimit: tt.serverDefault})
+				case r.URL.Path == "/access-keys":
+					json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: keys})
+				case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/data-limit"):
+					var body struct {
+						Limit api.DataLimit `json:"limit"`
+					}
+					json.NewDecoder(r.Body).Decode(&body)
+					mu.Lock()
+					capped = append(capped, fmt.Sprintf("%s=%d", strings.Split(r.URL.Path, "/")[2], body.Limit.Bytes))
+					mu.Unlock()
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			cm := &ConfigManager{
+				config: &Config{Servers: map[string]Server{
+					"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+				}},
+			}
+
+			if _, err := cm.CapAccessKeys("test", CapKeyOptions{NamePrefix: "team-", Cap: 100}); err != nil {
+				t.Fatalf("CapAccessKeys() unexpected error: %v", err)
+			}
+			if strings.Join(capped, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected keys %v to be capped, got %v", tt.expected, capped)
+			}
+		})
+	}
+}
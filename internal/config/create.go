@@ -0,0 +1,396 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// CreateKeyOptions describes the access keys to create
+type CreateKeyOptions struct {
+	Name   string
+	Method string
+	// MethodFromServer, when Method is empty, uses the method most of the server's keys use, or
+	// leaves the choice to the server when it has no keys yet
+	MethodFromServer bool
+	// UniqueName lists the keys before creating and warns, or fails in strict mode, when a name
+	// is already used by a key of the server or requested more than once
+	UniqueName bool
+	Port       int
+	// DataLimit in bytes; nil means no limit of the key's own, and zero blocks the key from
+	// transferring any data
+	DataLimit *int64
+	// DataLimitPercent sets the limit relative to the server's default limit instead
+	DataLimitPercent float64
+
+	// Reconcile lists the keys before creating and, if the create request fails, looks for
+	// a key the server created anyway so it isn't left orphaned
+	Reconcile bool
+	// Rollback deletes such an orphaned key instead of adopting it
+	Rollback bool
+
+	// Count creates several keys at once, named Name-1 to Name-N when Name is set
+	Count int
+	// NamesFile creates one key per non-empty line of the file
+	NamesFile string
+	// Concurrency bounds how many keys are created in parallel
+	Concurrency int
+}
+
+// KeyFailure is a key that could not be created, labelled by its name or position
+type KeyFailure struct {
+	Label string
+	Err   error
+}
+
+// CreateResult lists the created keys in the order they were requested and the keys that failed
+type CreateResult struct {
+	Created []*api.AccessKey
+	Failed  []KeyFailure
+}
+
+// CreateAccessKey creates one or more access keys on a server. The result is also returned when
+// some keys failed, so the keys that were created can still be shown.
+func (cm *ConfigManager) CreateAccessKey(serverName string, opts CreateKeyOptions) (*CreateResult, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	names, err := keyNames(opts)
+	if err != nil {
+		slog.Error("failed to determine key names", "error", err)
+		return nil, err
+	}
+
+	// Get API client for this server
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	if opts.DataLimitPercent > 0 {
+		limit, err := cm.percentOfDefaultLimit(apiClient, serverName, opts.DataLimitPercent)
+		if err != nil {
+			return nil, err
+		}
+		opts.DataLimit = &limit
+	}
+	if opts.DataLimit != nil {
+		if err := cm.checkMaxDataLimit(*opts.DataLimit); err != nil {
+			slog.Error("data limit above the maximum", "error", err)
+			return nil, err
+		}
+	}
+
+	matchMethod := opts.MethodFromServer && opts.Method == ""
+	var accessKeys []api.AccessKey
+	if matchMethod || opts.UniqueName {
+		accessKeys, err = apiClient.ListAccessKeys(server.URL)
+		if err != nil {
+			slog.Error("failed to list access keys before create", "error", err)
+			return nil, err
+		}
+	}
+
+	if matchMethod {
+		opts.Method = commonKeyMethod(accessKeys)
+		slog.Debug("matching the method of the server's keys", "server", serverName, "method", opts.Method)
+	}
+
+	if opts.UniqueName {
+		conflicts := nameConflicts(names, accessKeys)
+		if len(conflicts) > 0 && cm.options.Strict {
+			slog.Error("key names are not unique", "server", serverName, "conflicts", len(conflicts))
+			return nil, fmt.Errorf("no keys created: %w", errors.Join(conflicts...))
+		}
+		for _, conflict := range conflicts {
+			slog.Warn("creating a key with a duplicate name", "server", serverName, "reason", conflict)
+		}
+	}
+
+	reqs := make([]api.CreateAccessKeyRequest, len(names))
+	for i, name := range names {
+		reqs[i] = newCreateRequest(name, opts)
+	}
+	return cm.createKeys(apiClient, server, reqs, opts)
+}
+
+// nameConflicts explains every name that is already used by one of keys or that names contains
+// more than once. Keys without a name are never a conflict.
+func nameConflicts(names []string, keys []api.AccessKey) []error {
+	existing := make(map[string][]string)
+	for _, key := range keys {
+		if key.Name != "" {
+			existing[key.Name] = append(existing[key.Name], key.ID)
+		}
+	}
+	requested := make(map[string]int, len(names))
+	for _, name := range names {
+		requested[name]++
+	}
+
+	var conflicts []error
+	reported := make(map[string]bool)
+	for _, name := range names {
+		if name == "" || reported[name] {
+			continue
+		}
+		reported[name] = true
+		switch ids := existing[name]; len(ids) {
+		case 0:
+		case 1:
+			conflicts = append(conflicts, fmt.Errorf("key name '%s' is already used by the key with ID %s", name, ids[0]))
+		default:
+			conflicts = append(conflicts, fmt.Errorf("key name '%s' is already used by the keys with IDs %s", name, strings.Join(ids, ", ")))
+		}
+		if requested[name] > 1 {
+			conflicts = append(conflicts, fmt.Errorf("key name '%s' is requested %d times", name, requested[name]))
+		}
+	}
+	return conflicts
+}
+
+// createKeys sends the create requests using the reconcile and concurrency settings of opts.
+// Like CreateAccessKey, it returns the result also when some keys failed.
+func (cm *ConfigManager) createKeys(apiClient *api.APIClient, server Server, reqs []api.CreateAccessKeyRequest, opts CreateKeyOptions) (*CreateResult, error) {
+	var existingIDs map[string]bool
+	if opts.Reconcile {
+		existingKeys, err := apiClient.ListAccessKeys(server.URL)
+		if err != nil {
+			slog.Error("failed to list access keys before create", "error", err)
+			return nil, err
+		}
+		existingIDs = make(map[string]bool, len(existingKeys))
+		for _, key := range existingKeys {
+			existingIDs[key.ID] = true
+		}
+	}
+
+	concurrency := opts.Concurrency
+	if opts.Reconcile && concurrency > 1 {
+		// Keys created in parallel would look like orphans of each other
+		slog.Debug("reconciliation enabled, creating keys sequentially")
+		concurrency = 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	type createResult struct {
+		key *api.AccessKey
+		err error
+	}
+
+	results := make([]createResult, len(reqs))
+	var mu sync.Mutex
+	progress := cm.startProgress("Creating keys", len(reqs))
+	runBounded(ctx, len(reqs), concurrency, func(i int) {
+		defer progress.tick()
+		accessKey, err := cm.createKey(ctx, apiClient, server, reqs[i], existingIDs, opts)
+		results[i] = createResult{key: accessKey, err: err}
+
+		if err == nil && existingIDs != nil {
+			mu.Lock()
+			existingIDs[accessKey.ID] = true
+			mu.Unlock()
+		}
+	})
+	progress.finish()
+
+	// Results keep the order of the requests even though keys were created concurrently
+	result := &CreateResult{}
+	for i, created := range results {
+		err := created.err
+		if err == nil && created.key == nil {
+			// Never started because the run was interrupted
+			err = ctx.Err()
+		}
+		if err != nil {
+			label := reqs[i].Name
+			if label == "" {
+				label = fmt.Sprintf("key #%d", i+1)
+			}
+			result.Failed = append(result.Failed, KeyFailure{Label: label, Err: err})
+			continue
+		}
+		result.Created = append(result.Created, created.key)
+	}
+
+	if len(result.Failed) == 0 {
+		return result, nil
+	}
+	if len(reqs) == 1 {
+		return result, result.Failed[0].Err
+	}
+	return result, fmt.Errorf("created %d of %d access keys", len(result.Created), len(reqs))
+}
+
+// keyNames returns the name of every key to create; a single empty name creates one unnamed key
+func keyNames(opts CreateKeyOptions) ([]string, error) {
+	if opts.NamesFile != "" {
+		data, err := os.ReadFile(opts.NamesFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if name := strings.TrimSpace(line); name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no key names found in '%s'", opts.NamesFile)
+		}
+		return names, nil
+	}
+
+	if opts.Count > 1 {
+		names := make([]string, opts.Count)
+		if opts.Name != "" {
+			for i := range names {
+				names[i] = fmt.Sprintf("%s-%d", opts.Name, i+1)
+			}
+		}
+		return names, nil
+	}
+
+	return []string{opts.Name}, nil
+}
+
+// commonKeyMethod returns the method used by the most keys, preferring the method that comes first
+// alphabetically on a tie, or empty when no key reports a method
+func commonKeyMethod(keys []api.AccessKey) string {
+	counts := make(map[string]int)
+	for _, key := range keys {
+		if key.Method != "" {
+			counts[key.Method]++
+		}
+	}
+
+	var common string
+	for method, count := range counts {
+		if count > counts[common] || (count == counts[common] && method < common) {
+			common = method
+		}
+	}
+	return common
+}
+
+func newCreateRequest(name string, opts CreateKeyOptions) api.CreateAccessKeyRequest {
+	req := api.CreateAccessKeyRequest{
+		Method: opts.Method,
+	}
+	if name != "" {
+		req.Name = name
+	}
+	if opts.Port > 0 {
+		req.Port = opts.Port
+	}
+	if opts.DataLimit != nil {
+		req.Limit = &api.DataLimit{Bytes: *opts.DataLimit}
+	}
+	return req
+}
+
+// createKey creates a single access key, reconciling a failed request when enabled
+func (cm *ConfigManager) createKey(ctx context.Context, apiClient *api.APIClient, server Server, req api.CreateAccessKeyRequest, existingIDs map[string]bool, opts CreateKeyOptions) (*api.AccessKey, error) {
+	accessKey, err := apiClient.CreateAccessKeyContext(ctx, server.URL, req)
+	if err == nil {
+		ensureKeyName(apiClient, server, accessKey, req.Name)
+		return accessKey, nil
+	}
+
+	slog.Error("failed to create access key", "name", req.Name, "error", err)
+	if !opts.Reconcile {
+		return nil, err
+	}
+
+	accessKey, err = cm.reconcileCreatedKey(apiClient, server, req, existingIDs, opts.Rollback, err)
+	if err != nil {
+		return nil, err
+	}
+	slog.Warn("create request failed, but the server created the key; adopting it", "name", req.Name, "keyID", accessKey.ID)
+	return accessKey, nil
+}
+
+// ensureKeyName renames a created key that came back without the requested name, which some
+// Outline versions do; the name only sticks after a separate rename. The key exists either way,
+// so a failed rename is only logged.
+func ensureKeyName(apiClient *api.APIClient, server Server, accessKey *api.AccessKey, name string) {
+	if name == "" || accessKey.Name != "" {
+		return
+	}
+
+	slog.Debug("created key has no name, renaming it", "keyID", accessKey.ID, "name", name)
+	if err := apiClient.RenameAccessKey(server.URL, accessKey.ID, name); err != nil {
+		slog.Warn("access key was created without its name and could not be renamed", "keyID", accessKey.ID, "name", name, "error", err)
+		return
+	}
+	accessKey.Name = name
+}
+
+// reconcileCreatedKey looks for a key created by a failed create request. The key is returned so
+// it can be adopted, or deleted when rollback is set, in which case createErr is returned.
+func (cm *ConfigManager) reconcileCreatedKey(apiClient *api.APIClient, server Server, req api.CreateAccessKeyRequest, existingIDs map[string]bool, rollback bool, createErr error) (*api.AccessKey, error) {
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys for reconciliation", "error", err)
+		return nil, fmt.Errorf("%w (could not check for an orphaned key: %v)", createErr, err)
+	}
+
+	orphans := findOrphanedKeys(accessKeys, existingIDs, req)
+	if len(orphans) == 0 {
+		slog.Debug("no orphaned key found after failed create")
+		return nil, createErr
+	}
+
+	if rollback {
+		for _, orphan := range orphans {
+			if err := apiClient.DeleteAccessKey(server.URL, orphan.ID); err != nil {
+				slog.Error("failed to roll back orphaned key", "keyID", orphan.ID, "error", err)
+				return nil, fmt.Errorf("%w (orphaned key '%s' could not be deleted: %v)", createErr, orphan.ID, err)
+			}
+			slog.Warn("rolled back orphaned access key", "keyID", orphan.ID)
+		}
+		return nil, createErr
+	}
+
+	if len(orphans) > 1 {
+		ids := make([]string, 0, len(orphans))
+		for _, orphan := range orphans {
+			ids = append(ids, orphan.ID)
+		}
+		return nil, fmt.Errorf("%w (found several possibly orphaned keys: %s)", createErr, strings.Join(ids, ", "))
+	}
+
+	return &orphans[0], nil
+}
+
+// findOrphanedKeys returns keys that did not exist before the create request and match it
+func findOrphanedKeys(keys []api.AccessKey, existingIDs map[string]bool, req api.CreateAccessKeyRequest) []api.AccessKey {
+	var orphans []api.AccessKey
+	for _, key := range keys {
+		if existingIDs[key.ID] {
+			continue
+		}
+		if req.Name != "" && key.Name != req.Name {
+			continue
+		}
+		if req.Method != "" && key.Method != req.Method {
+			continue
+		}
+		orphans = append(orphans, key)
+	}
+	return orphans
+}
@@ -0,0 +1,564 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// newFlakyCreateServer fakes a server that creates the key but fails to answer the create request
+func newFlakyCreateServer(t *testing.T) (*httptest.Server, func() []api.AccessKey) {
+	t.Helper()
+
+	var mu sync.Mutex
+	keys := []api.AccessKey{{ID: "1", Name: "existing", Method: "aes-192-gcm"}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/access-keys":
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: keys})
+		case r.Method == http.MethodPost && r.URL.Path == "/access-keys":
+			var req api.CreateAccessKeyRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			keys = append(keys, api.AccessKey{ID: "2", Name: req.Name, Method: req.Method})
+			w.WriteHeader(http.StatusGatewayTimeout)
+		case r.Method == http.MethodDelete && r.URL.Path == "/access-keys/2":
+			keys = keys[:1]
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []api.AccessKey {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]api.AccessKey(nil), keys...)
+	}
+}
+
+func TestCreateAccessKeyReconcile(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         CreateKeyOptions
+		expectError  bool
+		expectedKeys int
+	}{
+		{"without reconcile", CreateKeyOptions{Name: "new", Method: "aes-192-gcm"}, true, 2},
+		{"adopt orphaned key", CreateKeyOptions{Name: "new", Method: "aes-192-gcm", Reconcile: true}, false, 2},
+		{"roll back orphaned key", CreateKeyOptions{Name: "new", Method: "aes-192-gcm", Reconcile: true, Rollback: true}, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, keys := newFlakyCreateServer(t)
+			cm := &ConfigManager{
+				config: &Config{Servers: map[string]Server{
+					"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+				}},
+			}
+
+			_, err := cm.CreateAccessKey("test", tt.opts)
+			if (err != nil) != tt.expectError {
+				t.Errorf("CreateAccessKey() error = %v, expectError %v", err, tt.expectError)
+			}
+
+			if got := len(keys()); got != tt.expectedKeys {
+				t.Errorf("Expected %d keys on server, got %d", tt.expectedKeys, got)
+			}
+		})
+	}
+}
+
+func TestFindOrphanedKeys(t *testing.T) {
+	keys := []api.AccessKey{
+		{ID: "1", Name: "new", Method: "aes-192-gcm"},
+		{ID: "2", Name: "new", Method: "aes-192-gcm"},
+		{ID: "3", Name: "other", Method: "aes-192-gcm"},
+		{ID: "4", Name: "new", Method: "chacha20-poly1305"},
+	}
+	existing := map[string]bool{"1": true}
+
+	orphans := findOrphanedKeys(keys, existing, api.CreateAccessKeyRequest{Name: "new", Method: "aes-192-gcm"})
+	if len(orphans) != 1 || orphans[0].ID != "2" {
+		t.Errorf("Expected only key 2 to be orphaned, got %+v", orphans)
+	}
+
+	unnamed := findOrphanedKeys(keys, existing, api.CreateAccessKeyRequest{Method: "aes-192-gcm"})
+	if len(unnamed) != 2 {
+		t.Errorf("Expected 2 orphans when no name was requested, got %d", len(unnamed))
+	}
+}
+
+func TestKeyNames(t *testing.T) {
+	namesFile := filepath.Join(t.TempDir(), "names.txt")
+	if err := os.WriteFile(namesFile, []byte("alice\n\n  bob  \ncarol, the admin\n"), 0600); err != nil {
+		t.Fatalf("Failed to write names file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     CreateKeyOptions
+		expected []string
+		hasError bool
+	}{
+		{"single named key", CreateKeyOptions{Name: "key"}, []string{"key"}, false},
+		{"single unnamed key", CreateKeyOptions{}, []string{""}, false},
+		{"count with name", CreateKeyOptions{Name: "team", Count: 3}, []string{"team-1", "team-2", "team-3"}, false},
+		{"count without name", CreateKeyOptions{Count: 2}, []string{"", ""}, false},
+		{"names from file", CreateKeyOptions{NamesFile: namesFile}, []string{"alice", "bob", "carol, the admin"}, false},
+
+		// Invalid inputs
+		{"missing names file", CreateKeyOptions{NamesFile: filepath.Join(t.TempDir(), "missing.txt")}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := keyNames(tt.opts)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("keyNames() expected error, got %v", names)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("keyNames() unexpected error: %v", err)
+			}
+			if strings.Join(names, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("keyNames() = %q, want %q", names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCreateAccessKeyBatch(t *testing.T) {
+	var mu sync.Mutex
+	nextID := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var req api.CreateAccessKeyRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		nextID++
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(api.AccessKey{
+			ID:        strconv.Itoa(nextID),
+			Name:      req.Name,
+			Port:      12345,
+			Method:    req.Method,
+			AccessURL: "ss://key" + strconv.Itoa(nextID),
+			DataLimit: req.Limit,
+		})
+	}))
+	defer server.Close()
+
+	namesFile := filepath.Join(t.TempDir(), "names.txt")
+	if err := os.WriteFile(namesFile, []byte("alice\nsmith, bob\n"), 0600); err != nil {
+		t.Fatalf("Failed to write names file: %v", err)
+	}
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	dataLimit := int64(1000000000)
+	result, err := cm.CreateAccessKey("test", CreateKeyOptions{
+		Method:    "aes-192-gcm",
+		DataLimit: &dataLimit,
+		NamesFile: namesFile,
+	})
+	if err != nil {
+		t.Fatalf("CreateAccessKey failed: %v", err)
+	}
+
+	if len(result.Created) != 2 {
+		t.Fatalf("Expected 2 created keys, got %d", len(result.Created))
+	}
+	for i, name := range []string{"alice", "smith, bob"} {
+		key := result.Created[i]
+		if key.Name != name || key.DataLimit == nil || key.DataLimit.Bytes != 1000000000 {
+			t.Errorf("Created key %d = %+v, want name %q with a 1GB limit", i, key, name)
+		}
+	}
+}
+
+// newSlowCreateServer fakes a server with a fixed latency per create request that rejects names starting with "bad"
+func newSlowCreateServer(tb testing.TB, latency time.Duration) *httptest.Server {
+	tb.Helper()
+
+	var mu sync.Mutex
+	nextID := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.CreateAccessKeyRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		time.Sleep(latency)
+
+		if strings.HasPrefix(req.Name, "bad") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		nextID++
+		id := nextID
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(api.AccessKey{ID: strconv.Itoa(id), Name: req.Name, AccessURL: "ss://" + req.Name})
+	}))
+	tb.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCreateAccessKeyConcurrent(t *testing.T) {
+	server := newSlowCreateServer(t, 5*time.Millisecond)
+	dir := t.TempDir()
+
+	names := []string{"delta", "alpha", "bad-one", "charlie", "bravo", "echo"}
+	namesFile := filepath.Join(dir, "names.txt")
+	if err := os.WriteFile(namesFile, []byte(strings.Join(names, "\n")), 0600); err != nil {
+		t.Fatalf("Failed to write names file: %v", err)
+	}
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	result, err := cm.CreateAccessKey("test", CreateKeyOptions{
+		NamesFile:   namesFile,
+		Concurrency: 4,
+	})
+	if err == nil {
+		t.Fatal("Expected an error reporting the failed key")
+	}
+	if !strings.Contains(err.Error(), "created 5 of 6") {
+		t.Errorf("Expected aggregated failure count, got %v", err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Label != "bad-one" {
+		t.Errorf("Expected bad-one to be reported as failed, got %+v", result.Failed)
+	}
+
+	var created []string
+	for _, key := range result.Created {
+		created = append(created, key.Name)
+	}
+	expected := []string{"delta", "alpha", "charlie", "bravo", "echo"}
+	if strings.Join(created, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected keys in requested order %v, got %v", expected, created)
+	}
+}
+
+// BenchmarkCreateAccessKeys compares sequential and concurrent bulk creation against a server with 5ms latency
+func BenchmarkCreateAccessKeys(b *testing.B) {
+	server := newSlowCreateServer(b, 5*time.Millisecond)
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := cm.CreateAccessKey("test", CreateKeyOptions{
+					Name:        "key",
+					Count:       20,
+					Concurrency: concurrency,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateAccessKeyRenamesUnnamedKey(t *testing.T) {
+	var renamed string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			// Older servers ignore the requested name
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(api.AccessKey{ID: "7", AccessURL: "ss://key7"})
+		case r.Method == http.MethodPut && r.URL.Path == "/access-keys/7/name":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			renamed = body["name"]
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	result, err := cm.CreateAccessKey("test", CreateKeyOptions{Name: "alice"})
+	if err != nil {
+		t.Fatalf("CreateAccessKey failed: %v", err)
+	}
+	if renamed != "alice" {
+		t.Errorf("Expected a rename to 'alice', got %q", renamed)
+	}
+	if result.Created[0].Name != "alice" {
+		t.Errorf("Expected the returned key to carry the name, got %q", result.Created[0].Name)
+	}
+
+	// Unnamed keys are not renamed
+	renamed = ""
+	if _, err := cm.CreateAccessKey("test", CreateKeyOptions{}); err != nil {
+		t.Fatalf("CreateAccessKey failed: %v", err)
+	}
+	if renamed != "" {
+		t.Errorf("Expected no rename for an unnamed key, got %q", renamed)
+	}
+}
+
+func TestCreateAccessKeyDataLimit(t *testing.T) {
+	zero, oneGB := int64(0), int64(1000000000)
+
+	tests := []struct {
+		name      string
+		dataLimit *int64
+		wantLimit string
+	}{
+		{"no limit", nil, ""},
+		{"explicit zero blocks the key", &zero, `{"bytes":0}`},
+		{"limit", &oneGB, `{"bytes":1000000000}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sentLimit string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req map[string]json.RawMessage
+				json.NewDecoder(r.Body).Decode(&req)
+				sentLimit = string(req["limit"])
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(api.AccessKey{ID: "1"})
+			}))
+			defer server.Close()
+
+			cm := &ConfigManager{
+				config: &Config{Servers: map[string]Server{
+					"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+				}},
+			}
+
+			if _, err := cm.CreateAccessKey("test", CreateKeyOptions{DataLimit: tt.dataLimit}); err != nil {
+				t.Fatalf("CreateAccessKey failed: %v", err)
+			}
+			if sentLimit != tt.wantLimit {
+				t.Errorf("Expected limit %q in the request, got %q", tt.wantLimit, sentLimit)
+			}
+		})
+	}
+}
+
+func TestMaxDataLimit(t *testing.T) {
+	oneGB, oneTB := int64(1000000000), int64(1000000000000)
+
+	tests := []struct {
+		name      string
+		max       int64
+		dataLimit *int64
+		hasError  bool
+	}{
+		{"no maximum", 0, &oneTB, false},
+		{"below the maximum", oneGB, &oneGB, false},
+		{"above the maximum", oneGB, &oneTB, true},
+		{"no limit", oneGB, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				if r.Method == http.MethodPut {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(api.AccessKey{ID: "1"})
+			}))
+			defer server.Close()
+
+			cm := &ConfigManager{
+				config: &Config{Servers: map[string]Server{
+					"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+				}},
+				options: Options{MaxDataLimit: tt.max},
+			}
+
+			_, err := cm.CreateAccessKey("test", CreateKeyOptions{DataLimit: tt.dataLimit})
+			if (err != nil) != tt.hasError {
+				t.Fatalf("CreateAccessKey() error = %v, hasError %v", err, tt.hasError)
+			}
+			if tt.hasError {
+				if !strings.Contains(err.Error(), "1.0 TB exceeds the maximum of 1.0 GB") {
+					t.Errorf("Expected the error to name both limits, got %v", err)
+				}
+				if got := requests.Load(); got != 0 {
+					t.Errorf("Expected no request to the server, got %d", got)
+				}
+			}
+
+			_, err = cm.EditAccessKey("test", EditKeyOptions{KeyID: "1", DataLimit: tt.dataLimit})
+			if (err != nil) != tt.hasError {
+				t.Errorf("EditAccessKey() error = %v, hasError %v", err, tt.hasError)
+			}
+		})
+	}
+}
+
+func TestMaxDataLimitUnits(t *testing.T) {
+	cm := &ConfigManager{options: Options{MaxDataLimit: 500 << 30, Units: UnitsIEC}}
+	err := cm.checkMaxDataLimit(1 << 40)
+	if err == nil || !strings.Contains(err.Error(), "1.0 TiB exceeds the maximum of 500 GiB") {
+		t.Errorf("Expected both limits in IEC units, got %v", err)
+	}
+}
+
+func TestCreateAccessKeyMethodFromServer(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []api.AccessKey
+		method     string
+		wantMethod string
+	}{
+		{"most common method", []api.AccessKey{{ID: "1", Method: "aes-256-gcm"}, {ID: "2", Method: "chacha20-ietf-poly1305"}, {ID: "3", Method: "chacha20-ietf-poly1305"}}, "", "chacha20-ietf-poly1305"},
+		{"tie picks the first alphabetically", []api.AccessKey{{ID: "1", Method: "chacha20-ietf-poly1305"}, {ID: "2", Method: "aes-256-gcm"}}, "", "aes-256-gcm"},
+		{"no keys leaves it to the server", nil, "", ""},
+		{"explicit method wins", []api.AccessKey{{ID: "1", Method: "aes-256-gcm"}}, "aes-128-gcm", "aes-128-gcm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sentMethod *string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: tt.keys})
+					return
+				}
+				var req map[string]string
+				json.NewDecoder(r.Body).Decode(&req)
+				method := req["method"]
+				sentMethod = &method
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(api.AccessKey{ID: "9"})
+			}))
+			defer server.Close()
+
+			cm := &ConfigManager{
+				config: &Config{Servers: map[string]Server{
+					"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+				}},
+			}
+
+			if _, err := cm.CreateAccessKey("test", CreateKeyOptions{Method: tt.method, MethodFromServer: true}); err != nil {
+				t.Fatalf("CreateAccessKey failed: %v", err)
+			}
+			if sentMethod == nil || *sentMethod != tt.wantMethod {
+				t.Errorf("Expected method %q in the request, got %v", tt.wantMethod, sentMethod)
+			}
+		})
+	}
+}
+
+func TestNameConflicts(t *testing.T) {
+	keys := []api.AccessKey{{ID: "1", Name: "alice"}, {ID: "2", Name: "bob"}, {ID: "3", Name: "bob"}, {ID: "4"}}
+
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"unique names", []string{"carol", "dave"}, nil},
+		{"existing name", []string{"alice"}, []string{"key name 'alice' is already used by the key with ID 1"}},
+		{"name of several keys", []string{"bob"}, []string{"key name 'bob' is already used by the keys with IDs 2, 3"}},
+		{"repeated name", []string{"carol", "carol"}, []string{"key name 'carol' is requested 2 times"}},
+		{"unnamed keys", []string{"", ""}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, conflict := range nameConflicts(tt.names, keys) {
+				got = append(got, conflict.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("nameConflicts(%q) = %q, want %q", tt.names, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAccessKeyUniqueName(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			created := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{{ID: "1", Name: "alice"}}})
+					return
+				}
+				created++
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(api.AccessKey{ID: "2", Name: "alice"})
+			}))
+			defer server.Close()
+
+			cm := &ConfigManager{
+				options: Options{Strict: strict},
+				config: &Config{Servers: map[string]Server{
+					"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+				}},
+			}
+
+			_, err := cm.CreateAccessKey("test", CreateKeyOptions{Name: "alice", UniqueName: true})
+			if strict {
+				if err == nil || !strings.Contains(err.Error(), "ID 1") || created != 0 {
+					t.Errorf("Expected an error naming key 1 and no key created, got %v and %d created", err, created)
+				}
+				return
+			}
+			if err != nil || created != 1 {
+				t.Errorf("Expected the key to be created with a warning, got %v and %d created", err, created)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// KeyDescription is everything known about one access key: the key itself, its usage and the
+// limit that applies to it, and the metadata kept in the local key cache. Sources other than the
+// key list are optional; the ones that could not be read are listed in Unavailable.
+type KeyDescription struct {
+	api.AccessKey `yaml:",inline"`
+	Server        string `json:"server"`
+	UsedBytes     *int64 `json:"usedBytes,omitempty"`
+	// EffectiveLimit is the key's own limit or else the server default; unset when unlimited
+	EffectiveLimit *int64 `json:"effectiveLimit,omitempty"`
+	LimitSource    string `json:"limitSource,omitempty"`
+	// RemainingBytes is the effective limit minus usage, floored at zero
+	RemainingBytes *int64     `json:"remainingBytes,omitempty"`
+	FirstSeen      *time.Time `json:"firstSeen,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
+	Unavailable    []string   `json:"unavailable,omitempty"`
+}
+
+// DescribeAccessKey finds a key by ID or name and joins it with the server's metrics, its
+// default data limit and the key cache
+func (cm *ConfigManager) DescribeAccessKey(serverName, keyID, keyName string) (*KeyDescription, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "error", err)
+		return nil, err
+	}
+
+	accessKey, err := findAccessKey(accessKeys, keyID, keyName)
+	if err != nil {
+		slog.Error("failed to resolve access key", "server", serverName, "keyID", keyID, "keyName", keyName, "error", err)
+		return nil, fmt.Errorf("%w on server '%s'", err, serverName)
+	}
+
+	description := &KeyDescription{AccessKey: *accessKey, Server: serverName}
+
+	var serverDefault *api.DataLimit
+	if serverInfo, err := apiClient.GetServerInfo(server.URL); err != nil {
+		slog.Debug("server info unavailable, ignoring the default data limit", "error", err)
+		description.Unavailable = append(description.Unavailable, "server default limit: "+err.Error())
+	} else {
+		serverDefault = serverInfo.AccessKeyDataLimit
+	}
+	if limit, source := effectiveDataLimit(*accessKey, serverDefault); limit != nil {
+		description.EffectiveLimit = &limit.Bytes
+		description.LimitSource = source
+	}
+
+	if metrics, err := apiClient.GetTransferMetrics(server.URL); err != nil {
+		slog.Debug("metrics unavailable, not showing usage", "error", err)
+		description.Unavailable = append(description.Unavailable, "usage: "+err.Error())
+	} else {
+		used := metrics.BytesTransferredByUserId[accessKey.ID]
+		description.UsedBytes = &used
+		if description.EffectiveLimit != nil {
+			remaining := max(*description.EffectiveLimit-used, 0)
+			description.RemainingBytes = &remaining
+		}
+	}
+
+	if cache, err := cm.loadKeyCache(serverName); err != nil {
+		slog.Debug("key cache unavailable", "error", err)
+		description.Unavailable = append(description.Unavailable, "key cache: "+err.Error())
+	} else if cached, ok := cache.Keys[accessKey.ID]; ok {
+		firstSeen := cached.FirstSeen
+		description.FirstSeen = &firstSeen
+		description.ExpiresAt = cached.ExpiresAt
+	}
+
+	return description, nil
+}
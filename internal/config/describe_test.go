@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestDescribeAccessKey(t *testing.T) {
+	metricsAvailable := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/server":
+			json.NewEncoder(w).Encode(api.OutlineServer{AccessKeyDataLimit: &api.DataLimit{Bytes: 1000}})
+		case "/access-keys":
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{
+				{ID: "1", Name: "alice", DataLimit: &api.DataLimit{Bytes: 300}},
+				{ID: "2", Name: "bob"},
+			}})
+		case "/metrics/transfer":
+			if !metricsAvailable {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(api.TransferMetrics{BytesTransferredByUserId: map[string]int64{"1": 500, "2": 400}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cm := &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+	firstSeen := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache := &KeyCache{Keys: map[string]CachedKey{"2": {AccessKey: api.AccessKey{ID: "2"}, FirstSeen: firstSeen}}}
+	if err := cm.saveKeyCache("test", cache); err != nil {
+		t.Fatalf("saveKeyCache failed: %v", err)
+	}
+
+	alice, err := cm.DescribeAccessKey("test", "1", "")
+	if err != nil {
+		t.Fatalf("DescribeAccessKey failed: %v", err)
+	}
+	if *alice.UsedBytes != 500 || *alice.EffectiveLimit != 300 || alice.LimitSource != "key" || *alice.RemainingBytes != 0 {
+		t.Errorf("Unexpected description of a key over its own limit: %+v", alice)
+	}
+	if alice.FirstSeen != nil || len(alice.Unavailable) != 0 {
+		t.Errorf("Expected an uncached key with every source available, got %+v", alice)
+	}
+
+	bob, err := cm.DescribeAccessKey("test", "", "bob")
+	if err != nil {
+		t.Fatalf("DescribeAccessKey failed: %v", err)
+	}
+	if *bob.EffectiveLimit != 1000 || bob.LimitSource != "server default" || *bob.RemainingBytes != 600 {
+		t.Errorf("Unexpected description of a key under the server default limit: %+v", bob)
+	}
+	if bob.FirstSeen == nil || !bob.FirstSeen.Equal(firstSeen) {
+		t.Errorf("Expected first seen %v from the cache, got %v", firstSeen, bob.FirstSeen)
+	}
+
+	metricsAvailable = false
+	bob, err = cm.DescribeAccessKey("test", "2", "")
+	if err != nil {
+		t.Fatalf("Expected missing metrics to be tolerated, got %v", err)
+	}
+	if bob.UsedBytes != nil || bob.RemainingBytes != nil || len(bob.Unavailable) != 1 {
+		t.Errorf("Expected usage to be reported as unavailable, got %+v", bob)
+	}
+
+	if _, err := cm.DescribeAccessKey("test", "9", ""); err == nil {
+		t.Error("Expected an error for an unknown key")
+	}
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// certExpiryWarning is how long before its expiry a certificate is reported with a warning
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// clockSkewMargin is how far outside a certificate's validity period the local clock has to be
+// before the clock itself is suspected
+const clockSkewMargin = 24 * time.Hour
+
+// CheckStatus is the outcome of a check; only CheckFail makes `servers test` fail
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+// CheckResult is the outcome of one step of `servers test`
+type CheckResult struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail"`
+}
+
+// CheckReport is every check `servers test` ran against a server
+type CheckReport struct {
+	Server string `json:"server"`
+	// Result is the worst status of the checks
+	Result CheckStatus   `json:"result"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// Failed counts the checks that failed
+func (r *CheckReport) Failed() int {
+	failed := 0
+	for _, check := range r.Checks {
+		if check.Status == CheckFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// TestServer runs connection, certificate and API checks against a server and reports each one
+func (cm *ConfigManager) TestServer(serverName string) (*CheckReport, error) {
+	if _, exists := cm.config.Servers[serverName]; !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	checks := cm.runServerChecks(serverName)
+	return &CheckReport{Server: serverName, Result: overallStatus(checks), Checks: checks}, nil
+}
+
+// overallStatus is the worst status of the checks
+func overallStatus(checks []CheckResult) CheckStatus {
+	result := CheckPass
+	for _, check := range checks {
+		switch check.Status {
+		case CheckFail:
+			return CheckFail
+		case CheckWarn:
+			result = CheckWarn
+		}
+	}
+	return result
+}
+
+// runServerChecks stops after the connect check fails since every later check needs a connection
+func (cm *ConfigManager) runServerChecks(serverName string) []CheckResult {
+	server := cm.config.Servers[serverName]
+
+	cert, err := api.InspectCertificate(server.URL, cm.clientOptions(server))
+	if err != nil {
+		return []CheckResult{{Name: "connect", Status: CheckFail, Detail: err.Error()}}
+	}
+	checks := []CheckResult{{Name: "connect", Status: CheckPass, Detail: RedactURL(server.URL)}}
+
+	fingerprint := CheckResult{Name: "fingerprint", Status: CheckFail}
+	switch {
+	case server.CertSha256 == "":
+		fingerprint.Detail = "no certSha256 configured, server presents " + cert.SHA256
+	case cert.SHA256 == strings.ToUpper(server.CertSha256):
+		fingerprint.Status = CheckPass
+		fingerprint.Detail = "matches the configured certSha256"
+	default:
+		fingerprint.Detail = "server presents " + cert.SHA256
+	}
+	checks = append(checks, fingerprint, certificateCheck(cert))
+
+	apiCheck := CheckResult{Name: "api", Status: CheckFail}
+	if health, err := cm.checkHealth(serverName); err != nil {
+		apiCheck.Detail = err.Error()
+	} else {
+		apiCheck.Status = CheckPass
+		apiCheck.Detail = fmt.Sprintf("version %s (%s)", health.Info.Version, health.Latency.Round(time.Millisecond))
+	}
+	return append(checks, apiCheck)
+}
+
+// certificateCheck explains whether the cert could be verified normally or has to be pinned, and
+// warns when it expires within certExpiryWarning. A cert that is not valid at the local time is a
+// failure, and the clock is suspected when it is far outside the validity period.
+func certificateCheck(cert *api.CertInfo) CheckResult {
+	check := CheckResult{Name: "certificate", Status: CheckPass}
+
+	switch cert.Kind {
+	case api.CertCASigned:
+		check.Detail = "CA-signed by " + cert.Issuer + ", standard verification would also work"
+		if !cert.HostMatch {
+			check.Detail += " if the server is reached by a name the cert is valid for"
+		}
+	case api.CertSelfSigned:
+		check.Detail = "self-signed, pinning is required"
+	default:
+		check.Detail = "issued by " + cert.Issuer + ", which is not a trusted root; pinning is required"
+	}
+
+	switch now := time.Now(); {
+	case now.Before(cert.NotBefore):
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("not valid until %s; %s", cert.NotBefore.Format(time.DateOnly), check.Detail)
+	case now.After(cert.NotAfter):
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("expired on %s; %s", cert.NotAfter.Format(time.DateOnly), check.Detail)
+	case now.Add(certExpiryWarning).After(cert.NotAfter):
+		check.Status = CheckWarn
+		check.Detail = fmt.Sprintf("expires on %s; %s", cert.NotAfter.Format(time.DateOnly), check.Detail)
+	}
+	if hint := clockSkewHint(cert, time.Now()); hint != "" {
+		check.Detail += "; " + hint
+	}
+	return check
+}
+
+// clockSkewHint suspects the local clock when it is more than clockSkewMargin outside the
+// validity period of cert, since a wrong clock makes a valid cert look expired or not yet valid
+func clockSkewHint(cert *api.CertInfo, now time.Time) string {
+	switch {
+	case now.Before(cert.NotBefore.Add(-clockSkewMargin)):
+		return fmt.Sprintf("the system clock reads %s, before the cert was issued; the clock appears to be wrong", now.Format(time.DateTime))
+	case now.After(cert.NotAfter.Add(clockSkewMargin)):
+		return fmt.Sprintf("the system clock reads %s; if the cert should still be valid, the clock appears to be wrong", now.Format(time.DateTime))
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestRunServerChecks(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.OutlineServer{Name: "test", Version: "1.9.0"})
+	}))
+	defer server.Close()
+
+	hash := sha256.Sum256(server.Certificate().Raw)
+	fingerprint := hex.EncodeToString(hash[:])
+
+	tests := []struct {
+		name       string
+		certSha256 string
+		expected   map[string]CheckStatus
+	}{
+		{"pinned cert", fingerprint, map[string]CheckStatus{"connect": CheckPass, "fingerprint": CheckPass, "certificate": CheckPass, "api": CheckPass}},
+		{"wrong pin", "ABCD", map[string]CheckStatus{"connect": CheckPass, "fingerprint": CheckFail, "certificate": CheckPass, "api": CheckFail}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &ConfigManager{
+				config: &Config{Servers: map[string]Server{
+					"test": {Name: "test", URL: server.URL, CertSha256: tt.certSha256},
+				}},
+			}
+
+			checks := cm.runServerChecks("test")
+			if len(checks) != len(tt.expected) {
+				t.Fatalf("Expected %d checks, got %+v", len(tt.expected), checks)
+			}
+			for _, check := range checks {
+				if check.Status != tt.expected[check.Name] {
+					t.Errorf("Check %s: expected status %s, got %+v", check.Name, tt.expected[check.Name], check)
+				}
+			}
+		})
+	}
+}
+
+func TestRunServerChecksUnreachable(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: url},
+		}},
+	}
+
+	checks := cm.runServerChecks("test")
+	if len(checks) != 1 || checks[0].Name != "connect" || checks[0].Status != CheckFail {
+		t.Errorf("Expected a single failed connect check, got %+v", checks)
+	}
+}
+
+func TestCertificateCheckExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		expected CheckStatus
+	}{
+		{"valid", time.Now().AddDate(1, 0, 0), CheckPass},
+		{"expires soon", time.Now().AddDate(0, 0, 7), CheckWarn},
+		{"expired", time.Now().AddDate(0, 0, -1), CheckFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := certificateCheck(&api.CertInfo{Kind: api.CertSelfSigned, NotBefore: time.Now().AddDate(-1, 0, 0), NotAfter: tt.notAfter})
+			if check.Status != tt.expected {
+				t.Errorf("Expected status %s, got %+v", tt.expected, check)
+			}
+		})
+	}
+}
+
+func TestOverallStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []CheckStatus
+		expected CheckStatus
+	}{
+		{"all pass", []CheckStatus{CheckPass, CheckPass}, CheckPass},
+		{"warning", []CheckStatus{CheckPass, CheckWarn}, CheckWarn},
+		{"failure wins", []CheckStatus{CheckFail, CheckWarn}, CheckFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checks []CheckResult
+			for _, status := range tt.statuses {
+				checks = append(checks, CheckResult{Status: status})
+			}
+			if got := overallStatus(checks); got != tt.expected {
+				t.Errorf("overallStatus(%v) = %s, want %s", tt.statuses, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCertificateCheckNotYetValid(t *testing.T) {
+	check := certificateCheck(&api.CertInfo{Kind: api.CertSelfSigned, NotBefore: time.Now().AddDate(1, 0, 0), NotAfter: time.Now().AddDate(10, 0, 0)})
+	if check.Status != CheckFail || !strings.Contains(check.Detail, "not valid until") || !strings.Contains(check.Detail, "clock appears to be wrong") {
+		t.Errorf("Expected a failure suspecting the clock, got %+v", check)
+	}
+}
+
+func TestClockSkewHint(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cert := &api.CertInfo{NotBefore: now.AddDate(-1, 0, 0), NotAfter: now.AddDate(1, 0, 0)}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantHint bool
+	}{
+		{"within the validity period", now, false},
+		{"just before issue", cert.NotBefore.Add(-time.Hour), false},
+		{"long before issue", cert.NotBefore.AddDate(0, 0, -2), true},
+		{"just after expiry", cert.NotAfter.Add(time.Hour), false},
+		{"long after expiry", cert.NotAfter.AddDate(3, 0, 0), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hint := clockSkewHint(cert, tt.now)
+			if (hint != "") != tt.wantHint {
+				t.Errorf("clockSkewHint() at %s = %q, want a hint: %v", tt.now, hint, tt.wantHint)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// KeyDiff compares the access keys of two servers by name
+type KeyDiff struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// OnlyInSource and OnlyInTarget list the key names found on one server only, sorted
+	OnlyInSource []string `json:"onlyInSource"`
+	OnlyInTarget []string `json:"onlyInTarget"`
+	Common       []string `json:"common"`
+	// UnnamedSource and UnnamedTarget count keys without a name, which cannot be compared
+	UnnamedSource int `json:"unnamedSource"`
+	UnnamedTarget int `json:"unnamedTarget"`
+}
+
+// DiffServerKeys compares the key names of two servers, to verify a migration between them
+func (cm *ConfigManager) DiffServerKeys(source, target string) (*KeyDiff, error) {
+	sourceKeys, err := cm.listKeys(source)
+	if err != nil {
+		return nil, err
+	}
+	targetKeys, err := cm.listKeys(target)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffKeyNames(sourceKeys, targetKeys)
+	diff.Source = source
+	diff.Target = target
+	return diff, nil
+}
+
+// listKeys returns the live access keys of a server
+func (cm *ConfigManager) listKeys(serverName string) ([]api.AccessKey, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "server", serverName, "error", err)
+		return nil, fmt.Errorf("server '%s': %w", serverName, err)
+	}
+	return accessKeys, nil
+}
+
+// diffKeyNames splits the key names of two servers into the ones found on either side only and
+// the ones on both. Names are compared exactly and duplicates count once.
+func diffKeyNames(source, target []api.AccessKey) *KeyDiff {
+	diff := &KeyDiff{
+		OnlyInSource: []string{},
+		OnlyInTarget: []string{},
+		Common:       []string{},
+	}
+
+	sourceNames, unnamed := keyNameSet(source)
+	diff.UnnamedSource = unnamed
+	targetNames, unnamed := keyNameSet(target)
+	diff.UnnamedTarget = unnamed
+
+	for name := range sourceNames {
+		if targetNames[name] {
+			diff.Common = append(diff.Common, name)
+		} else {
+			diff.OnlyInSource = append(diff.OnlyInSource, name)
+		}
+	}
+	for name := range targetNames {
+		if !sourceNames[name] {
+			diff.OnlyInTarget = append(diff.OnlyInTarget, name)
+		}
+	}
+
+	sort.Strings(diff.OnlyInSource)
+	sort.Strings(diff.OnlyInTarget)
+	sort.Strings(diff.Common)
+	return diff
+}
+
+func keyNameSet(keys []api.AccessKey) (map[string]bool, int) {
+	names := make(map[string]bool, len(keys))
+	unnamed := 0
+	for _, key := range keys {
+		if key.Name == "" {
+			unnamed++
+			continue
+		}
+		names[key.Name] = true
+	}
+	return names, unnamed
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestDiffKeyNames(t *testing.T) {
+	source := []api.AccessKey{
+		{ID: "1", Name: "alice"},
+		{ID: "2", Name: "bob"},
+		{ID: "3", Name: "bob"},
+		{ID: "4"},
+	}
+	target := []api.AccessKey{
+		{ID: "7", Name: "carol"},
+		{ID: "8", Name: "alice"},
+		{ID: "9", Name: "Bob"},
+	}
+
+	diff := diffKeyNames(source, target)
+
+	if want := []string{"bob"}; !slices.Equal(diff.OnlyInSource, want) {
+		t.Errorf("OnlyInSource = %q, want %q", diff.OnlyInSource, want)
+	}
+	if want := []string{"Bob", "carol"}; !slices.Equal(diff.OnlyInTarget, want) {
+		t.Errorf("OnlyInTarget = %q, want %q", diff.OnlyInTarget, want)
+	}
+	if want := []string{"alice"}; !slices.Equal(diff.Common, want) {
+		t.Errorf("Common = %q, want %q", diff.Common, want)
+	}
+	if diff.UnnamedSource != 1 || diff.UnnamedTarget != 0 {
+		t.Errorf("unnamed = %d, %d, want 1, 0", diff.UnnamedSource, diff.UnnamedTarget)
+	}
+}
+
+func TestDiffKeyNamesEmpty(t *testing.T) {
+	diff := diffKeyNames(nil, nil)
+	if diff.OnlyInSource == nil || diff.OnlyInTarget == nil || diff.Common == nil {
+		t.Error("Expected empty lists rather than nil, so JSON output has arrays")
+	}
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	neturl "net/url"
+	"os"
+	"slices"
+)
+
+// EditFunc opens the file at path for the user to edit and returns once they are done
+type EditFunc func(path string) error
+
+// ConfigEdit describes the outcome of EditConfig
+type ConfigEdit struct {
+	Path string
+	// BackupPath holds the config as it was before the edit
+	BackupPath string
+	// Changed is set when the edited config differs from the backup
+	Changed bool
+}
+
+// EditConfig lets the user edit the config file in place and validates the result. The previous
+// version is kept next to it as a backup; an invalid edit is offered for another round in the
+// editor, and restored from the backup when declined, so the file is never left invalid.
+func (cm *ConfigManager) EditConfig(edit EditFunc) (*ConfigEdit, error) {
+	original, err := os.ReadFile(cm.configPath)
+	if os.IsNotExist(err) {
+		// Give the editor a file to open that shows the expected layout
+		if err := cm.saveConfig(); err != nil {
+			slog.Error("failed to save config", "error", err)
+			return nil, err
+		}
+		original, err = os.ReadFile(cm.configPath)
+	}
+	if err != nil {
+		slog.Error("failed to read config file", "error", err)
+		return nil, err
+	}
+
+	result := &ConfigEdit{Path: cm.configPath, BackupPath: cm.configPath + ".bak"}
+	if err := os.WriteFile(result.BackupPath, original, configFileMode); err != nil {
+		slog.Error("failed to write config backup", "error", err)
+		return nil, err
+	}
+
+	var rejected []byte
+	for {
+		if err := edit(cm.configPath); err != nil {
+			slog.Error("editor failed", "error", err)
+			return nil, cm.restoreConfig(original, fmt.Errorf("editor failed: %w", err))
+		}
+
+		data, err := os.ReadFile(cm.configPath)
+		if err != nil {
+			slog.Error("failed to read config file", "error", err)
+			return nil, cm.restoreConfig(original, err)
+		}
+
+		config, err := parseConfig(data)
+		if err == nil {
+			err = cm.expandServerEnv(config)
+		}
+		if err == nil {
+			err = validateConfig(config)
+		}
+		if err == nil {
+			cm.config = config
+			result.Changed = !bytes.Equal(data, original)
+			if result.Changed {
+				if err := cm.backupConfig(original); err != nil {
+					slog.Warn("failed to back up the previous config, it is still in the .bak file", "error", err)
+				}
+			}
+			return result, nil
+		}
+
+		slog.Error("edited config is invalid", "error", err)
+		if rejected != nil && bytes.Equal(data, rejected) {
+			// Nothing changed since the last round, e.g. an editor that exits right away
+			return nil, cm.restoreConfig(original, err)
+		}
+		rejected = data
+
+		again, promptErr := cm.confirm(fmt.Sprintf("The config is invalid: %v. Edit it again? (no restores the previous version)", err))
+		if promptErr != nil || !again {
+			return nil, cm.restoreConfig(original, err)
+		}
+	}
+}
+
+// restoreConfig puts the original config back after a failed edit and returns editErr
+func (cm *ConfigManager) restoreConfig(original []byte, editErr error) error {
+	if err := os.WriteFile(cm.configPath, original, configFileMode); err != nil {
+		slog.Error("failed to restore config file", "error", err)
+		return fmt.Errorf("%w (the previous version could not be restored from %s.bak: %v)", editErr, cm.configPath, err)
+	}
+	slog.Warn("restored the previous config", "path", cm.configPath)
+	return fmt.Errorf("config not changed: %w", editErr)
+}
+
+// validateConfig checks what the commands that write the config enforce: every server has an
+// absolute URL and a certificate fingerprint, and groups only list configured servers
+func validateConfig(config *Config) error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(config.Servers)) {
+		server := config.Servers[name]
+		if server.URL == "" {
+			errs = append(errs, fmt.Errorf("server '%s': url is required", name))
+		} else if parsedURL, err := neturl.Parse(server.URL); err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
+			errs = append(errs, fmt.Errorf("server '%s': url is not an absolute URL", name))
+		}
+		if server.CertSha256 == "" {
+			errs = append(errs, fmt.Errorf("server '%s': certificate SHA256 is required", name))
+		}
+	}
+
+	for _, group := range slices.Sorted(maps.Keys(config.Groups)) {
+		for _, member := range config.Groups[group] {
+			if _, exists := config.Servers[member]; !exists {
+				errs = append(errs, fmt.Errorf("group '%s': server '%s' not found", group, member))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
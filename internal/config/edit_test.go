@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const validConfig = `servers:
+  eu-1:
+    name: eu-1
+    url: https://eu-1.example.com/Secret
+    certSha256: ABCD
+`
+
+// scriptedEditor returns an EditFunc that writes the given contents on each successive call
+func scriptedEditor(t *testing.T, edits ...string) (EditFunc, *int) {
+	t.Helper()
+	calls := 0
+	return func(path string) error {
+		if calls >= len(edits) {
+			t.Fatalf("editor opened %d times, expected %d", calls+1, len(edits))
+		}
+		calls++
+		return os.WriteFile(path, []byte(edits[calls-1]), 0600)
+	}, &calls
+}
+
+func TestEditConfig(t *testing.T) {
+	const edited = validConfig + `  eu-2:
+    name: eu-2
+    url: https://eu-2.example.com/Secret
+    certSha256: EF01
+`
+	const invalid = "servers:\n  eu-1:\n    url: https://eu-1.example.com/Secret\n"
+
+	tests := []struct {
+		name      string
+		options   Options
+		edits     []string
+		wantFile  string
+		wantErr   bool
+		wantCalls int
+	}{
+		{"valid edit is kept", Options{}, []string{edited}, edited, false, 1},
+		{"invalid edit is restored without input", Options{NoInput: true}, []string{invalid}, validConfig, true, 1},
+		{"unparsable edit is restored", Options{NoInput: true}, []string{"servers: ["}, validConfig, true, 1},
+		{"invalid edit can be fixed in another round", Options{AssumeYes: true}, []string{invalid, edited}, edited, false, 2},
+		{"unchanged invalid edit stops", Options{AssumeYes: true}, []string{invalid, invalid}, validConfig, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &ConfigManager{configPath: filepath.Join(t.TempDir(), "config.yaml"), options: tt.options}
+			if err := os.WriteFile(cm.configPath, []byte(validConfig), 0600); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+			editor, calls := scriptedEditor(t, tt.edits...)
+
+			edit, err := cm.EditConfig(editor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EditConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("Expected the editor to open %d times, got %d", tt.wantCalls, *calls)
+			}
+
+			data, _ := os.ReadFile(cm.configPath)
+			if string(data) != tt.wantFile {
+				t.Errorf("Config file is\n%s\nwant\n%s", data, tt.wantFile)
+			}
+			backup, _ := os.ReadFile(cm.configPath + ".bak")
+			if string(backup) != validConfig {
+				t.Errorf("Backup is\n%s\nwant the original config", backup)
+			}
+			if err == nil && (!edit.Changed || len(cm.config.Servers) != 2) {
+				t.Errorf("Expected the edit to be reported and loaded, got %+v with %d servers", edit, len(cm.config.Servers))
+			}
+		})
+	}
+}
+
+func TestEditConfigEditorFailure(t *testing.T) {
+	cm := &ConfigManager{configPath: filepath.Join(t.TempDir(), "config.yaml")}
+	if err := os.WriteFile(cm.configPath, []byte(validConfig), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	editorErr := errors.New("exit status 1")
+	_, err := cm.EditConfig(func(path string) error {
+		_ = os.WriteFile(path, []byte("half written"), 0600)
+		return editorErr
+	})
+	if !errors.Is(err, editorErr) {
+		t.Fatalf("Expected the editor error, got %v", err)
+	}
+	if data, _ := os.ReadFile(cm.configPath); string(data) != validConfig {
+		t.Errorf("Expected the original config to be restored, got\n%s", data)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"valid", Config{Servers: map[string]Server{"a": {URL: "https://a.example.com/S", CertSha256: "AB"}}}, false},
+		{"empty", Config{Servers: map[string]Server{}}, false},
+		{"missing url", Config{Servers: map[string]Server{"a": {CertSha256: "AB"}}}, true},
+		{"relative url", Config{Servers: map[string]Server{"a": {URL: "a.example.com/S", CertSha256: "AB"}}}, true},
+		{"missing cert", Config{Servers: map[string]Server{"a": {URL: "https://a.example.com/S"}}}, true},
+		{"group with unknown server", Config{
+			Servers: map[string]Server{"a": {URL: "https://a.example.com/S", CertSha256: "AB"}},
+			Groups:  map[string][]string{"eu": {"a", "b"}},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(&tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+)
+
+// envReference matches a ${NAME} reference to an environment variable. A bare $NAME is left
+// alone, since it can appear in a URL.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${NAME} references in value with the environment variables from lookup.
+// Unset variables are replaced with nothing and returned in missing.
+func expandEnv(value string, lookup func(string) (string, bool)) (expanded string, missing []string) {
+	expanded = envReference.ReplaceAllStringFunc(value, func(reference string) string {
+		name := envReference.FindStringSubmatch(reference)[1]
+		env, ok := lookup(name)
+		if !ok && !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+		return env
+	})
+	return expanded, missing
+}
+
+// expandServerEnv expands the environment variables referenced by the url and certSha256 of
+// every server, so a config can be committed without its secrets. The references are kept, and
+// written back by marshalConfig, as long as the field is not changed. An unset variable is an
+// error with Options.Strict and a warning otherwise.
+func (cm *ConfigManager) expandServerEnv(config *Config) error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(config.Servers)) {
+		server := config.Servers[name]
+		for _, field := range []struct {
+			key      string
+			value    *string
+			template *string
+		}{
+			{"url", &server.URL, &server.urlTemplate},
+			{"certSha256", &server.CertSha256, &server.certTemplate},
+		} {
+			if !envReference.MatchString(*field.value) {
+				continue
+			}
+			expanded, missing := expandEnv(*field.value, os.LookupEnv)
+			for _, variable := range missing {
+				if cm.options.Strict {
+					errs = append(errs, fmt.Errorf("server '%s': %s references ${%s}, which is not set", name, field.key, variable))
+					continue
+				}
+				slog.Warn("config references an unset environment variable, leaving it empty", "server", name, "field", field.key, "variable", variable)
+			}
+			*field.template, *field.value = *field.value, expanded
+		}
+		config.Servers[name] = server
+	}
+	return errors.Join(errs...)
+}
+
+// withEnvReferences returns a copy of config with the environment variable references put back
+// into the fields that still hold their expanded value
+func withEnvReferences(config *Config) *Config {
+	restored := *config
+	restored.Servers = make(map[string]Server, len(config.Servers))
+	for name, server := range config.Servers {
+		if server.urlTemplate != "" {
+			if expanded, _ := expandEnv(server.urlTemplate, os.LookupEnv); expanded == server.URL {
+				server.URL = server.urlTemplate
+			}
+		}
+		if server.certTemplate != "" {
+			if expanded, _ := expandEnv(server.certTemplate, os.LookupEnv); expanded == server.CertSha256 {
+				server.CertSha256 = server.certTemplate
+			}
+		}
+		restored.Servers[name] = server
+	}
+	return &restored
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{"HOST": "example.com", "SECRET": "abc", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		input       string
+		want        string
+		wantMissing string
+	}{
+		{"https://${HOST}:8443/${SECRET}", "https://example.com:8443/abc", ""},
+		{"https://example.com/$SECRET", "https://example.com/$SECRET", ""},
+		{"${EMPTY}", "", ""},
+		{"https://${HOST}/${UNSET}/${UNSET}", "https://example.com//", "UNSET"},
+		{"${NOT-A-NAME}", "${NOT-A-NAME}", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, missing := expandEnv(tt.input, lookup)
+			if got != tt.want || strings.Join(missing, ",") != tt.wantMissing {
+				t.Errorf("expandEnv(%q) = %q, %v, want %q, %q", tt.input, got, missing, tt.want, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestConfigEnvReferences(t *testing.T) {
+	t.Setenv("OUTLINE_TEST_SECRET", "SecretPath")
+	t.Setenv("OUTLINE_TEST_CERT", "ABCDEF")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	written := `servers:
+  eu:
+    name: eu
+    url: https://eu.example.com/${OUTLINE_TEST_SECRET}
+    certSha256: ${OUTLINE_TEST_CERT}
+  us:
+    name: us
+    url: https://us.example.com/${OUTLINE_TEST_SECRET}
+    certSha256: ${OUTLINE_TEST_CERT}
+`
+	if err := os.WriteFile(configPath, []byte(written), configFileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := &ConfigManager{configPath: configPath}
+	if err := cm.loadConfig(); err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	eu := cm.config.Servers["eu"]
+	if eu.URL != "https://eu.example.com/SecretPath" || eu.CertSha256 != "ABCDEF" {
+		t.Errorf("Expected the references to be expanded, got %+v", eu)
+	}
+
+	// Saving keeps the references of unchanged fields and writes changed ones as they are
+	us := cm.config.Servers["us"]
+	us.URL = "https://us.example.com/NewPath"
+	cm.config.Servers["us"] = us
+	if err := cm.saveConfig(); err != nil {
+		t.Fatalf("saveConfig failed: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(data)
+	if strings.Count(saved, "${OUTLINE_TEST_CERT}") != 2 || !strings.Contains(saved, "eu.example.com/${OUTLINE_TEST_SECRET}") {
+		t.Errorf("Expected the unchanged references to be kept, got:\n%s", saved)
+	}
+	if !strings.Contains(saved, "us.example.com/NewPath") || strings.Contains(saved, "SecretPath") || strings.Contains(saved, "emplate") {
+		t.Errorf("Expected only the changed URL to be written out, got:\n%s", saved)
+	}
+}
+
+func TestConfigEnvReferencesUnset(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	written := "servers:\n  eu:\n    name: eu\n    url: https://eu.example.com/${OUTLINE_TEST_UNSET}\n    certSha256: ABCDEF\n"
+	if err := os.WriteFile(configPath, []byte(written), configFileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	lenient := &ConfigManager{configPath: configPath}
+	if err := lenient.loadConfig(); err != nil {
+		t.Fatalf("Expected an unset variable to only warn, got %v", err)
+	}
+	if url := lenient.config.Servers["eu"].URL; url != "https://eu.example.com/" {
+		t.Errorf("Expected the unset variable to expand to nothing, got %q", url)
+	}
+
+	strict := &ConfigManager{configPath: configPath, options: Options{Strict: true}}
+	err := strict.loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "${OUTLINE_TEST_UNSET}") {
+		t.Errorf("Expected an error naming the unset variable with Strict, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// explainServerError turns errors with a known fix into a message saying what to do about them
+func explainServerError(serverName string, err error) error {
+	var mismatch *api.CertMismatchError
+	if errors.As(err, &mismatch) {
+		return fmt.Errorf("server '%s' presented a different certificate than the pinned one (expected %s, got %s); "+
+			"if the server's certificate was rotated, verify the new fingerprint and run 'outline-cli servers update %s --cert-sha256 %s'",
+			serverName, mismatch.Expected, mismatch.Got, serverName, mismatch.Got)
+	}
+	return err
+}
+
+// ExplainError does what explainServerError does for errors that no longer say which server they
+// came from, finding the server by its pinned fingerprint
+func (cm *ConfigManager) ExplainError(err error) error {
+	var rateLimited *api.RateLimitedError
+	if errors.As(err, &rateLimited) && cm.options.Client.Retries == 0 {
+		return fmt.Errorf("%w; pass --retries to wait and retry automatically", err)
+	}
+
+	var mismatch *api.CertMismatchError
+	if !errors.As(err, &mismatch) {
+		return err
+	}
+
+	var match string
+	for _, name := range cm.sortedServerNames() {
+		if strings.ToUpper(cm.config.Servers[name].CertSha256) != mismatch.Expected {
+			continue
+		}
+		if match != "" {
+			// Several servers share the certificate, so there is no single command to suggest
+			return err
+		}
+		match = name
+	}
+	if match == "" {
+		return err
+	}
+	return explainServerError(match, err)
+}
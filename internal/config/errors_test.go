@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestExplainError(t *testing.T) {
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"main":    {Name: "main", CertSha256: "aa11"},
+			"shared1": {Name: "shared1", CertSha256: "CC33"},
+			"shared2": {Name: "shared2", CertSha256: "CC33"},
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantHint  string
+		unchanged bool
+	}{
+		{
+			name:     "wrapped mismatch of a known server",
+			err:      fmt.Errorf("request failed: %w", &api.CertMismatchError{Expected: "AA11", Got: "BB22"}),
+			wantHint: "outline-cli servers update main --cert-sha256 BB22",
+		},
+		{
+			name:      "fingerprint pinned by several servers",
+			err:       &api.CertMismatchError{Expected: "CC33", Got: "BB22"},
+			unchanged: true,
+		},
+		{
+			name:      "unknown fingerprint",
+			err:       &api.CertMismatchError{Expected: "DD44", Got: "BB22"},
+			unchanged: true,
+		},
+		{
+			name:     "rate limited without retries",
+			err:      fmt.Errorf("failed to list access keys: %w", &api.RateLimitedError{}),
+			wantHint: "pass --retries",
+		},
+		{
+			name:      "other error",
+			err:       errors.New("connection refused"),
+			unchanged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cm.ExplainError(tt.err)
+			if tt.unchanged {
+				if got != tt.err {
+					t.Errorf("ExplainError() = %v, expected the error unchanged", got)
+				}
+				return
+			}
+			if !strings.Contains(got.Error(), tt.wantHint) {
+				t.Errorf("ExplainError() = %q, expected it to contain %q", got, tt.wantHint)
+			}
+		})
+	}
+}
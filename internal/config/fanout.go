@@ -0,0 +1,211 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// ServerResult is the outcome of an operation on a single server
+type ServerResult[T any] struct {
+	Server string
+	Value  T
+	Err    error
+}
+
+// fanOut runs fn against every named server and returns the results in the same order as names.
+// Servers are processed concurrently, unless failFast is set: then they are processed one by one
+// and the remaining servers are skipped after the first failure.
+func fanOut[T any](names []string, failFast bool, fn func(name string) (T, error)) []ServerResult[T] {
+	if failFast {
+		results := make([]ServerResult[T], 0, len(names))
+		for _, name := range names {
+			value, err := fn(name)
+			results = append(results, ServerResult[T]{Server: name, Value: value, Err: err})
+			if err != nil {
+				break
+			}
+		}
+		return results
+	}
+
+	results := make([]ServerResult[T], len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			value, err := fn(name)
+			results[i] = ServerResult[T]{Server: name, Value: value, Err: err}
+		}(i, name)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runBounded calls fn for every index below n using at most concurrency goroutines.
+// Indexes that have not started when ctx is cancelled are skipped.
+func runBounded(ctx context.Context, n, concurrency int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// FanOutResult holds the results of an operation run against the selected servers, in selection order
+type FanOutResult[T any] struct {
+	Results []ServerResult[T]
+	// Total is the number of selected servers; a fail-fast run that stopped early has fewer results
+	Total int
+	// Skipped lists the servers left out by SkipUnreachable; they do not count towards Total
+	Skipped []string
+}
+
+func newFanOutResult[T any](results []ServerResult[T], total int) *FanOutResult[T] {
+	return &FanOutResult[T]{Results: results, Total: total}
+}
+
+// Failed returns the failed servers, with errors that have a known fix explained
+func (r *FanOutResult[T]) Failed() []ServerResult[T] {
+	var failed []ServerResult[T]
+	for _, result := range r.Results {
+		if result.Err != nil {
+			result.Err = explainServerError(result.Server, result.Err)
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err summarizes the failures, or returns nil if every server succeeded.
+// A single-server run returns the server's error itself.
+func (r *FanOutResult[T]) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	if r.Total == 1 && len(r.Results) == 1 {
+		return failed[0].Err
+	}
+
+	if len(r.Results) < r.Total {
+		return fmt.Errorf("aborted after server '%s' failed (%d of %d servers skipped)", failed[0].Server, r.Total-len(r.Results), r.Total)
+	}
+	return fmt.Errorf("%d of %d servers failed", len(failed), r.Total)
+}
+
+// IsServerPattern reports whether a server name is a glob pattern selecting multiple servers
+func IsServerPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// ServerSelection picks the servers a fan-out command runs against: one server or glob pattern by Name,
+// every server with All, or the members of a config group
+type ServerSelection struct {
+	Name  string
+	All   bool
+	Group string
+	// FailFast stops at the first failing server instead of querying the rest
+	FailFast bool
+	// SkipUnreachable leaves out the servers that do not accept a connection within the dial timeout
+	SkipUnreachable bool
+}
+
+// Multiple reports whether the selection can match more than one server
+func (sel ServerSelection) Multiple() bool {
+	return sel.All || sel.Group != "" || IsServerPattern(sel.Name)
+}
+
+// selectServers resolves a selection into a list of configured server names
+func (cm *ConfigManager) selectServers(sel ServerSelection) ([]string, error) {
+	name := sel.Name
+	if sel.All {
+		names := cm.sortedServerNames()
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no servers configured")
+		}
+		return names, nil
+	}
+
+	if sel.Group != "" {
+		return cm.groupMembers(sel.Group)
+	}
+	if IsServerPattern(name) {
+		var names []string
+		for _, candidate := range cm.sortedServerNames() {
+			matched, err := path.Match(name, candidate)
+			if err != nil {
+				return nil, fmt.Errorf("invalid server pattern '%s': %v", name, err)
+			}
+			if matched {
+				names = append(names, candidate)
+			}
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no servers match '%s'", name)
+		}
+		return names, nil
+	}
+
+	if _, exists := cm.config.Servers[name]; !exists {
+		return nil, serverNotFound(name)
+	}
+	return []string{name}, nil
+}
+
+// selectFanOut resolves a selection like selectServers. With SkipUnreachable, every selected
+// server is first probed with a TCP dial, and those that do not answer within the dial timeout are
+// returned as skipped instead of selected.
+func (cm *ConfigManager) selectFanOut(sel ServerSelection) (names, skipped []string, err error) {
+	names, err = cm.selectServers(sel)
+	if err != nil || !sel.SkipUnreachable {
+		return names, nil, err
+	}
+
+	probes := fanOut(names, false, func(name string) (struct{}, error) {
+		server := cm.config.Servers[name]
+		return struct{}{}, api.ProbeServer(server.URL, cm.clientOptions(server))
+	})
+	var reachable []string
+	for _, probe := range probes {
+		if probe.Err != nil {
+			slog.Debug("skipping unreachable server", "server", probe.Server, "error", probe.Err)
+			skipped = append(skipped, probe.Server)
+			continue
+		}
+		reachable = append(reachable, probe.Server)
+	}
+
+	if len(reachable) == 0 {
+		return nil, skipped, fmt.Errorf("none of the %d selected servers is reachable", len(names))
+	}
+	return reachable, skipped, nil
+}
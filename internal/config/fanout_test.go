@@ -0,0 +1,357 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func newFleetManager(t *testing.T) *ConfigManager {
+	t.Helper()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/server":
+			json.NewEncoder(w).Encode(api.OutlineServer{Name: "Healthy", Version: "1.0.0"})
+		case "/metrics/transfer":
+			json.NewEncoder(w).Encode(api.TransferMetrics{BytesTransferredByUserId: map[string]int64{"1": 1024}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(healthy.Close)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(failing.Close)
+
+	return &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"a-healthy": {Name: "a-healthy", URL: healthy.URL, CertSha256: "dummy"},
+			"b-failing": {Name: "b-failing", URL: failing.URL, CertSha256: "dummy"},
+			"c-healthy": {Name: "c-healthy", URL: healthy.URL, CertSha256: "dummy"},
+		}},
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	fn := func(name string) (string, error) {
+		if name == "b" {
+			return "", errors.New("boom")
+		}
+		return "ok-" + name, nil
+	}
+
+	results := fanOut(names, false, fn)
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	for i, name := range names {
+		if results[i].Server != name {
+			t.Errorf("Expected result %d for server %s, got %s", i, name, results[i].Server)
+		}
+	}
+	if results[0].Value != "ok-a" || results[2].Value != "ok-c" {
+		t.Errorf("Expected successful values to be kept, got %q and %q", results[0].Value, results[2].Value)
+	}
+	if results[1].Err == nil {
+		t.Error("Expected error for server b")
+	}
+
+	if err := newFanOutResult(results, len(names)).Err(); err == nil {
+		t.Error("Expected Err to return an error")
+	}
+
+	failFast := fanOut(names, true, fn)
+	if len(failFast) != 2 {
+		t.Fatalf("Expected fail-fast to stop after 2 servers, got %d", len(failFast))
+	}
+	if err := newFanOutResult(failFast, len(names)).Err(); err == nil {
+		t.Error("Expected Err to return an error for fail-fast run")
+	}
+}
+
+func TestFanOutResultSuccess(t *testing.T) {
+	results := []ServerResult[int]{{Server: "a", Value: 1}, {Server: "b", Value: 2}}
+	if err := newFanOutResult(results, len(results)).Err(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestFanOutResultSingleServer(t *testing.T) {
+	original := errors.New("connection refused")
+	results := []ServerResult[int]{{Server: "a", Err: original}}
+	if err := newFanOutResult(results, 1).Err(); err != original {
+		t.Errorf("Expected original error for single server, got %v", err)
+	}
+}
+
+func TestCheckHealthPartialFailure(t *testing.T) {
+	cm := newFleetManager(t)
+
+	result, err := cm.CheckHealth(ServerSelection{All: true}, HealthOptions{})
+	if err != nil {
+		t.Fatalf("CheckHealth() unexpected error: %v", err)
+	}
+	if result.Err() == nil {
+		t.Error("Expected error when one server is failing")
+	}
+
+	result, err = cm.CheckHealth(ServerSelection{Name: "a-healthy"}, HealthOptions{})
+	if err != nil || result.Err() != nil {
+		t.Errorf("Expected healthy server to pass, got %v, %v", err, result.Err())
+	}
+
+	results := fanOut([]string{"a-healthy", "b-failing", "c-healthy"}, false, cm.checkHealth)
+	if results[0].Err != nil || results[2].Err != nil {
+		t.Errorf("Expected healthy servers to succeed, got %v and %v", results[0].Err, results[2].Err)
+	}
+	if results[1].Err == nil {
+		t.Error("Expected failing server to report an error")
+	}
+}
+
+func TestGetMetricsPartialFailure(t *testing.T) {
+	cm := newFleetManager(t)
+
+	result, err := cm.GetMetrics(ServerSelection{All: true})
+	if err != nil {
+		t.Fatalf("GetMetrics() unexpected error: %v", err)
+	}
+	if result.Err() == nil {
+		t.Error("Expected error when one server is failing")
+	}
+	if failed := result.Failed(); len(failed) != 1 || failed[0].Server != "b-failing" {
+		t.Errorf("Expected only b-failing to fail, got %+v", failed)
+	}
+
+	result, err = cm.GetMetrics(ServerSelection{Name: "c-healthy"})
+	if err != nil || result.Err() != nil {
+		t.Errorf("Expected healthy server to succeed, got %v, %v", err, result.Err())
+	}
+
+	if _, err := cm.GetMetrics(ServerSelection{Name: "missing"}); err == nil {
+		t.Error("Expected error for unknown server")
+	}
+}
+
+func TestSelectServers(t *testing.T) {
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"prod-eu":  {Name: "prod-eu"},
+			"prod-us":  {Name: "prod-us"},
+			"staging":  {Name: "staging"},
+			"prod*odd": {Name: "prod*odd"},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		all      bool
+		expected []string
+		hasError bool
+	}{
+		{"exact name", "staging", false, []string{"staging"}, false},
+		{"all servers", "", true, []string{"prod*odd", "prod-eu", "prod-us", "staging"}, false},
+		{"glob prefix", "prod-*", false, []string{"prod-eu", "prod-us"}, false},
+		{"single character glob", "prod-?s", false, []string{"prod-us"}, false},
+		{"character class", "[ps]*", false, []string{"prod*odd", "prod-eu", "prod-us", "staging"}, false},
+
+		// Invalid inputs
+		{"unknown server", "missing", false, nil, true},
+		{"no match", "dev-*", false, nil, true},
+		{"malformed pattern", "prod-[", false, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := cm.selectServers(ServerSelection{Name: tt.input, All: tt.all})
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("selectServers(%q) expected error, got %v", tt.input, names)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("selectServers(%q) unexpected error: %v", tt.input, err)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("selectServers(%q) = %v, want %v", tt.input, names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSkipUnreachable(t *testing.T) {
+	// A closed listener leaves a port that refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	downURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	cm := newFleetManager(t)
+	cm.config.Servers["d-down"] = Server{Name: "d-down", URL: downURL, CertSha256: "dummy"}
+
+	result, err := cm.CheckHealth(ServerSelection{All: true, SkipUnreachable: true}, HealthOptions{})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if strings.Join(result.Skipped, ",") != "d-down" || result.Total != 3 {
+		t.Errorf("Expected d-down skipped from 3 servers, got skipped %v of %d", result.Skipped, result.Total)
+	}
+	// Reachable servers are still queried, whatever their API answers
+	if len(result.Failed()) != 1 || result.Failed()[0].Server != "b-failing" {
+		t.Errorf("Expected only b-failing to fail, got %+v", result.Failed())
+	}
+
+	if _, err := cm.CheckHealth(ServerSelection{Name: "d-*", SkipUnreachable: true}, HealthOptions{}); err == nil {
+		t.Error("Expected an error when no selected server is reachable")
+	}
+
+	result, err = cm.CheckHealth(ServerSelection{All: true}, HealthOptions{})
+	if err != nil {
+		t.Fatalf("CheckHealth failed: %v", err)
+	}
+	if len(result.Skipped) != 0 || len(result.Failed()) != 2 {
+		t.Errorf("Expected nothing skipped without SkipUnreachable, got skipped %v and %d failures", result.Skipped, len(result.Failed()))
+	}
+}
+
+func TestRunBounded(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	seen := make([]bool, 20)
+
+	runBounded(context.Background(), len(seen), 3, func(i int) {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		seen[i] = true
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+
+	if maxRunning > 3 {
+		t.Errorf("Expected at most 3 concurrent calls, got %d", maxRunning)
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Errorf("Index %d was never processed", i)
+		}
+	}
+}
+
+func TestRunBoundedCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var mu sync.Mutex
+	processed := 0
+	runBounded(ctx, 100, 1, func(i int) {
+		mu.Lock()
+		processed++
+		mu.Unlock()
+		if i == 4 {
+			cancel()
+		}
+	})
+
+	if processed >= 100 {
+		t.Errorf("Expected cancellation to skip remaining work, processed %d", processed)
+	}
+}
+
+func TestCheckHealthDataPort(t *testing.T) {
+	open, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer open.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	closed.Close()
+
+	newServer := func(port int) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(api.OutlineServer{Version: "1.0.0", HostnameForAccessKeys: "127.0.0.1", PortForNewAccessKeys: port})
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	cm := &ConfigManager{config: &Config{Servers: map[string]Server{
+		"a-open":    {Name: "a-open", URL: newServer(open.Addr().(*net.TCPAddr).Port).URL, CertSha256: "dummy"},
+		"b-closed":  {Name: "b-closed", URL: newServer(closed.Addr().(*net.TCPAddr).Port).URL, CertSha256: "dummy"},
+		"c-no-port": {Name: "c-no-port", URL: newServer(0).URL, CertSha256: "dummy"},
+	}}}
+
+	result, err := cm.CheckHealth(ServerSelection{All: true}, HealthOptions{CheckDataPort: true})
+	if err != nil || result.Err() != nil {
+		t.Fatalf("Expected a closed data port not to fail the API check, got %v, %v", err, result.Err())
+	}
+	wantOpen := map[string]bool{"a-open": true, "b-closed": false, "c-no-port": false}
+	for _, server := range result.Results {
+		if server.Value.DataPort == "" {
+			t.Errorf("%s: expected the data port to be checked", server.Server)
+		}
+		if (server.Value.DataPortErr == nil) != wantOpen[server.Server] {
+			t.Errorf("%s: expected open = %v, got %v", server.Server, wantOpen[server.Server], server.Value.DataPortErr)
+		}
+	}
+
+	result, _ = cm.CheckHealth(ServerSelection{Name: "a-open"}, HealthOptions{})
+	if result.Results[0].Value.DataPort != "" {
+		t.Error("Expected the data port to be left unchecked by default")
+	}
+}
+
+func TestSelectionLogger(t *testing.T) {
+	tests := []struct {
+		name string
+		sel  ServerSelection
+		want string
+	}{
+		{"single server", ServerSelection{Name: "eu-1"}, "server=eu-1 op=GetMetrics"},
+		{"all servers", ServerSelection{All: true}, "selection=all op=GetMetrics"},
+		{"group", ServerSelection{Group: "europe"}, "group=europe op=GetMetrics"},
+		{"pattern", ServerSelection{Name: "eu-*"}, "pattern=eu-* op=GetMetrics"},
+	}
+
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+			selectionLogger(tt.sel, "GetMetrics").Info("fetching")
+			if !strings.Contains(buf.String(), tt.want) || (tt.sel.Name != "eu-1" && strings.Contains(buf.String(), "server=")) {
+				t.Errorf("Expected the record to carry %q and no server, got %q", tt.want, buf.String())
+			}
+		})
+	}
+}
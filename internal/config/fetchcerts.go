@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// FetchCertsOptions controls how `servers fetch-certs` fetches certificates
+type FetchCertsOptions struct {
+	// Parallel is the number of servers dialed at the same time
+	Parallel int
+}
+
+// FetchCertResult is the certificate a server without a pinned fingerprint presented
+type FetchCertResult struct {
+	Name   string       `json:"name"`
+	SHA256 string       `json:"sha256,omitempty"`
+	Kind   api.CertKind `json:"kind,omitempty"`
+	// Saved is set when the fingerprint was written to the config
+	Saved bool   `json:"saved"`
+	Error string `json:"error,omitempty"`
+}
+
+// FetchMissingCerts fetches the certificate of every server without a certSha256 and, with
+// Options.AssumeYes, pins the presented fingerprints with a single save. The certificates are not
+// verified, so the fingerprints must be checked before they are trusted. The results are also
+// returned when some servers could not be reached.
+func (cm *ConfigManager) FetchMissingCerts(opts FetchCertsOptions) ([]FetchCertResult, error) {
+	var names []string
+	for _, name := range cm.sortedServerNames() {
+		if cm.config.Servers[name].CertSha256 == "" {
+			names = append(names, name)
+		}
+	}
+
+	results := make([]FetchCertResult, len(names))
+	progress := cm.startProgress("Fetching certificates", len(names))
+	runBounded(context.Background(), len(names), opts.Parallel, func(i int) {
+		defer progress.tick()
+		results[i].Name = names[i]
+		server := cm.config.Servers[names[i]]
+		cert, err := api.InspectCertificate(server.URL, cm.clientOptions(server))
+		if err != nil {
+			results[i].Error = err.Error()
+			return
+		}
+		results[i].SHA256 = cert.SHA256
+		results[i].Kind = cert.Kind
+	})
+	progress.finish()
+
+	fetched := 0
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+		fetched++
+		if cm.options.AssumeYes {
+			server := cm.config.Servers[results[i].Name]
+			server.CertSha256 = results[i].SHA256
+			cm.config.Servers[results[i].Name] = server
+			results[i].Saved = true
+		}
+	}
+	if cm.options.AssumeYes && fetched > 0 {
+		if err := cm.saveConfig(); err != nil {
+			slog.Error("failed to save config", "error", err)
+			return nil, err
+		}
+	}
+
+	if fetched < len(results) {
+		return results, fmt.Errorf("failed to fetch the certificate of %d of %d servers", len(results)-fetched, len(results))
+	}
+	return results, nil
+}
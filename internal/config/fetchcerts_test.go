@@ -0,0 +1,59 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchMissingCerts(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	down := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	downURL := down.URL
+	down.Close()
+
+	hash := sha256.Sum256(server.Certificate().Raw)
+	fingerprint := strings.ToUpper(hex.EncodeToString(hash[:]))
+
+	for _, assumeYes := range []bool{false, true} {
+		cm := &ConfigManager{
+			configPath: filepath.Join(t.TempDir(), "config.yaml"),
+			options:    Options{AssumeYes: assumeYes},
+			config: &Config{Servers: map[string]Server{
+				"down":   {Name: "down", URL: downURL},
+				"legacy": {Name: "legacy", URL: server.URL},
+				"pinned": {Name: "pinned", URL: server.URL, CertSha256: "AA11"},
+			}},
+		}
+
+		results, err := cm.FetchMissingCerts(FetchCertsOptions{Parallel: 2})
+		if err == nil {
+			t.Error("Expected an error for the unreachable server")
+		}
+		if len(results) != 2 || results[0].Name != "down" || results[1].Name != "legacy" {
+			t.Fatalf("Expected results for the two servers without a fingerprint, got %+v", results)
+		}
+		if results[0].Error == "" || results[0].Saved {
+			t.Errorf("Expected the unreachable server to fail, got %+v", results[0])
+		}
+		if results[1].SHA256 != fingerprint || results[1].Saved != assumeYes {
+			t.Errorf("Expected fingerprint %s saved = %v, got %+v", fingerprint, assumeYes, results[1])
+		}
+
+		want := ""
+		if assumeYes {
+			want = fingerprint
+		}
+		if got := cm.config.Servers["legacy"].CertSha256; got != want {
+			t.Errorf("With AssumeYes = %v, expected certSha256 %q in the config, got %q", assumeYes, want, got)
+		}
+		if cm.config.Servers["pinned"].CertSha256 != "AA11" {
+			t.Error("Expected a pinned fingerprint to be left alone")
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// AddGroup creates a server group or adds servers to an existing one
+func (cm *ConfigManager) AddGroup(group string, servers []string) error {
+	for _, name := range servers {
+		if _, exists := cm.config.Servers[name]; !exists {
+			slog.Error("server not found", "server", name)
+			return serverNotFound(name)
+		}
+	}
+
+	if cm.config.Groups == nil {
+		cm.config.Groups = make(map[string][]string)
+	}
+	members := cm.config.Groups[group]
+	for _, name := range servers {
+		if !slices.Contains(members, name) {
+			members = append(members, name)
+		}
+	}
+	cm.config.Groups[group] = members
+
+	if err := cm.saveConfig(); err != nil {
+		slog.Error("failed to save config", "error", err)
+		return err
+	}
+
+	slog.Debug("group updated", "group", group, "members", members)
+	return nil
+}
+
+// RemoveGroup deletes a server group; the servers themselves are kept
+func (cm *ConfigManager) RemoveGroup(group string) error {
+	if _, exists := cm.config.Groups[group]; !exists {
+		slog.Error("group not found", "group", group)
+		return fmt.Errorf("group '%s' not found", group)
+	}
+
+	delete(cm.config.Groups, group)
+
+	if err := cm.saveConfig(); err != nil {
+		slog.Error("failed to save config", "error", err)
+		return err
+	}
+
+	slog.Debug("group removed", "group", group)
+	return nil
+}
+
+// Group is a named set of servers
+type Group struct {
+	Name    string   `json:"name"`
+	Servers []string `json:"servers"`
+}
+
+// ListGroups returns every group with its members, sorted by name
+func (cm *ConfigManager) ListGroups() []Group {
+	groups := make([]Group, 0, len(cm.config.Groups))
+	for name, members := range cm.config.Groups {
+		groups = append(groups, Group{Name: name, Servers: members})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// serverGroups returns the names of the groups a server belongs to, sorted
+func (cm *ConfigManager) serverGroups(name string) []string {
+	groups := []string{}
+	for group, members := range cm.config.Groups {
+		if slices.Contains(members, name) {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// groupMembers returns the servers of a group, failing if the group lists servers that were deleted
+func (cm *ConfigManager) groupMembers(group string) ([]string, error) {
+	members, exists := cm.config.Groups[group]
+	if !exists {
+		return nil, fmt.Errorf("group '%s' not found", group)
+	}
+
+	var missing []string
+	for _, name := range members {
+		if _, exists := cm.config.Servers[name]; !exists {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("group '%s' lists servers that no longer exist: %s", group, strings.Join(missing, ", "))
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("group '%s' has no servers", group)
+	}
+
+	return members, nil
+}
+
+// renameGroupMember keeps group membership intact when a server is renamed
+func (cm *ConfigManager) renameGroupMember(oldName, newName string) {
+	for group, members := range cm.config.Groups {
+		if i := slices.Index(members, oldName); i >= 0 {
+			members[i] = newName
+			cm.config.Groups[group] = members
+		}
+	}
+}
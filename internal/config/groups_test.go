@@ -0,0 +1,86 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newGroupManager(t *testing.T) *ConfigManager {
+	t.Helper()
+	return &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config: &Config{Servers: map[string]Server{
+			"eu-1": {Name: "eu-1", URL: "https://eu-1.example.com/Secret"},
+			"eu-2": {Name: "eu-2", URL: "https://eu-2.example.com/Secret"},
+			"us-1": {Name: "us-1", URL: "https://us-1.example.com/Secret"},
+		}},
+	}
+}
+
+func TestAddGroup(t *testing.T) {
+	cm := newGroupManager(t)
+
+	if err := cm.AddGroup("eu", []string{"eu-1"}); err != nil {
+		t.Fatalf("AddGroup failed: %v", err)
+	}
+	if err := cm.AddGroup("eu", []string{"eu-2", "eu-1"}); err != nil {
+		t.Fatalf("AddGroup to existing group failed: %v", err)
+	}
+	if got := strings.Join(cm.config.Groups["eu"], ","); got != "eu-1,eu-2" {
+		t.Errorf("Expected members eu-1,eu-2 without duplicates, got %s", got)
+	}
+
+	if err := cm.AddGroup("eu", []string{"eu-3"}); err == nil {
+		t.Error("Expected error adding an unknown server to a group")
+	}
+}
+
+func TestSelectServersByGroup(t *testing.T) {
+	cm := newGroupManager(t)
+	cm.config.Groups = map[string][]string{
+		"eu":    {"eu-2", "eu-1"},
+		"stale": {"us-1", "us-2"},
+	}
+
+	tests := []struct {
+		name     string
+		group    string
+		expected []string
+		hasError bool
+	}{
+		{"group members", "eu", []string{"eu-2", "eu-1"}, false},
+		{"unknown group", "asia", nil, true},
+		{"member no longer exists", "stale", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := cm.selectServers(ServerSelection{Group: tt.group})
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("selectServers(group %q) expected error, got %v", tt.group, names)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectServers(group %q) unexpected error: %v", tt.group, err)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("selectServers(group %q) = %v, want %v", tt.group, names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenameServerUpdatesGroups(t *testing.T) {
+	cm := newGroupManager(t)
+	cm.config.Groups = map[string][]string{"eu": {"eu-1", "eu-2"}}
+
+	if err := cm.UpdateServer("eu-1", ServerUpdate{NewName: "eu-west", LocalOnly: true}); err != nil {
+		t.Fatalf("UpdateServer failed: %v", err)
+	}
+	if got := strings.Join(cm.config.Groups["eu"], ","); got != "eu-west,eu-2" {
+		t.Errorf("Expected group to follow the rename, got %s", got)
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	neturl "net/url"
+	"strconv"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// HealthOptions selects the checks of CheckHealth beyond the management API
+type HealthOptions struct {
+	// CheckDataPort also dials the host and port that new access keys connect to
+	CheckDataPort bool
+}
+
+// ServerHealth is the answer of a server's management API and how long it took
+type ServerHealth struct {
+	Info    *api.OutlineServer
+	Latency time.Duration
+	// DataPort is the host:port new access keys connect to; only set when it was checked
+	DataPort string
+	// DataPortErr is why the data port did not accept a connection
+	DataPortErr error
+}
+
+// checkHealth queries the management API of a single server
+func (cm *ConfigManager) checkHealth(serverName string) (ServerHealth, error) {
+	server := cm.config.Servers[serverName]
+
+	apiClient, err := cm.getOneShotAPIClient(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return ServerHealth{}, err
+	}
+
+	start := time.Now()
+	serverInfo, err := apiClient.GetServerInfo(server.URL)
+	if err != nil {
+		slog.Debug("health check failed", "server", serverName, "error", err)
+		return ServerHealth{}, err
+	}
+
+	return ServerHealth{Info: serverInfo, Latency: time.Since(start)}, nil
+}
+
+// checkDataPort dials the address the server gives to new access keys. Servers that report no
+// hostname for keys are dialed at the host of their management URL, as the keys would be.
+func (cm *ConfigManager) checkDataPort(serverName string, health *ServerHealth) {
+	server := cm.config.Servers[serverName]
+	host := health.Info.HostnameForAccessKeys
+	if host == "" {
+		if parsedURL, err := neturl.Parse(server.URL); err == nil {
+			host = parsedURL.Hostname()
+		}
+	}
+	port := health.Info.PortForNewAccessKeys
+
+	health.DataPort = net.JoinHostPort(host, strconv.Itoa(port))
+	if port == 0 {
+		health.DataPortErr = fmt.Errorf("server reports no port for new access keys")
+		return
+	}
+	health.DataPortErr = api.ProbeAddress(host, port, cm.clientOptions(server))
+	if health.DataPortErr != nil {
+		slog.Debug("data port is closed", "server", serverName, "address", health.DataPort, "error", health.DataPortErr)
+	}
+}
+
+// CheckHealth queries the management API of the selected servers
+func (cm *ConfigManager) CheckHealth(sel ServerSelection, opts HealthOptions) (*FanOutResult[ServerHealth], error) {
+	names, skipped, err := cm.selectFanOut(sel)
+	if err != nil {
+		slog.Error("failed to select servers", "server", sel.Name, "group", sel.Group, "error", err)
+		return nil, err
+	}
+
+	progress := cm.startProgress("Checking servers", len(names))
+	defer progress.finish()
+	check := func(serverName string) (ServerHealth, error) {
+		health, err := cm.checkHealth(serverName)
+		if err == nil && opts.CheckDataPort {
+			cm.checkDataPort(serverName, &health)
+		}
+		return health, err
+	}
+	result := newFanOutResult(fanOut(names, sel.FailFast, withProgress(progress, check)), len(names))
+	result.Skipped = skipped
+	return result, nil
+}
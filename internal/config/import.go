@@ -0,0 +1,136 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// ImportOptions controls how `servers import` adds servers
+type ImportOptions struct {
+	// Verify checks that every server answers with its pinned certificate before it is added
+	Verify bool
+	// Parallel is the number of servers verified at the same time
+	Parallel int
+}
+
+// ImportResult is the outcome of importing one server
+type ImportResult struct {
+	Name     string `json:"name"`
+	Imported bool   `json:"imported"`
+	Version  string `json:"version,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ImportServers adds the servers of a config file, or a file with the same `servers:` layout.
+// Servers that are invalid, already configured or fail verification are skipped, the rest are
+// written to the config with a single save. The results are also returned when servers were skipped.
+func (cm *ConfigManager) ImportServers(path string, opts ImportOptions) ([]ImportResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Error("failed to read import file", "error", err)
+		return nil, err
+	}
+
+	var imported Config
+	if err := yaml.Unmarshal(data, &imported); err != nil {
+		slog.Error("failed to parse import file", "error", err)
+		return nil, fmt.Errorf("invalid import file: %w", err)
+	}
+	dropKnownFields(&imported)
+	if len(imported.Servers) == 0 {
+		return nil, fmt.Errorf("no servers found in '%s'", path)
+	}
+
+	names := make([]string, 0, len(imported.Servers))
+	for name := range imported.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]ImportResult, len(names))
+	for i, name := range names {
+		results[i] = ImportResult{Name: name}
+		if err := cm.checkImport(name, imported.Servers[name]); err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+
+	if opts.Verify {
+		progress := cm.startProgress("Verifying servers", len(names))
+		runBounded(context.Background(), len(names), opts.Parallel, func(i int) {
+			defer progress.tick()
+			if results[i].Error != "" {
+				return
+			}
+			server := imported.Servers[names[i]]
+			clientOpts := cm.clientOptions(server)
+			clientOpts.DisableKeepAlives = true
+			info, err := api.NewAPIClientWithOptions(server.CertSha256, clientOpts).GetServerInfo(server.URL)
+			if err != nil {
+				results[i].Error = explainServerError(names[i], err).Error()
+				return
+			}
+			results[i].Version = info.Version
+		})
+	}
+
+	for i, name := range names {
+		if results[i].Error != "" {
+			continue
+		}
+		server := imported.Servers[name]
+		// Checked here rather than up front so that two servers of the import sharing a URL are caught too
+		if err := cm.checkDuplicateURL(serverLogger(name, "ImportServers"), name, server.URL); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		server.Name = name
+		cm.config.Servers[name] = server
+		results[i].Imported = true
+	}
+
+	added := 0
+	for _, result := range results {
+		if result.Imported {
+			added++
+		}
+	}
+	if added > 0 {
+		if err := cm.saveConfig(); err != nil {
+			slog.Error("failed to save config", "error", err)
+			return nil, err
+		}
+	}
+
+	if added < len(results) {
+		return results, fmt.Errorf("%d of %d servers were not imported", len(results)-added, len(results))
+	}
+	return results, nil
+}
+
+// checkImport rejects entries that could not be added with `servers add` either
+func (cm *ConfigManager) checkImport(name string, server Server) error {
+	if err := ValidateServerName(name); err != nil {
+		return err
+	}
+	if _, exists := cm.config.Servers[name]; exists {
+		return fmt.Errorf("server '%s' already exists", name)
+	}
+	if server.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if err := ValidateServerURL(server.URL); err != nil {
+		return err
+	}
+	if server.CertSha256 == "" {
+		return fmt.Errorf("certificate SHA256 is required")
+	}
+	return ValidateCertSHA256(server.CertSha256)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestImportServers(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.OutlineServer{Name: "Good", Version: "1.2.3"})
+	}))
+	defer good.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	dir := t.TempDir()
+	importPath := filepath.Join(dir, "import.yaml")
+	importFile := "servers:\n" +
+		"  good:\n    url: " + good.URL + "\n    certSha256: AA11\n" +
+		"  failing:\n    url: " + failing.URL + "\n    certSha256: AA11\n" +
+		"  existing:\n    url: https://other.example.com/Secret\n    certSha256: AA11\n" +
+		"  nocert:\n    url: https://nocert.example.com/Secret\n" +
+		"  badurl:\n    url: nocert.example.com/Secret\n    certSha256: AA11\n" +
+		"  badcert:\n    url: https://badcert.example.com/Secret\n    certSha256: not-a-fingerprint\n"
+	if err := os.WriteFile(importPath, []byte(importFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		verify       bool
+		wantImported []string
+		wantSkipped  []string
+	}{
+		{"with verification", true, []string{"good"}, []string{"failing", "nocert", "badurl", "badcert"}},
+		{"without verification", false, []string{"good", "failing"}, []string{"nocert", "badurl", "badcert"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &ConfigManager{
+				configPath: filepath.Join(dir, tt.name+".yaml"),
+				config: &Config{Servers: map[string]Server{
+					"existing": {Name: "existing", URL: "https://existing.example.com/Secret", CertSha256: "dummy"},
+				}},
+			}
+
+			_, err := cm.ImportServers(importPath, ImportOptions{Verify: tt.verify, Parallel: 2})
+			if err == nil {
+				t.Error("ImportServers() expected error for skipped servers, got nil")
+			}
+
+			for _, name := range tt.wantImported {
+				if got := cm.config.Servers[name]; got.Name != name {
+					t.Errorf("Expected server %q to be imported, got %+v", name, got)
+				}
+			}
+			for _, name := range tt.wantSkipped {
+				if _, exists := cm.config.Servers[name]; exists {
+					t.Errorf("Expected server %q to be skipped", name)
+				}
+			}
+			if got := cm.config.Servers["existing"].URL; got != "https://existing.example.com/Secret" {
+				t.Errorf("Expected existing server to be left alone, got URL %q", got)
+			}
+			if _, err := os.Stat(cm.configPath); err != nil {
+				t.Errorf("Expected config to be saved: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// KeySpec is a key to recreate. IDs, ports and access URLs are assigned by the server, so only
+// the name, method and data limit are taken from an exported key.
+type KeySpec struct {
+	Name      string         `json:"name"`
+	Method    string         `json:"method"`
+	DataLimit *api.DataLimit `json:"dataLimit,omitempty"`
+}
+
+// ImportKeyOptions controls how exported keys are recreated
+type ImportKeyOptions struct {
+	// IfNotExists skips keys whose name is already used on the server or earlier in the file
+	IfNotExists bool
+	// Concurrency bounds how many keys are created in parallel
+	Concurrency int
+	// StripLimit creates the keys without their exported data limit, so the server's default
+	// limit, if any, applies to them
+	StripLimit bool
+}
+
+// KeyImportResult is the outcome of a key import: the created and failed keys, and the names
+// skipped because they already existed
+type KeyImportResult struct {
+	CreateResult
+	Skipped []string
+}
+
+// ImportAccessKeys recreates the keys listed in a file, as written by `keys list --output json`
+// or `--output csv`, on a server. The result is also returned when some keys failed.
+func (cm *ConfigManager) ImportAccessKeys(serverName, path string, opts ImportKeyOptions) (*KeyImportResult, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	specs, err := readKeySpecs(path)
+	if err != nil {
+		slog.Error("failed to read keys", "path", path, "error", err)
+		return nil, err
+	}
+	if !opts.StripLimit {
+		for _, spec := range specs {
+			if spec.DataLimit == nil {
+				continue
+			}
+			if err := cm.checkMaxDataLimit(spec.DataLimit.Bytes); err != nil {
+				slog.Error("data limit above the maximum", "key", spec.Name, "error", err)
+				return nil, fmt.Errorf("no keys imported: key '%s': %w", spec.Name, err)
+			}
+		}
+	}
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	result := &KeyImportResult{}
+	if opts.IfNotExists {
+		existingKeys, err := apiClient.ListAccessKeys(server.URL)
+		if err != nil {
+			slog.Error("failed to list access keys", "error", err)
+			return nil, err
+		}
+		specs, result.Skipped = skipExistingKeys(specs, existingKeys)
+	}
+	if len(specs) == 0 {
+		return result, nil
+	}
+
+	reqs := make([]api.CreateAccessKeyRequest, len(specs))
+	for i, spec := range specs {
+		reqs[i] = api.CreateAccessKeyRequest{Name: spec.Name, Method: spec.Method, Limit: spec.DataLimit}
+		if opts.StripLimit {
+			reqs[i].Limit = nil
+		}
+	}
+	created, err := cm.createKeys(apiClient, server, reqs, CreateKeyOptions{Concurrency: opts.Concurrency})
+	if created != nil {
+		result.CreateResult = *created
+	}
+	return result, err
+}
+
+// skipExistingKeys drops the specs whose name is taken by an existing key or an earlier spec.
+// Unnamed keys cannot be matched and are always kept.
+func skipExistingKeys(specs []KeySpec, existing []api.AccessKey) ([]KeySpec, []string) {
+	taken := make(map[string]bool, len(existing))
+	for _, key := range existing {
+		taken[key.Name] = true
+	}
+
+	var kept []KeySpec
+	var skipped []string
+	for _, spec := range specs {
+		if spec.Name != "" && taken[spec.Name] {
+			skipped = append(skipped, spec.Name)
+			continue
+		}
+		taken[spec.Name] = true
+		kept = append(kept, spec)
+	}
+	return kept, skipped
+}
+
+// readKeySpecs reads a CSV file when the path ends in .csv, and a JSON list of keys otherwise
+func readKeySpecs(path string) ([]KeySpec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var specs []KeySpec
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		specs, err = parseKeySpecsCSV(file)
+	} else {
+		err = json.NewDecoder(file).Decode(&specs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", path, err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no keys found in '%s'", path)
+	}
+	return specs, nil
+}
+
+// parseKeySpecsCSV reads the name, method and data limit columns by their header; other columns
+// are ignored and only name is required. The exact dataLimitBytes column is preferred over
+// dataLimit, which `keys list --output csv` rounds for display.
+func parseKeySpecsCSV(in io.Reader) ([]KeySpec, error) {
+	reader := csv.NewReader(in)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.TrimSpace(column)] = i
+	}
+	nameColumn, ok := columns["name"]
+	if !ok {
+		return nil, fmt.Errorf("missing 'name' column")
+	}
+	field := func(record []string, column string) string {
+		if i, ok := columns[column]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var specs []KeySpec
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return specs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		spec := KeySpec{Name: strings.TrimSpace(record[nameColumn]), Method: field(record, "method")}
+		if _, ok := columns["dataLimitBytes"]; ok {
+			if dataLimit := field(record, "dataLimitBytes"); dataLimit != "" {
+				bytes, err := strconv.ParseInt(dataLimit, 10, 64)
+				if err != nil || bytes < 0 {
+					return nil, fmt.Errorf("line %d: invalid dataLimitBytes '%s'", line, dataLimit)
+				}
+				spec.DataLimit = &api.DataLimit{Bytes: bytes}
+			}
+		} else if dataLimit := field(record, "dataLimit"); dataLimit != "" {
+			bytes, err := ParseDataSize(dataLimit)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			spec.DataLimit = &api.DataLimit{Bytes: bytes}
+		}
+		specs = append(specs, spec)
+	}
+}
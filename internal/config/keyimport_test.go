@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestParseKeySpecsCSV(t *testing.T) {
+	// The layout written by `keys list --output csv`, whose dataLimit is rounded
+	input := "id,name,port,method,accessUrl,dataLimit,dataLimitBytes,usage\n" +
+		"1,alice,12345,chacha20-ietf-poly1305,ss://a,1.5 GB,1499999999,20 MB\n" +
+		"2,bob,12346,aes-192-gcm,ss://b,,,\n"
+
+	specs, err := parseKeySpecsCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseKeySpecsCSV() error = %v", err)
+	}
+	if len(specs) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(specs))
+	}
+	if specs[0].Name != "alice" || specs[0].Method != "chacha20-ietf-poly1305" || specs[0].DataLimit == nil || specs[0].DataLimit.Bytes != 1499999999 {
+		t.Errorf("specs[0] = %+v, want alice with the exact 1499999999-byte limit", specs[0])
+	}
+	if specs[1].Name != "bob" || specs[1].DataLimit != nil {
+		t.Errorf("specs[1] = %+v, want bob without a limit", specs[1])
+	}
+
+	if _, err := parseKeySpecsCSV(strings.NewReader("id,method\n1,aes-192-gcm\n")); err == nil {
+		t.Error("Expected an error for a CSV without a name column")
+	}
+	if _, err := parseKeySpecsCSV(strings.NewReader("name,dataLimit\nalice,lots\n")); err == nil {
+		t.Error("Expected an error for an invalid data limit")
+	}
+	if _, err := parseKeySpecsCSV(strings.NewReader("name,dataLimitBytes\nalice,1.5 GB\n")); err == nil {
+		t.Error("Expected an error for a dataLimitBytes that is not a number")
+	}
+
+	// Without dataLimitBytes, as in a hand-written CSV, the display size is read
+	specs, err = parseKeySpecsCSV(strings.NewReader("name,dataLimit\nalice,1.5 GB\n"))
+	if err != nil || specs[0].DataLimit == nil || specs[0].DataLimit.Bytes != 1500000000 {
+		t.Errorf("Expected a 1.5 GB limit from the dataLimit column, got %+v, %v", specs, err)
+	}
+}
+
+func TestSkipExistingKeys(t *testing.T) {
+	specs := []KeySpec{{Name: "alice"}, {Name: "bob"}, {Name: "bob"}, {}, {}}
+	existing := []api.AccessKey{{ID: "1", Name: "alice"}}
+
+	kept, skipped := skipExistingKeys(specs, existing)
+
+	if len(kept) != 3 || kept[0].Name != "bob" || kept[1].Name != "" || kept[2].Name != "" {
+		t.Errorf("kept = %+v, want bob and two unnamed keys", kept)
+	}
+	if strings.Join(skipped, ",") != "alice,bob" {
+		t.Errorf("skipped = %q, want alice and the second bob", skipped)
+	}
+}
+
+func TestImportAccessKeys(t *testing.T) {
+	var mu sync.Mutex
+	var created []api.CreateAccessKeyRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{{ID: "1", Name: "alice"}}})
+			return
+		}
+
+		var req api.CreateAccessKeyRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		created = append(created, req)
+		id := strconv.Itoa(len(created) + 1)
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(api.AccessKey{ID: id, Name: req.Name, Method: req.Method, DataLimit: req.Limit})
+	}))
+	defer server.Close()
+
+	// The layout written by `keys list --output json`
+	path := filepath.Join(t.TempDir(), "keys.json")
+	exported := `[
+  {"id": "1", "name": "alice", "port": 1, "method": "aes-192-gcm", "accessUrl": "ss://a"},
+  {"id": "2", "name": "bob", "port": 2, "method": "chacha20-ietf-poly1305", "accessUrl": "ss://b", "dataLimit": {"bytes": 1000}, "usedBytes": 10}
+]`
+	if err := os.WriteFile(path, []byte(exported), 0600); err != nil {
+		t.Fatalf("Failed to write keys file: %v", err)
+	}
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	result, err := cm.ImportAccessKeys("test", path, ImportKeyOptions{IfNotExists: true, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("ImportAccessKeys() error = %v", err)
+	}
+
+	if strings.Join(result.Skipped, ",") != "alice" {
+		t.Errorf("Skipped = %q, want alice", result.Skipped)
+	}
+	if len(result.Created) != 1 || len(created) != 1 {
+		t.Fatalf("Expected bob to be created, got %+v", created)
+	}
+	req := created[0]
+	if req.Name != "bob" || req.Method != "chacha20-ietf-poly1305" || req.Limit == nil || req.Limit.Bytes != 1000 || req.Port != 0 {
+		t.Errorf("create request = %+v, want bob's name, method and limit only", req)
+	}
+}
+
+func TestImportAccessKeysStripLimit(t *testing.T) {
+	var created []api.CreateAccessKeyRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.CreateAccessKeyRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		created = append(created, req)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(api.AccessKey{ID: "1", Name: req.Name, DataLimit: req.Limit})
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "bob", "dataLimit": {"bytes": 1000}}]`), 0600); err != nil {
+		t.Fatalf("Failed to write keys file: %v", err)
+	}
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	if _, err := cm.ImportAccessKeys("test", path, ImportKeyOptions{Concurrency: 1, StripLimit: true}); err != nil {
+		t.Fatalf("ImportAccessKeys() error = %v", err)
+	}
+	if len(created) != 1 || created[0].Name != "bob" || created[0].Limit != nil {
+		t.Errorf("create requests = %+v, want bob without a data limit", created)
+	}
+}
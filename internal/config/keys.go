@@ -0,0 +1,252 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// KeyListOptions controls which access keys are listed
+type KeyListOptions struct {
+	// Unused limits the list to keys whose transfer is zero or below UnusedThreshold
+	Unused          bool
+	UnusedThreshold int64
+	// CheckPorts warns about ports shared by several keys and ports outside the usual range
+	CheckPorts bool
+	// WithUsage fetches the usage of every key, not only of keys with a data limit
+	WithUsage bool
+	// WithURLsOnly leaves out keys without an access URL, which cannot be handed out
+	WithURLsOnly bool
+}
+
+// KeyEntry is an access key joined with its transfer metrics, when available
+type KeyEntry struct {
+	api.AccessKey `yaml:",inline"`
+	UsedBytes     *int64 `json:"usedBytes,omitempty"`
+	// RemainingBytes is the data limit minus usage, floored at zero; unset for keys without a limit
+	RemainingBytes *int64 `json:"remainingBytes,omitempty"`
+}
+
+// ListAccessKeys returns the access keys of a server, joined with their usage when it was needed
+func (cm *ConfigManager) ListAccessKeys(serverName string, opts KeyListOptions) ([]KeyEntry, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	// Get API client for this server
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "error", err)
+		return nil, err
+	}
+
+	if opts.CheckPorts {
+		for _, finding := range checkKeyPorts(accessKeys) {
+			slog.Warn(finding)
+		}
+	}
+
+	if opts.WithURLsOnly {
+		var excluded int
+		accessKeys, excluded = filterKeysWithURLs(accessKeys)
+		if excluded > 0 {
+			slog.Warn("left out keys without an access URL", "server", serverName, "count", excluded)
+		}
+	}
+
+	var usage map[string]int64
+	if opts.Unused {
+		metrics, err := apiClient.GetTransferMetrics(server.URL)
+		if err != nil {
+			slog.Error("failed to get metrics", "error", err)
+			return nil, err
+		}
+		usage = metrics.BytesTransferredByUserId
+		accessKeys = filterUnusedKeys(accessKeys, usage, opts.UnusedThreshold)
+	} else if hasDataLimit(accessKeys) || opts.WithUsage {
+		// Usage is only needed when asked for and for the remaining allowance of limited keys
+		metrics, err := apiClient.GetTransferMetrics(server.URL)
+		if err != nil {
+			slog.Debug("metrics unavailable, not computing remaining allowance", "error", err)
+		} else {
+			usage = metrics.BytesTransferredByUserId
+		}
+	}
+
+	return newKeyEntries(accessKeys, usage), nil
+}
+
+// newKeyEntries joins access keys with their usage; a nil usage map leaves usage fields unset
+func newKeyEntries(keys []api.AccessKey, usage map[string]int64) []KeyEntry {
+	entries := make([]KeyEntry, 0, len(keys))
+	for _, key := range keys {
+		entry := KeyEntry{AccessKey: key}
+		if usage != nil {
+			used := usage[key.ID]
+			entry.UsedBytes = &used
+			if key.DataLimit != nil {
+				remaining := max(key.DataLimit.Bytes-used, 0)
+				entry.RemainingBytes = &remaining
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func hasDataLimit(keys []api.AccessKey) bool {
+	for _, key := range keys {
+		if key.DataLimit != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// filterUnusedKeys keeps the keys whose transferred bytes are zero or below the threshold
+func filterUnusedKeys(keys []api.AccessKey, usage map[string]int64, threshold int64) []api.AccessKey {
+	unused := make([]api.AccessKey, 0, len(keys))
+	for _, key := range keys {
+		used := usage[key.ID]
+		if used == 0 || used < threshold {
+			unused = append(unused, key)
+		}
+	}
+	return unused
+}
+
+// filterKeysWithURLs keeps the keys that have an access URL and counts the others
+func filterKeysWithURLs(keys []api.AccessKey) ([]api.AccessKey, int) {
+	withURLs := make([]api.AccessKey, 0, len(keys))
+	for _, key := range keys {
+		if strings.TrimSpace(key.AccessURL) != "" {
+			withURLs = append(withURLs, key)
+		}
+	}
+	return withURLs, len(keys) - len(withURLs)
+}
+
+// minTypicalPort is where Outline's randomly chosen ports start; lower ports are the well-known range
+const minTypicalPort = 1024
+
+// checkKeyPorts describes every port used by more than one key and every key on a port below
+// minTypicalPort, in key order. Keys without a known port are ignored.
+func checkKeyPorts(keys []api.AccessKey) []string {
+	byPort := make(map[int][]string)
+	var ports []int
+	for _, key := range keys {
+		if key.Port == 0 {
+			continue
+		}
+		if _, seen := byPort[key.Port]; !seen {
+			ports = append(ports, key.Port)
+		}
+		byPort[key.Port] = append(byPort[key.Port], key.ID)
+	}
+
+	var findings []string
+	for _, port := range ports {
+		ids := byPort[port]
+		users := "key " + ids[0]
+		if len(ids) > 1 {
+			users = "keys " + strings.Join(ids, ", ")
+			findings = append(findings, fmt.Sprintf("port %d is shared by %d keys: %s", port, len(ids), strings.Join(ids, ", ")))
+		}
+		if port < minTypicalPort {
+			findings = append(findings, fmt.Sprintf("port %d, used by %s, is in the well-known range below %d", port, users, minTypicalPort))
+		}
+	}
+	return findings
+}
+
+// GetAccessKey returns one access key, found by ID or by name
+func (cm *ConfigManager) GetAccessKey(serverName, keyID, keyName string) (*api.AccessKey, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "error", err)
+		return nil, err
+	}
+
+	accessKey, err := findAccessKey(accessKeys, keyID, keyName)
+	if err != nil {
+		slog.Error("failed to resolve access key", "server", serverName, "keyID", keyID, "keyName", keyName, "error", err)
+		return nil, fmt.Errorf("%w on server '%s'", err, serverName)
+	}
+	return accessKey, nil
+}
+
+// findAccessKey returns the key with the given ID, or with the given name when keyID is empty. A
+// name shared by several keys is an error rather than a guess, since the key is about to be acted on.
+func findAccessKey(keys []api.AccessKey, keyID, keyName string) (*api.AccessKey, error) {
+	if keyID != "" {
+		for i, key := range keys {
+			if key.ID == keyID {
+				return &keys[i], nil
+			}
+		}
+		return nil, fmt.Errorf("access key with ID '%s' not found", keyID)
+	}
+
+	var matches []int
+	for i, key := range keys {
+		if key.Name == keyName {
+			matches = append(matches, i)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("access key with name '%s' not found", keyName)
+	case 1:
+		return &keys[matches[0]], nil
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = keys[match].ID
+	}
+	return nil, fmt.Errorf("access key name '%s' is ambiguous, it is used by the keys with IDs %s; use --key-id instead", keyName, strings.Join(ids, ", "))
+}
+
+// percentOfDefaultLimit resolves a data limit given as a percentage of the server's default access key limit
+func (cm *ConfigManager) percentOfDefaultLimit(apiClient *api.APIClient, serverName string, percent float64) (int64, error) {
+	serverInfo, err := apiClient.GetServerInfo(cm.config.Servers[serverName].URL)
+	if err != nil {
+		slog.Error("failed to get server info", "error", err)
+		return 0, err
+	}
+
+	if serverInfo.AccessKeyDataLimit == nil {
+		return 0, fmt.Errorf("server '%s' has no default data limit, so the data limit cannot be a percentage", serverName)
+	}
+
+	limit := percentOf(serverInfo.AccessKeyDataLimit.Bytes, percent)
+	slog.Debug("resolved percentage data limit", "percent", percent, "default", serverInfo.AccessKeyDataLimit.Bytes, "bytes", limit)
+	return limit, nil
+}
+
+func percentOf(bytes int64, percent float64) int64 {
+	return int64(math.Round(float64(bytes) * percent / 100))
+}
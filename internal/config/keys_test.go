@@ -0,0 +1,243 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestCheckKeyPorts(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []api.AccessKey
+		expected []string
+	}{
+		{
+			name:     "separate ports",
+			keys:     []api.AccessKey{{ID: "1", Port: 12345}, {ID: "2", Port: 23456}},
+			expected: nil,
+		},
+		{
+			name: "shared port",
+			keys: []api.AccessKey{{ID: "1", Port: 12345}, {ID: "2", Port: 23456}, {ID: "3", Port: 12345}},
+			expected: []string{
+				"port 12345 is shared by 2 keys: 1, 3",
+			},
+		},
+		{
+			name: "low ports",
+			keys: []api.AccessKey{{ID: "1", Port: 443}, {ID: "2", Port: 80}, {ID: "3", Port: 80}},
+			expected: []string{
+				"port 443, used by key 1, is in the well-known range below 1024",
+				"port 80 is shared by 2 keys: 2, 3",
+				"port 80, used by keys 2, 3, is in the well-known range below 1024",
+			},
+		},
+		{
+			name:     "unknown ports ignored",
+			keys:     []api.AccessKey{{ID: "1"}, {ID: "2"}},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := checkKeyPorts(tt.keys)
+			if len(findings) != len(tt.expected) {
+				t.Fatalf("checkKeyPorts() = %q, want %q", findings, tt.expected)
+			}
+			for i := range tt.expected {
+				if findings[i] != tt.expected[i] {
+					t.Errorf("checkKeyPorts()[%d] = %q, want %q", i, findings[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFilterUnusedKeys(t *testing.T) {
+	keys := []api.AccessKey{{ID: "1"}, {ID: "2"}, {ID: "3"}, {ID: "4"}}
+	usage := map[string]int64{
+		"1": 0,
+		"2": 500,
+		"3": 5000,
+	}
+
+	tests := []struct {
+		name      string
+		threshold int64
+		expected  []string
+	}{
+		{"zero transfer only", 0, []string{"1", "4"}},
+		{"below threshold", 1000, []string{"1", "2", "4"}},
+		{"threshold equal to usage", 500, []string{"1", "4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := filterUnusedKeys(keys, usage, tt.threshold)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("filterUnusedKeys() returned %d keys, want %d", len(result), len(tt.expected))
+			}
+			for i, id := range tt.expected {
+				if result[i].ID != id {
+					t.Errorf("filterUnusedKeys()[%d] = %s, want %s", i, result[i].ID, id)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterKeysWithURLs(t *testing.T) {
+	keys := []api.AccessKey{
+		{ID: "1", AccessURL: "ss://a@example.com:1"},
+		{ID: "2"},
+		{ID: "3", AccessURL: " "},
+		{ID: "4", AccessURL: "ss://b@example.com:2"},
+	}
+
+	result, excluded := filterKeysWithURLs(keys)
+	if len(result) != 2 || result[0].ID != "1" || result[1].ID != "4" {
+		t.Errorf("filterKeysWithURLs() kept %+v, want keys 1 and 4", result)
+	}
+	if excluded != 2 {
+		t.Errorf("filterKeysWithURLs() excluded %d keys, want 2", excluded)
+	}
+}
+
+func TestNewKeyEntries(t *testing.T) {
+	keys := []api.AccessKey{
+		{ID: "1", DataLimit: &api.DataLimit{Bytes: 1000}},
+		{ID: "2", DataLimit: &api.DataLimit{Bytes: 1000}},
+		{ID: "3"},
+	}
+	usage := map[string]int64{"1": 400, "2": 1500, "3": 10}
+
+	entries := newKeyEntries(keys, usage)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	if entries[0].RemainingBytes == nil || *entries[0].RemainingBytes != 600 {
+		t.Errorf("Expected 600 bytes remaining, got %v", entries[0].RemainingBytes)
+	}
+	if entries[1].RemainingBytes == nil || *entries[1].RemainingBytes != 0 {
+		t.Errorf("Expected remaining to be floored at 0, got %v", entries[1].RemainingBytes)
+	}
+	if entries[2].RemainingBytes != nil {
+		t.Errorf("Expected no remaining for unlimited key, got %d", *entries[2].RemainingBytes)
+	}
+	if entries[2].UsedBytes == nil || *entries[2].UsedBytes != 10 {
+		t.Errorf("Expected 10 bytes used, got %v", entries[2].UsedBytes)
+	}
+
+	withoutMetrics := newKeyEntries(keys, nil)
+	if withoutMetrics[0].UsedBytes != nil || withoutMetrics[0].RemainingBytes != nil {
+		t.Error("Expected no usage fields without metrics")
+	}
+}
+
+func TestFindAccessKey(t *testing.T) {
+	keys := []api.AccessKey{
+		{ID: "1", Name: "alice"},
+		{ID: "2", Name: "bob"},
+		{ID: "3", Name: "1"},
+		{ID: "4", Name: "shared"},
+		{ID: "5", Name: "shared"},
+	}
+
+	tests := []struct {
+		name     string
+		keyID    string
+		keyName  string
+		expected string
+		hasError bool
+	}{
+		{"by ID", "2", "", "2", false},
+		{"by name", "", "alice", "1", false},
+		{"name that looks like an ID", "", "1", "3", false},
+		{"unknown ID", "9", "", "", true},
+		{"unknown name", "", "carol", "", true},
+		{"ambiguous name", "", "shared", "", true},
+		{"ID of a key with a shared name", "5", "", "5", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := findAccessKey(keys, tt.keyID, tt.keyName)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("findAccessKey(%q, %q) expected error, got nil", tt.keyID, tt.keyName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findAccessKey(%q, %q) unexpected error: %v", tt.keyID, tt.keyName, err)
+			}
+			if key.ID != tt.expected {
+				t.Errorf("findAccessKey(%q, %q) = key %s, want %s", tt.keyID, tt.keyName, key.ID, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPercentOfDefaultLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    *api.DataLimit
+		percent  float64
+		expected int64
+		hasError bool
+	}{
+		{"half", &api.DataLimit{Bytes: 10_000_000_000}, 50, 5_000_000_000, false},
+		{"fraction rounds", &api.DataLimit{Bytes: 3}, 50, 2, false},
+		{"above default", &api.DataLimit{Bytes: 1000}, 150, 1500, false},
+		{"no default limit", nil, 50, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(api.OutlineServer{Name: "test", AccessKeyDataLimit: tt.limit})
+			}))
+			defer server.Close()
+
+			cm := &ConfigManager{
+				config: &Config{Servers: map[string]Server{
+					"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+				}},
+			}
+
+			limit, err := cm.percentOfDefaultLimit(api.NewAPIClient("dummy"), "test", tt.percent)
+			if (err != nil) != tt.hasError {
+				t.Fatalf("percentOfDefaultLimit() error = %v, hasError %v", err, tt.hasError)
+			}
+			if limit != tt.expected {
+				t.Errorf("percentOfDefaultLimit(%v) = %d, want %d", tt.percent, limit, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKeyEntryYAML(t *testing.T) {
+	used := int64(500)
+	entry := KeyEntry{AccessKey: api.AccessKey{ID: "1", Name: "alice"}, UsedBytes: &used}
+
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		t.Fatalf("yaml.Marshal() error = %v", err)
+	}
+
+	// The key's fields sit next to the usage fields, as in the JSON output
+	for _, line := range []string{"id: \"1\"", "name: alice", "usedBytes: 500"} {
+		if !strings.Contains(string(data), "\n"+line+"\n") && !strings.HasPrefix(string(data), line+"\n") {
+			t.Errorf("Expected top-level %q in:\n%s", line, data)
+		}
+	}
+}
@@ -18,6 +18,9 @@ func levelFromString(logLevel string) slog.Level {
 		return slog.LevelInfo
 	case "debug":
 		return slog.LevelDebug
+	case "off":
+		// Above every level that is logged
+		return slog.LevelError + 4
 	default:
 		panic(fmt.Sprintf("illegal log level (%s), you should not see this error", logLevel))
 	}
@@ -30,6 +33,28 @@ func InitLogger(logLevel string) {
 		Level: level,
 	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, opts))
+	// Logs go to stderr so stdout carries only command output and can be piped
+	logger := slog.New(slog.NewTextHandler(os.Stderr, opts))
 	slog.SetDefault(logger)
 }
+
+// serverLogger returns a logger that tags every record with the server, under the "server" key,
+// and the operation, under the "op" key, so the logs of one server or operation can be filtered
+func serverLogger(serverName, operation string) *slog.Logger {
+	return slog.With("server", serverName, "op", operation)
+}
+
+// selectionLogger is serverLogger for an operation on a selection of servers. Only a single server
+// is logged under "server"; an --all, group or pattern selection is logged under its own key, and
+// the records of each server come from a serverLogger inside the fan-out.
+func selectionLogger(sel ServerSelection, operation string) *slog.Logger {
+	switch {
+	case sel.All:
+		return slog.With("selection", "all", "op", operation)
+	case sel.Group != "":
+		return slog.With("group", sel.Group, "op", operation)
+	case IsServerPattern(sel.Name):
+		return slog.With("pattern", sel.Name, "op", operation)
+	}
+	return serverLogger(sel.Name, operation)
+}
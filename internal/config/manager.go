@@ -1,12 +1,20 @@
 package config
 
 import (
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	neturl "net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/dustin/go-humanize"
 	"github.com/goccy/go-yaml"
@@ -16,20 +24,62 @@ import (
 
 type Config struct {
 	Servers map[string]Server `yaml:"servers"`
+	// Groups name sets of servers that fan-out commands can run against
+	Groups map[string][]string `yaml:"groups,omitempty"`
+	// Extra holds the fields this version does not know, so that saving the config keeps them
+	Extra map[string]any `yaml:",inline"`
 }
 
 type Server struct {
 	Name       string `yaml:"name"`
 	URL        string `yaml:"url"`
 	CertSha256 string `yaml:"certSha256,omitempty"`
+	// EndpointPrefix is the path of the management API below URL, for servers behind a subpath router
+	EndpointPrefix string `yaml:"endpointPrefix,omitempty"`
+	// SNI is the TLS server name to send instead of the URL's host, e.g. when the URL uses an IP
+	SNI string `yaml:"sni,omitempty"`
+	// Extra holds the fields this version does not know, so that saving the config keeps them
+	Extra map[string]any `yaml:",inline"`
+
+	// urlTemplate and certTemplate hold URL and CertSha256 as written in the config file when
+	// they reference environment variables; see expandServerEnv
+	urlTemplate  string
+	certTemplate string
+}
+
+// Options holds settings that apply to every command
+type Options struct {
+	Client api.ClientOptions
+	// Strict turns warnings about questionable input into errors
+	Strict bool
+	// Profile selects the config file; empty means DefaultProfile
+	Profile string
+	// NoInput makes any operation that would prompt fail instead
+	NoInput bool
+	// AssumeYes answers yes to every confirmation prompt
+	AssumeYes bool
+	// BackupCount is how many backups of the config file to keep, taken before each change;
+	// zero disables backups
+	BackupCount int
+	// BackupDir holds the backups; empty means a backups directory next to the config file
+	BackupDir string
+	// Progress reports the progress of bulk key operations and commands run against several
+	// servers; nil disables it
+	Progress ProgressFunc
+	// MaxDataLimit rejects creating or editing keys with a data limit above it, in bytes; zero
+	// means no maximum
+	MaxDataLimit int64
+	// Units is the unit system of sizes in errors and prompts, UnitsSI or UnitsIEC; empty means UnitsSI
+	Units string
 }
 
 type ConfigManager struct {
 	configPath string
 	config     *Config
+	options    Options
 }
 
-func NewConfigManager() (*ConfigManager, error) {
+func NewConfigManager(opts Options) (*ConfigManager, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		slog.Error("failed to get home directory", "error", err)
@@ -37,9 +87,9 @@ func NewConfigManager() (*ConfigManager, error) {
 	}
 
 	configDir := filepath.Join(homeDir, ".config", "outline-cli")
-	configPath := filepath.Join(configDir, "config.yaml")
+	configPath := filepath.Join(configDir, profileFileName(opts.Profile))
 
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if err := os.MkdirAll(configDir, 0700); err != nil {
 		slog.Error("failed to create config directory", "error", err)
 		return nil, err
 	}
@@ -47,6 +97,7 @@ func NewConfigManager() (*ConfigManager, error) {
 	cm := &ConfigManager{
 		configPath: configPath,
 		config:     &Config{Servers: make(map[string]Server)},
+		options:    opts,
 	}
 
 	if err := cm.loadConfig(); err != nil {
@@ -58,10 +109,15 @@ func NewConfigManager() (*ConfigManager, error) {
 }
 
 func (cm *ConfigManager) loadConfig() error {
-	if _, err := os.Stat(cm.configPath); os.IsNotExist(err) {
-		slog.Warn("config file does not exist", "path", cm.configPath)
+	info, err := os.Stat(cm.configPath)
+	if os.IsNotExist(err) {
+		slog.Debug("config file does not exist yet", "path", cm.configPath)
 		return nil
 	}
+	if err == nil && hasLoosePermissions(info.Mode()) {
+		slog.Warn("config file is readable by other users and contains secret server URLs; run 'outline-cli config fix-perms'",
+			"path", cm.configPath, "mode", fmt.Sprintf("%04o", info.Mode().Perm()))
+	}
 
 	data, err := os.ReadFile(cm.configPath)
 	if err != nil {
@@ -69,27 +125,84 @@ func (cm *ConfigManager) loadConfig() error {
 		return err
 	}
 
-	if err := yaml.Unmarshal(data, cm.config); err != nil {
+	config, err := parseConfig(data)
+	if err != nil {
 		slog.Error("failed to parse config file", "error", err)
 		return err
 	}
+	if err := cm.expandServerEnv(config); err != nil {
+		slog.Error("failed to expand environment variables in config file", "error", err)
+		return err
+	}
 
-	if cm.config.Servers == nil {
+	cm.config = config
+	return nil
+}
+
+// parseConfig decodes a config file; an empty file is a config without servers
+func parseConfig(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+
+	if config.Servers == nil {
 		slog.Debug("config file is empty, creating default config")
-		cm.config.Servers = make(map[string]Server)
+		config.Servers = make(map[string]Server)
 	}
+	dropKnownFields(config)
+	return config, nil
+}
 
-	return nil
+// dropKnownFields removes the fields of the structs from Extra. The decoder puts every field into
+// an inline map, known or not, so this leaves Extra with only the fields this version does not know.
+func dropKnownFields(config *Config) {
+	for _, key := range yamlFields(reflect.TypeFor[Config]()) {
+		delete(config.Extra, key)
+	}
+	serverFields := yamlFields(reflect.TypeFor[Server]())
+	for _, server := range config.Servers {
+		for _, key := range serverFields {
+			delete(server.Extra, key)
+		}
+	}
+}
+
+// yamlFields returns the sorted YAML keys of the exported fields of a struct type, leaving out
+// inline fields
+func yamlFields(t reflect.Type) []string {
+	var fields []string
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if name, _, _ := strings.Cut(field.Tag.Get("yaml"), ","); name != "" {
+			fields = append(fields, name)
+		}
+	}
+	slices.Sort(fields)
+	return fields
 }
 
+// saveConfig writes the config to disk, backing up the previous version first. The YAML encoder
+// emits map keys in sorted order, so servers are always written alphabetically and saving the
+// same data is byte-identical.
 func (cm *ConfigManager) saveConfig() error {
-	data, err := yaml.Marshal(cm.config)
+	data, err := yaml.Marshal(withEnvReferences(cm.config))
 	if err != nil {
 		slog.Error("failed to marshal config", "error", err)
 		return err
 	}
 
-	if err := os.WriteFile(cm.configPath, data, 0644); err != nil {
+	if previous, err := os.ReadFile(cm.configPath); err == nil && !bytes.Equal(previous, data) {
+		if err := cm.backupConfig(previous); err != nil {
+			return fmt.Errorf("failed to back up the config before changing it (pass --backups 0 to skip backups): %w", err)
+		}
+	}
+
+	// Server URLs contain the secret API path, so the file is only readable by its owner
+	if err := os.WriteFile(cm.configPath, data, configFileMode); err != nil {
 		slog.Error("failed to write config file", "error", err)
 		return err
 	}
@@ -97,27 +210,78 @@ func (cm *ConfigManager) saveConfig() error {
 	return nil
 }
 
-func (cm *ConfigManager) ListServers() error {
-	if len(cm.config.Servers) == 0 {
-		slog.Debug("no servers configured")
-		return nil
+// ListServers returns every configured server; probe also queries each one for its status
+func (cm *ConfigManager) ListServers(probe bool) []ServerStatus {
+	return cm.serverStatuses(probe)
+}
+
+// serverNotFound is the error for a server name missing from the config. A blank name, e.g. from
+// an empty shell variable, gets a message of its own instead of a not found error naming nothing.
+func serverNotFound(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("server name cannot be empty")
 	}
+	return fmt.Errorf("server '%s' not found", name)
+}
 
-	fmt.Println("Configured servers:")
-	fmt.Println("===================")
-	for name, server := range cm.config.Servers {
-		fmt.Printf("Name: %s\n", name)
-		fmt.Printf("URL:  %s\n", server.URL)
-		fmt.Printf("Cert: %s\n", server.CertSha256)
-		fmt.Println("---")
+// ValidateServerName checks the name of a new server. Names are config keys and are typed as
+// command arguments, so they cannot be blank or contain whitespace, control characters or
+// path separators.
+func ValidateServerName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("server name cannot be empty")
 	}
+	if strings.IndexFunc(name, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("server name '%s' cannot contain whitespace or control characters", name)
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("server name '%s' cannot contain '/' or '\\'", name)
+	}
+	return nil
+}
+
+// ValidateServerURL checks that a management URL is absolute, as `servers add` requires
+func ValidateServerURL(rawURL string) error {
+	parsedURL, err := neturl.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL format: %v", err)
+	}
+	if parsedURL.Scheme == "" {
+		return fmt.Errorf("URL must include a scheme (e.g., https://)")
+	}
+	if parsedURL.Host == "" {
+		return fmt.Errorf("URL must include a host")
+	}
+	return nil
+}
 
+// ValidateCertSHA256 checks that a certificate fingerprint is hex, as `servers add` requires
+func ValidateCertSHA256(hash string) error {
+	if _, err := hex.DecodeString(hash); err != nil {
+		return fmt.Errorf("invalid SHA256 hash format: %v", err)
+	}
 	return nil
 }
 
+// Server returns the config entry of a server
+func (cm *ConfigManager) Server(name string) (Server, error) {
+	log := serverLogger(name, "Server")
+	server, exists := cm.config.Servers[name]
+	if !exists {
+		log.Error("server not found")
+		return Server{}, serverNotFound(name)
+	}
+	return server, nil
+}
+
 func (cm *ConfigManager) AddServer(name, url, certSha256 string) error {
+	log := serverLogger(name, "AddServer")
+	if err := ValidateServerName(name); err != nil {
+		log.Error("invalid server name", "error", err)
+		return err
+	}
 	if _, exists := cm.config.Servers[name]; exists {
-		slog.Error("server already exists", "name", name)
+		log.Error("server already exists")
 		return fmt.Errorf("server '%s' already exists", name)
 	}
 
@@ -125,6 +289,10 @@ func (cm *ConfigManager) AddServer(name, url, certSha256 string) error {
 		return fmt.Errorf("certificate SHA256 is required")
 	}
 
+	if err := cm.checkDuplicateURL(log, name, url); err != nil {
+		return err
+	}
+
 	cm.config.Servers[name] = Server{
 		Name:       name,
 		URL:        url,
@@ -132,22 +300,98 @@ func (cm *ConfigManager) AddServer(name, url, certSha256 string) error {
 	}
 
 	if err := cm.saveConfig(); err != nil {
-		slog.Error("failed to save config", "error", err)
+		log.Error("failed to save config", "error", err)
 		return err
 	}
 
-	slog.Info("server added successfully", "name", name)
+	log.Info("server added successfully")
 	return nil
 }
 
+// checkDuplicateURL warns, or fails in strict mode, when another server already uses the same URL
+func (cm *ConfigManager) checkDuplicateURL(log *slog.Logger, name, rawURL string) error {
+	normalized := normalizeURL(rawURL)
+	for _, existingName := range cm.sortedServerNames() {
+		if existingName == name {
+			continue
+		}
+		if normalizeURL(cm.config.Servers[existingName].URL) != normalized {
+			continue
+		}
+
+		if cm.options.Strict {
+			log.Error("server URL already configured", "existing", existingName)
+			return fmt.Errorf("server '%s' already uses this URL", existingName)
+		}
+		log.Warn("server URL already configured, metrics will be counted twice", "existing", existingName)
+		return nil
+	}
+	return nil
+}
+
+// normalizeURL makes URLs comparable by lowercasing scheme and host and dropping trailing slashes
+func normalizeURL(rawURL string) string {
+	parsedURL, err := neturl.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return strings.TrimRight(strings.TrimSpace(rawURL), "/")
+	}
+
+	parsedURL.Scheme = strings.ToLower(parsedURL.Scheme)
+	parsedURL.Host = strings.ToLower(parsedURL.Host)
+	parsedURL.Path = strings.TrimRight(parsedURL.Path, "/")
+	parsedURL.RawPath = ""
+	return parsedURL.String()
+}
+
 // getAPIClientForServer returns an API client configured for the specified server
 func (cm *ConfigManager) getAPIClientForServer(serverName string) (*api.APIClient, error) {
 	server, exists := cm.config.Servers[serverName]
 	if !exists {
-		return nil, fmt.Errorf("server '%s' not found", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	if err := cm.checkPinnedCert(serverName, server); err != nil {
+		return nil, err
+	}
+	return api.NewAPIClientWithOptions(server.CertSha256, cm.clientOptions(server)), nil
+}
+
+// getOneShotAPIClient returns an API client for commands that send a single request per server,
+// so fan-out runs don't keep an idle connection open to every server in the fleet
+func (cm *ConfigManager) getOneShotAPIClient(serverName string) (*api.APIClient, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		return nil, serverNotFound(serverName)
+	}
+
+	if err := cm.checkPinnedCert(serverName, server); err != nil {
+		return nil, err
+	}
+	opts := cm.clientOptions(server)
+	opts.DisableKeepAlives = true
+	return api.NewAPIClientWithOptions(server.CertSha256, opts), nil
+}
+
+// checkPinnedCert rejects a server without a certSha256, as written by the old src/ binary. The
+// pin would be compared against an empty fingerprint and fail every TLS handshake with an error
+// that does not say why.
+func (cm *ConfigManager) checkPinnedCert(serverName string, server Server) error {
+	if server.CertSha256 != "" || cm.options.Client.SkipCertCheck {
+		return nil
 	}
+	slog.Error("server has no pinned certificate", "server", serverName)
+	return fmt.Errorf("server '%s' has no pinned certificate; run 'outline-cli servers test %s' to see the fingerprint it presents, "+
+		"verify it, then run 'outline-cli servers update %s --cert-sha256 <fingerprint>' or add it with 'outline-cli config edit'; "+
+		"'outline-cli servers fetch-certs' does this for every server without one",
+		serverName, serverName, serverName)
+}
 
-	return api.NewAPIClient(server.CertSha256), nil
+// clientOptions combines the global client options with the connection settings of a server
+func (cm *ConfigManager) clientOptions(server Server) api.ClientOptions {
+	opts := cm.options.Client
+	opts.EndpointPrefix = server.EndpointPrefix
+	opts.ServerName = server.SNI
+	return opts
 }
 
 // AddServerFromJSON adds a server from JSON input
@@ -172,371 +416,481 @@ func (cm *ConfigManager) AddServerFromJSON(serverName, jsonInput string) error {
 	return cm.AddServer(serverName, serverData.APIURL, serverData.CertSha256)
 }
 
-func (cm *ConfigManager) GetServer(name string) error {
+// ServerDetails is a configured server with what its management API reports about it
+type ServerDetails struct {
+	Server Server
+	// Info is nil when the server could not be queried
+	Info *api.OutlineServer
+	// KeyCount is only set when requested and the key list could be fetched
+	KeyCount *int
+	// Groups lists the groups the server belongs to
+	Groups []string
+	// Err is why the server could not be queried
+	Err error
+}
+
+func (cm *ConfigManager) GetServer(name string, withKeys bool) (*ServerDetails, error) {
+	log := serverLogger(name, "GetServer")
 	server, exists := cm.config.Servers[name]
 	if !exists {
-		slog.Error("server not found", "name", name)
-		return fmt.Errorf("server '%s' not found", name)
-	}
-
-	fmt.Printf("Server: %s\n", name)
-	fmt.Printf("URL:   %s\n", server.URL)
-	if server.CertSha256 != "" {
-		fmt.Printf("Cert:  %s\n", server.CertSha256)
+		log.Error("server not found")
+		return nil, serverNotFound(name)
 	}
+	details := &ServerDetails{Server: server, Groups: cm.serverGroups(name)}
 
 	// Get API client for this server
 	apiClient, err := cm.getAPIClientForServer(name)
 	if err != nil {
-		slog.Error("failed to get API client", "error", err)
-		return err
+		log.Error("failed to get API client", "error", err)
+		return nil, err
 	}
 
 	// Get server information from API
 	serverInfo, err := apiClient.GetServerInfo(server.URL)
 	if err != nil {
-		slog.Warn("failed to get server info from API", "error", err)
-		return nil
+		log.Warn("failed to get server info from API", "error", err)
+		details.Err = explainServerError(name, err)
+		return details, nil
 	}
+	details.Info = serverInfo
 
-	fmt.Printf("API Info:\n")
-	fmt.Printf("  Name:                    %s\n", serverInfo.Name)
-	fmt.Printf("  Server ID:               %s\n", serverInfo.ServerID)
-	fmt.Printf("  Version:                 %s\n", serverInfo.Version)
-	fmt.Printf("  Metrics Enabled:         %t\n", serverInfo.MetricsEnabled)
-	fmt.Printf("  Port for New Keys:       %d\n", serverInfo.PortForNewAccessKeys)
-	fmt.Printf("  Hostname for Keys:       %s\n", serverInfo.HostnameForAccessKeys)
-	if serverInfo.AccessKeyDataLimit != nil {
-		fmt.Printf("  Access Key Data Limit:   %d bytes\n", serverInfo.AccessKeyDataLimit.Bytes)
+	if withKeys {
+		accessKeys, err := apiClient.ListAccessKeys(server.URL)
+		if err != nil {
+			log.Warn("failed to get access key count from API", "error", err)
+			return details, nil
+		}
+		count := len(accessKeys)
+		details.KeyCount = &count
 	}
-	return nil
+	return details, nil
 }
 
-func (cm *ConfigManager) UpdateServer(name, url string) error {
+// GetServerRaw returns the unmodified response of a read-only management API endpoint
+func (cm *ConfigManager) GetServerRaw(name, endpoint string) ([]byte, error) {
+	log := serverLogger(name, "GetServerRaw")
 	server, exists := cm.config.Servers[name]
 	if !exists {
-		slog.Error("server not found", "name", name)
-		return fmt.Errorf("server '%s' not found", name)
+		log.Error("server not found")
+		return nil, serverNotFound(name)
 	}
 
-	if url != "" {
-		slog.Debug("updating server URL", "name", name, "url", url)
-		server.URL = url
-		cm.config.Servers[name] = server
+	apiClient, err := cm.getAPIClientForServer(name)
+	if err != nil {
+		log.Error("failed to get API client", "error", err)
+		return nil, err
 	}
 
-	if err := cm.saveConfig(); err != nil {
-		slog.Error("failed to save config", "error", err)
-		return err
+	body, err := apiClient.GetRaw(server.URL, endpoint)
+	if err != nil {
+		log.Error("failed to get raw endpoint", "error", err)
+		return nil, err
 	}
+	return body, nil
+}
 
-	slog.Debug("server updated successfully", "name", name)
-	return nil
+// ServerUpdate describes the changes to make to a configured server; empty fields are left unchanged
+type ServerUpdate struct {
+	URL        string
+	CertSha256 string
+	// NewName renames the server in the config and, unless LocalOnly is set, on the server itself
+	NewName   string
+	LocalOnly bool
+	// EndpointPrefix sets the path of the management API below the URL when not nil; an empty
+	// prefix removes it
+	EndpointPrefix *string
+	// SNI sets the TLS server name to send when not nil; an empty name sends the URL's host again
+	SNI *string
 }
 
-func (cm *ConfigManager) DeleteServer(name string) error {
-	if _, exists := cm.config.Servers[name]; !exists {
-		slog.Error("server not found", "name", name)
-		return fmt.Errorf("server '%s' not found", name)
+func (cm *ConfigManager) UpdateServer(name string, update ServerUpdate) error {
+	log := serverLogger(name, "UpdateServer")
+	server, exists := cm.config.Servers[name]
+	if !exists {
+		log.Error("server not found")
+		return serverNotFound(name)
 	}
+	original := server
 
-	delete(cm.config.Servers, name)
-
-	if err := cm.saveConfig(); err != nil {
-		slog.Error("failed to save config", "error", err)
-		return err
+	rename := update.NewName != "" && update.NewName != name
+	if rename {
+		if err := ValidateServerName(update.NewName); err != nil {
+			log.Error("invalid server name", "newName", update.NewName, "error", err)
+			return err
+		}
+		if _, taken := cm.config.Servers[update.NewName]; taken {
+			log.Error("server already exists", "newName", update.NewName)
+			return fmt.Errorf("server '%s' already exists", update.NewName)
+		}
 	}
 
-	slog.Debug("server deleted successfully", "name", name)
-	return nil
-}
+	if update.URL != "" {
+		if err := cm.checkDuplicateURL(log, name, update.URL); err != nil {
+			return err
+		}
 
-func (cm *ConfigManager) ListAccessKeys(serverName string) error {
-	server, exists := cm.config.Servers[serverName]
-	if !exists {
-		slog.Error("server not found", "name", serverName)
-		return fmt.Errorf("server '%s' not found", serverName)
+		log.Debug("updating server URL", "url", update.URL)
+		server.URL = update.URL
 	}
 
-	// Get API client for this server
-	apiClient, err := cm.getAPIClientForServer(serverName)
-	if err != nil {
-		slog.Error("failed to get API client", "error", err)
-		return err
+	if update.CertSha256 != "" {
+		log.Debug("updating server certificate", "certSha256", update.CertSha256)
+		server.CertSha256 = update.CertSha256
 	}
 
-	accessKeys, err := apiClient.ListAccessKeys(server.URL)
-	if err != nil {
-		slog.Error("failed to list access keys", "error", err)
-		return err
+	if update.EndpointPrefix != nil {
+		server.EndpointPrefix = strings.Trim(strings.TrimSpace(*update.EndpointPrefix), "/")
+		log.Debug("updating endpoint prefix", "endpointPrefix", server.EndpointPrefix)
 	}
 
-	if len(accessKeys) == 0 {
-		slog.Debug("no access keys found on server", "name", serverName)
-		return nil
+	if update.SNI != nil {
+		server.SNI = strings.TrimSpace(*update.SNI)
+		log.Debug("updating TLS server name", "sni", server.SNI)
 	}
 
-	fmt.Printf("Access keys for server '%s':\n", serverName)
-	fmt.Println("==================================")
-	for _, key := range accessKeys {
-		fmt.Printf("ID:       %s\n", key.ID)
-		fmt.Printf("Name:     %s\n", key.Name)
-		fmt.Printf("Port:     %d\n", key.Port)
-		fmt.Printf("Method:   %s\n", key.Method)
-		fmt.Printf("Access URL: %s\n", key.AccessURL)
-		if key.DataLimit != nil {
-			fmt.Printf("Data Limit: %s\n", humanize.Bytes(uint64(key.DataLimit.Bytes)))
-		}
-		fmt.Println("---")
+	newName := name
+	if rename {
+		log.Debug("renaming server", "newName", update.NewName)
+		newName = update.NewName
+		server.Name = newName
+		delete(cm.config.Servers, name)
+		cm.renameGroupMember(name, newName)
 	}
+	cm.config.Servers[newName] = server
 
-	return nil
-}
-
-// CreateAccessKey creates a new access key on a server
-func (cm *ConfigManager) CreateAccessKey(serverName, keyName, method string, port int, dataLimitStr string) error {
-	server, exists := cm.config.Servers[serverName]
-	if !exists {
-		slog.Error("server not found", "name", serverName)
-		return fmt.Errorf("server '%s' not found", serverName)
+	if err := cm.saveConfig(); err != nil {
+		log.Error("failed to save config", "error", err)
+		return err
 	}
 
-	// Parse data limit if provided
-	var dataLimit int64
-	if dataLimitStr != "" {
-		var err error
-		dataLimit, err = ParseDataSize(dataLimitStr)
+	if rename && !update.LocalOnly {
+		apiClient, err := cm.getAPIClientForServer(newName)
+		if err == nil {
+			err = apiClient.RenameServer(server.URL, newName)
+		}
 		if err != nil {
-			slog.Error("failed to parse data limit", "error", err)
+			log.Error("failed to rename server, restoring local config", "error", err)
+			delete(cm.config.Servers, newName)
+			cm.config.Servers[name] = original
+			cm.renameGroupMember(newName, name)
+			if saveErr := cm.saveConfig(); saveErr != nil {
+				log.Error("failed to restore config", "error", saveErr)
+				return fmt.Errorf("%w (config could not be restored: %v)", err, saveErr)
+			}
 			return err
 		}
 	}
 
-	req := api.CreateAccessKeyRequest{
-		Method: method,
-	}
-	if keyName != "" {
-		req.Name = keyName
-	}
-	if port > 0 {
-		req.Port = port
-	}
-	if dataLimit > 0 {
-		req.Limit = &api.DataLimit{Bytes: dataLimit}
-	}
+	log.Debug("server updated successfully")
+	return nil
+}
 
-	// Get API client for this server
-	apiClient, err := cm.getAPIClientForServer(serverName)
-	if err != nil {
-		slog.Error("failed to get API client", "error", err)
-		return err
+func (cm *ConfigManager) DeleteServer(name string) error {
+	log := serverLogger(name, "DeleteServer")
+	if _, exists := cm.config.Servers[name]; !exists {
+		log.Error("server not found")
+		return serverNotFound(name)
 	}
 
-	accessKey, err := apiClient.CreateAccessKey(server.URL, req)
-	if err != nil {
-		slog.Error("failed to create access key", "error", err)
-		return err
-	}
+	delete(cm.config.Servers, name)
 
-	fmt.Printf("Access key created successfully!\n")
-	fmt.Printf("ID:         %s\n", accessKey.ID)
-	fmt.Printf("Name:       %s\n", accessKey.Name)
-	fmt.Printf("Password:   %s\n", accessKey.Password)
-	fmt.Printf("Port:       %d\n", accessKey.Port)
-	fmt.Printf("Method:     %s\n", accessKey.Method)
-	fmt.Printf("Access URL: %s\n", accessKey.AccessURL)
-	if accessKey.DataLimit != nil {
-		fmt.Printf("Data Limit: %s\n", humanize.Bytes(uint64(accessKey.DataLimit.Bytes)))
+	if err := cm.saveConfig(); err != nil {
+		log.Error("failed to save config", "error", err)
+		return err
 	}
 
+	log.Debug("server deleted successfully")
 	return nil
 }
 
 func (cm *ConfigManager) DeleteAccessKey(serverName, keyID string) error {
+	log := serverLogger(serverName, "DeleteAccessKey")
 	server, exists := cm.config.Servers[serverName]
 	if !exists {
-		slog.Error("server not found", "serverName", serverName)
-		return fmt.Errorf("server '%s' not found", serverName)
+		log.Error("server not found")
+		return serverNotFound(serverName)
 	}
 
 	// Get API client for this server
 	apiClient, err := cm.getAPIClientForServer(serverName)
 	if err != nil {
-		slog.Error("failed to get API client", "error", err)
+		log.Error("failed to get API client", "error", err)
 		return err
 	}
 
 	err = apiClient.DeleteAccessKey(server.URL, keyID)
 	if err != nil {
-		slog.Error("failed to delete access key", "error", err)
+		log.Error("failed to delete access key", "error", err)
 		return err
 	}
 
-	slog.Debug("access key deleted successfully", "serverName", serverName, "keyID", keyID)
+	log.Debug("access key deleted successfully", "keyID", keyID)
 	return nil
 }
 
 // DeleteAccessKeyByName deletes an access key by name
 func (cm *ConfigManager) DeleteAccessKeyByName(serverName, keyName string) error {
+	log := serverLogger(serverName, "DeleteAccessKeyByName")
 	server, exists := cm.config.Servers[serverName]
 	if !exists {
-		slog.Error("server not found", "serverName", serverName)
-		return fmt.Errorf("server '%s' not found", serverName)
+		log.Error("server not found")
+		return serverNotFound(serverName)
 	}
 
 	// Get API client for this server
 	apiClient, err := cm.getAPIClientForServer(serverName)
 	if err != nil {
-		slog.Error("failed to get API client", "error", err)
+		log.Error("failed to get API client", "error", err)
 		return err
 	}
 
 	// First, get all access keys to find the one with the matching name
 	accessKeys, err := apiClient.ListAccessKeys(server.URL)
 	if err != nil {
-		slog.Error("failed to list access keys", "error", err)
+		log.Error("failed to list access keys", "error", err)
 		return err
 	}
 
-	var keyID string
-	for _, key := range accessKeys {
-		if key.Name == keyName {
-			keyID = key.ID
-			break
-		}
+	accessKey, err := findAccessKey(accessKeys, "", keyName)
+	if err != nil {
+		log.Error("failed to resolve access key", "keyName", keyName, "error", err)
+		return fmt.Errorf("%w on server '%s'", err, serverName)
 	}
 
-	if keyID == "" {
-		slog.Error("access key not found", "serverName", serverName, "keyName", keyName)
-		return fmt.Errorf("access key with name '%s' not found on server '%s'", keyName, serverName)
-	}
+	return cm.DeleteAccessKey(serverName, accessKey.ID)
+}
 
-	return cm.DeleteAccessKey(serverName, keyID)
+// ServerMetrics is the transfer of a server and, when known, when the server was created
+type ServerMetrics struct {
+	Transfer  *api.TransferMetrics
+	CreatedAt time.Time
 }
 
-func (cm *ConfigManager) GetMetrics(serverName string) error {
-	server, exists := cm.config.Servers[serverName]
-	if !exists {
-		slog.Error("server not found", "serverName", serverName)
-		return fmt.Errorf("server '%s' not found", serverName)
-	}
+// fetchMetrics fetches the transfer metrics of a single server
+func (cm *ConfigManager) fetchMetrics(serverName string) (*ServerMetrics, error) {
+	log := serverLogger(serverName, "fetchMetrics")
+	server := cm.config.Servers[serverName]
 
-	// Get API client for this server
+	// Both requests share a connection, which BenchmarkConnectionPooling shows is about twice as
+	// fast as one connection per request
 	apiClient, err := cm.getAPIClientForServer(serverName)
 	if err != nil {
-		slog.Error("failed to get API client", "error", err)
-		return err
+		log.Error("failed to get API client", "error", err)
+		return nil, err
 	}
 
 	metrics, err := apiClient.GetTransferMetrics(server.URL)
 	if err != nil {
-		slog.Error("failed to get metrics", "error", err)
-		return err
+		log.Error("failed to get metrics", "error", err)
+		return nil, err
 	}
 
-	fmt.Printf("Transfer metrics for server '%s':\n", serverName)
-	fmt.Println("==================================")
-	if len(metrics.BytesTransferredByUserId) == 0 {
-		slog.Debug("no transfer data available", "serverName", serverName)
-		return nil
+	result := &ServerMetrics{Transfer: metrics}
+	// The creation date only adds context to the totals, so the metrics are still shown without it
+	if serverInfo, err := apiClient.GetServerInfo(server.URL); err != nil {
+		log.Debug("server info unavailable, not showing server age", "error", err)
+	} else if serverInfo.CreatedTimestampMs > 0 {
+		result.CreatedAt = time.UnixMilli(serverInfo.CreatedTimestampMs)
 	}
 
-	for userID, bytes := range metrics.BytesTransferredByUserId {
-		fmt.Printf("User %s: %s\n", userID, humanize.Bytes(uint64(bytes)))
+	return result, nil
+}
+
+// GetMetrics fetches the transfer metrics of the selected servers
+func (cm *ConfigManager) GetMetrics(sel ServerSelection) (*FanOutResult[*ServerMetrics], error) {
+	log := selectionLogger(sel, "GetMetrics")
+	names, skipped, err := cm.selectFanOut(sel)
+	if err != nil {
+		log.Error("failed to select servers", "error", err)
+		return nil, err
 	}
 
-	return nil
+	progress := cm.startProgress("Fetching metrics", len(names))
+	defer progress.finish()
+	result := newFanOutResult(fanOut(names, sel.FailFast, withProgress(progress, cm.fetchMetrics)), len(names))
+	result.Skipped = skipped
+	return result, nil
+}
+
+// GetMetricsReports fetches the transfer metrics of the selected servers with key names resolved
+func (cm *ConfigManager) GetMetricsReports(sel ServerSelection) (*FanOutResult[*MetricsReport], error) {
+	log := selectionLogger(sel, "GetMetricsReports")
+	names, skipped, err := cm.selectFanOut(sel)
+	if err != nil {
+		log.Error("failed to select servers", "error", err)
+		return nil, err
+	}
+
+	progress := cm.startProgress("Fetching metrics", len(names))
+	defer progress.finish()
+	result := newFanOutResult(fanOut(names, sel.FailFast, withProgress(progress, cm.fetchMetricsReport)), len(names))
+	result.Skipped = skipped
+	return result, nil
+}
+
+// ConfigPath is the config file of the selected profile, whether or not it exists yet
+func (cm *ConfigManager) ConfigPath() string {
+	return cm.configPath
 }
 
-func (cm *ConfigManager) PrintConfig() error {
-	data, err := yaml.Marshal(cm.config)
+// MarshalConfig returns the config as it is written to the config file, with environment
+// variable references rather than their values
+func (cm *ConfigManager) MarshalConfig() ([]byte, error) {
+	data, err := yaml.Marshal(withEnvReferences(cm.config))
 	if err != nil {
 		slog.Error("failed to marshal config", "error", err)
-		return err
+		return nil, err
 	}
+	return data, nil
+}
 
-	fmt.Println(string(data))
-	return nil
+// EditKeyOptions describes the changes to make to an access key, found by KeyID or KeyName
+type EditKeyOptions struct {
+	KeyID   string
+	KeyName string
+	NewName string
+	// DataLimit in bytes; nil leaves the limit unchanged, while zero blocks the key
+	DataLimit *int64
+	// DataLimitPercent sets the limit relative to the server's default limit instead
+	DataLimitPercent float64
+	RemoveLimit      bool
+	// CheckUsage fetches the key's transfer and asks for confirmation before setting a limit at
+	// or below it, which blocks the key right away
+	CheckUsage bool
+}
+
+// EditKeyResult records the changes made by EditAccessKey
+type EditKeyResult struct {
+	KeyID string
+	// NewName is set if the key was renamed
+	NewName      string
+	LimitRemoved bool
+	// DataLimit is the new limit in bytes, or nil if the limit was not set
+	DataLimit *int64
 }
 
-// EditAccessKey edits an existing access key
-func (cm *ConfigManager) EditAccessKey(serverName, keyID, keyName, newName, dataLimitStr string, removeLimit bool) error {
+// EditAccessKey edits an existing access key. When a later change fails, the result still records
+// the changes that were made before it.
+func (cm *ConfigManager) EditAccessKey(serverName string, opts EditKeyOptions) (*EditKeyResult, error) {
+	log := serverLogger(serverName, "EditAccessKey")
 	server, exists := cm.config.Servers[serverName]
 	if !exists {
-		slog.Error("server not found", "serverName", serverName)
-		return fmt.Errorf("server '%s' not found", serverName)
+		log.Error("server not found")
+		return nil, serverNotFound(serverName)
 	}
 
 	// Get API client for this server
 	apiClient, err := cm.getAPIClientForServer(serverName)
 	if err != nil {
-		slog.Error("failed to get API client", "error", err)
-		return err
+		log.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	dataLimit := opts.DataLimit
+	if opts.DataLimitPercent > 0 {
+		limit, err := cm.percentOfDefaultLimit(apiClient, serverName, opts.DataLimitPercent)
+		if err != nil {
+			return nil, err
+		}
+		dataLimit = &limit
+	}
+	if dataLimit != nil && !opts.RemoveLimit {
+		if err := cm.checkMaxDataLimit(*dataLimit); err != nil {
+			log.Error("data limit above the maximum", "error", err)
+			return nil, err
+		}
 	}
 
 	// Determine the actual key ID
-	actualKeyID := keyID
-	if keyName != "" {
+	actualKeyID := opts.KeyID
+	if opts.KeyName != "" {
 		// Find key by name
 		accessKeys, err := apiClient.ListAccessKeys(server.URL)
 		if err != nil {
-			slog.Error("failed to list access keys", "error", err)
-			return err
-		}
-
-		found := false
-		for _, key := range accessKeys {
-			if key.Name == keyName {
-				actualKeyID = key.ID
-				found = true
-				break
-			}
+			log.Error("failed to list access keys", "error", err)
+			return nil, err
 		}
 
-		if !found {
-			slog.Error("access key not found", "serverName", serverName, "keyName", keyName)
-			return fmt.Errorf("access key with name '%s' not found on server '%s'", keyName, serverName)
+		accessKey, err := findAccessKey(accessKeys, "", opts.KeyName)
+		if err != nil {
+			log.Error("failed to resolve access key", "keyName", opts.KeyName, "error", err)
+			return nil, fmt.Errorf("%w on server '%s'", err, serverName)
 		}
+		actualKeyID = accessKey.ID
 	}
 
 	if actualKeyID == "" {
-		return fmt.Errorf("either --key-id or --key-name must be specified")
+		return nil, fmt.Errorf("either --key-id or --key-name must be specified")
+	}
+	result := &EditKeyResult{KeyID: actualKeyID}
+
+	if opts.CheckUsage && dataLimit != nil && !opts.RemoveLimit {
+		if err := cm.confirmLimitAboveUsage(apiClient, server, actualKeyID, *dataLimit); err != nil {
+			return nil, err
+		}
 	}
 
 	// Update key name if provided
-	if newName != "" {
-		err := apiClient.RenameAccessKey(server.URL, actualKeyID, newName)
+	if opts.NewName != "" {
+		err := apiClient.RenameAccessKey(server.URL, actualKeyID, opts.NewName)
 		if err != nil {
-			slog.Error("failed to rename access key", "error", err)
-			return err
+			log.Error("failed to rename access key", "error", err)
+			return result, err
 		}
-		fmt.Printf("Access key renamed successfully to: %s\n", newName)
+		result.NewName = opts.NewName
 	}
 
 	// Handle data limit changes
-	if removeLimit {
+	if opts.RemoveLimit {
 		err := apiClient.RemoveAccessKeyDataLimit(server.URL, actualKeyID)
 		if err != nil {
-			slog.Error("failed to remove data limit", "error", err)
-			return err
+			log.Error("failed to remove data limit", "error", err)
+			return result, err
 		}
-		fmt.Printf("Data limit removed successfully\n")
-	} else if dataLimitStr != "" {
-		// Parse and set new data limit
-		dataLimit, err := ParseDataSize(dataLimitStr)
+		result.LimitRemoved = true
+	} else if dataLimit != nil {
+		err := apiClient.SetAccessKeyDataLimit(server.URL, actualKeyID, api.DataLimit{Bytes: *dataLimit})
 		if err != nil {
-			slog.Error("failed to parse data limit", "error", err)
-			return err
+			log.Error("failed to set data limit", "error", err)
+			return result, err
 		}
+		result.DataLimit = dataLimit
+	}
 
-		err = apiClient.SetAccessKeyDataLimit(server.URL, actualKeyID, api.DataLimit{Bytes: dataLimit})
-		if err != nil {
-			slog.Error("failed to set data limit", "error", err)
-			return err
-		}
-		fmt.Printf("Data limit updated successfully to: %s\n", humanize.Bytes(uint64(dataLimit)))
+	return result, nil
+}
+
+// checkMaxDataLimit rejects a data limit above Options.MaxDataLimit, which guards against typing
+// a unit too large
+func (cm *ConfigManager) checkMaxDataLimit(limit int64) error {
+	if cm.options.MaxDataLimit > 0 && limit > cm.options.MaxDataLimit {
+		return fmt.Errorf("data limit %s exceeds the maximum of %s set by --max-data-limit",
+			FormatBytes(limit, cm.options.Units), FormatBytes(cm.options.MaxDataLimit, cm.options.Units))
+	}
+	return nil
+}
+
+// confirmLimitAboveUsage asks before setting a data limit that the key has already reached
+func (cm *ConfigManager) confirmLimitAboveUsage(apiClient *api.APIClient, server Server, keyID string, dataLimit int64) error {
+	metrics, err := apiClient.GetTransferMetrics(server.URL)
+	if err != nil {
+		slog.Error("failed to get metrics", "error", err)
+		return fmt.Errorf("could not check the key's usage: %w", err)
 	}
 
+	used := metrics.BytesTransferredByUserId[keyID]
+	if used < dataLimit {
+		return nil
+	}
+
+	usage := fmt.Sprintf("key '%s' has already transferred %s, so a limit of %s blocks it right away", keyID, FormatBytes(used, cm.options.Units), FormatBytes(dataLimit, cm.options.Units))
+	confirmed, err := cm.confirm(strings.ToUpper(usage[:1]) + usage[1:] + ". Set it anyway?")
+	if err != nil {
+		return fmt.Errorf("%s: %w", usage, err)
+	}
+	if !confirmed {
+		return fmt.Errorf("data limit not changed")
+	}
 	return nil
 }
 
@@ -549,10 +903,14 @@ func ParseDataSize(sizeStr string) (int64, error) {
 	// Remove any whitespace
 	sizeStr = strings.TrimSpace(sizeStr)
 
-	bytes, err := humanize.ParseBytes(sizeStr)
+	bytes, err := humanize.ParseBigBytes(sizeStr)
 	if err != nil {
 		return 0, fmt.Errorf("invalid data size format. Expected format like '1GB', '500MB', '2TB'. Got: %s", sizeStr)
 	}
+	// Sizes that do not fit in an int64 would wrap around to a negative or tiny limit
+	if !bytes.IsInt64() {
+		return 0, fmt.Errorf("data size too large. The largest size is about 9.2EB. Got: %s", sizeStr)
+	}
 
-	return int64(bytes), nil
+	return bytes.Int64(), nil
 }
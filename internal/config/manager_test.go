@@ -1,7 +1,18 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/api"
 )
 
 func TestParseDataSize(t *testing.T) {
@@ -31,11 +42,14 @@ func TestParseDataSize(t *testing.T) {
 		{"number without unit", "1000", 1000, false},
 		{"with multiple spaces", "1  GB", 1000000000, false},
 		{"decimal without unit", "1.5", 1, false},
+		{"exabytes", "9EB", 9000000000000000000, false},
 
 		// Invalid inputs
 		{"invalid format", "invalid", 0, true},
 		{"unknown unit", "1ZB", 0, true},
 		{"negative number", "-1GB", 0, true},
+		{"above int64", "10EB", 0, true},
+		{"above uint64", "20EiB", 0, true},
 	}
 
 	for _, tt := range tests {
@@ -57,3 +71,583 @@ func TestParseDataSize(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDataSizeTooLarge(t *testing.T) {
+	for _, input := range []string{"10EB", "20EiB", "1000000000000000000000"} {
+		if _, err := ParseDataSize(input); err == nil || !strings.Contains(err.Error(), "too large") {
+			t.Errorf("ParseDataSize(%q) error = %v, want a size too large error", input, err)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		units    string
+		input    int64
+		expected string
+	}{
+		{"default is SI", "", 1000000000, "1.0 GB"},
+		{"SI gigabyte", UnitsSI, 1000000000, "1.0 GB"},
+		{"IEC gibibyte", UnitsIEC, 1073741824, "1.0 GiB"},
+		{"IEC small value", UnitsIEC, 512, "512 B"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatBytes(tt.input, tt.units); got != tt.expected {
+				t.Errorf("FormatBytes(%d, %q) = %q, want %q", tt.input, tt.units, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAmbiguousSizeWarning(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		warns bool
+	}{
+		{"unit given", "1.5GB", false},
+		{"whole bytes", "1000", false},
+		{"exact decimal", "0.5KB", false},
+		{"IEC", "1.5GiB", false},
+		{"decimal without unit", "1.5", true},
+		{"fraction of a byte", "1.5B", true},
+		{"truncated decimal", "1.0001KB", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bytes, err := ParseDataSize(tt.input)
+			if err != nil {
+				t.Fatalf("ParseDataSize(%q) unexpected error: %v", tt.input, err)
+			}
+			warning := AmbiguousSizeWarning(tt.input, bytes)
+			if (warning != "") != tt.warns {
+				t.Errorf("AmbiguousSizeWarning(%q) = %q, warns %v", tt.input, warning, tt.warns)
+			}
+		})
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"unchanged", "https://example.com:8443/Secret", "https://example.com:8443/Secret"},
+		{"trailing slash", "https://example.com:8443/Secret/", "https://example.com:8443/Secret"},
+		{"uppercase host", "https://EXAMPLE.com:8443/Secret", "https://example.com:8443/Secret"},
+		{"uppercase scheme", "HTTPS://example.com/Secret", "https://example.com/Secret"},
+		{"with spaces", " https://example.com/Secret ", "https://example.com/Secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeURL(tt.input); got != tt.expected {
+				t.Errorf("normalizeURL(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAddServerDuplicateURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		strict   bool
+		url      string
+		hasError bool
+	}{
+		{"different URL", false, "https://other.com/Secret", false},
+		{"duplicate URL warns", false, "https://EXAMPLE.com/Secret/", false},
+		{"duplicate URL in strict mode", true, "https://EXAMPLE.com/Secret/", true},
+		{"path case matters", true, "https://example.com/secret", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &ConfigManager{
+				configPath: filepath.Join(t.TempDir(), "config.yaml"),
+				config: &Config{Servers: map[string]Server{
+					"existing": {Name: "existing", URL: "https://example.com/Secret", CertSha256: "ABCD"},
+				}},
+				options: Options{Strict: tt.strict},
+			}
+
+			err := cm.AddServer("new", tt.url, "ABCD")
+			if (err != nil) != tt.hasError {
+				t.Errorf("AddServer(%q) error = %v, hasError %v", tt.url, err, tt.hasError)
+			}
+
+			if _, added := cm.config.Servers["new"]; added == tt.hasError {
+				t.Errorf("Expected server added = %v", !tt.hasError)
+			}
+		})
+	}
+}
+
+func TestValidateServerName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		hasError bool
+	}{
+		{"plain", "prod-1", false},
+		{"dots and underscores", "eu.west_2", false},
+		{"empty", "", true},
+		{"whitespace only", "   ", true},
+		{"inner space", "my server", true},
+		{"tab", "prod\t1", true},
+		{"slash", "prod/1", true},
+		{"backslash", `prod\1`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateServerName(tt.input)
+			if (err != nil) != tt.hasError {
+				t.Errorf("ValidateServerName(%q) error = %v, hasError %v", tt.input, err, tt.hasError)
+			}
+		})
+	}
+}
+
+func TestEmptyServerName(t *testing.T) {
+	cm := &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: "https://example.com/Secret", CertSha256: "ABCD"},
+		}},
+	}
+
+	for _, name := range []string{"", "  "} {
+		if _, err := cm.Server(name); err == nil || err.Error() != "server name cannot be empty" {
+			t.Errorf("Server(%q) error = %v, want 'server name cannot be empty'", name, err)
+		}
+		if _, err := cm.ListAccessKeys(name, KeyListOptions{}); err == nil || err.Error() != "server name cannot be empty" {
+			t.Errorf("ListAccessKeys(%q) error = %v, want 'server name cannot be empty'", name, err)
+		}
+		if err := cm.AddServer(name, "https://other.com/Secret", "ABCD"); err == nil {
+			t.Errorf("AddServer(%q) expected an error", name)
+		}
+	}
+	if _, err := cm.Server("missing"); err == nil || err.Error() != "server 'missing' not found" {
+		t.Errorf("Server(missing) error = %v, want the not found error", err)
+	}
+}
+
+func TestSaveConfigIsStable(t *testing.T) {
+	dir := t.TempDir()
+	servers := map[string]Server{}
+	for _, name := range []string{"zulu", "alpha", "mike", "bravo", "yankee", "charlie"} {
+		servers[name] = Server{Name: name, URL: "https://" + name + ".example.com/Secret", CertSha256: "ABCD"}
+	}
+
+	var saved [][]byte
+	for i := 0; i < 5; i++ {
+		cm := &ConfigManager{
+			configPath: filepath.Join(dir, fmt.Sprintf("config-%d.yaml", i)),
+			config:     &Config{Servers: servers},
+		}
+		if err := cm.saveConfig(); err != nil {
+			t.Fatalf("saveConfig failed: %v", err)
+		}
+
+		data, err := os.ReadFile(cm.configPath)
+		if err != nil {
+			t.Fatalf("Failed to read saved config: %v", err)
+		}
+		saved = append(saved, data)
+	}
+
+	for i := 1; i < len(saved); i++ {
+		if !bytes.Equal(saved[0], saved[i]) {
+			t.Fatalf("Save %d differs from the first save:\n%s\nvs\n%s", i, saved[0], saved[i])
+		}
+	}
+
+	// Loading and saving again must not reorder anything either
+	cm := &ConfigManager{
+		configPath: filepath.Join(dir, "config-0.yaml"),
+		config:     &Config{Servers: make(map[string]Server)},
+	}
+	if err := cm.loadConfig(); err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if err := cm.saveConfig(); err != nil {
+		t.Fatalf("saveConfig failed: %v", err)
+	}
+	reloaded, err := os.ReadFile(cm.configPath)
+	if err != nil {
+		t.Fatalf("Failed to read saved config: %v", err)
+	}
+	if !bytes.Equal(saved[0], reloaded) {
+		t.Errorf("Round trip changed the config:\n%s\nvs\n%s", saved[0], reloaded)
+	}
+
+	previous := -1
+	for _, name := range []string{"alpha", "bravo", "charlie", "mike", "yankee", "zulu"} {
+		index := bytes.Index(saved[0], []byte("  "+name+":"))
+		if index < previous {
+			t.Errorf("Expected server %s to be written in alphabetical order", name)
+		}
+		previous = index
+	}
+}
+
+func TestSaveConfigKeepsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	original := `futureSetting:
+  enabled: true
+servers:
+  main:
+    name: main
+    url: https://example.com/Secret
+    certSha256: ABCD
+    endpointPrefix: outline/api
+    tags:
+    - eu
+`
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cm := &ConfigManager{configPath: path, config: &Config{Servers: make(map[string]Server)}}
+	if err := cm.loadConfig(); err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	empty := ""
+	if err := cm.UpdateServer("main", ServerUpdate{EndpointPrefix: &empty}); err != nil {
+		t.Fatalf("UpdateServer failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read saved config: %v", err)
+	}
+	saved := string(data)
+	for _, want := range []string{"futureSetting:\n  enabled: true", "tags:\n    - eu"} {
+		if !strings.Contains(saved, want) {
+			t.Errorf("Expected the saved config to keep %q, got:\n%s", want, saved)
+		}
+	}
+	// A cleared field must not come back from the unknown fields
+	if strings.Contains(saved, "endpointPrefix") {
+		t.Errorf("Expected the endpoint prefix to be removed, got:\n%s", saved)
+	}
+}
+
+func TestUpdateServerRename(t *testing.T) {
+	tests := []struct {
+		name        string
+		localOnly   bool
+		remoteFails bool
+		hasError    bool
+		wantName    string
+		wantRemote  bool
+	}{
+		{"renames locally and remotely", false, false, false, "new", true},
+		{"local only", true, false, false, "new", false},
+		{"remote failure rolls back", false, true, true, "old", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remoteCalled := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				remoteCalled = true
+				if tt.remoteFails {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			cm := &ConfigManager{
+				configPath: filepath.Join(t.TempDir(), "config.yaml"),
+				config: &Config{Servers: map[string]Server{
+					"old": {Name: "old", URL: server.URL, CertSha256: "dummy"},
+				}},
+			}
+
+			err := cm.UpdateServer("old", ServerUpdate{NewName: "new", LocalOnly: tt.localOnly})
+			if (err != nil) != tt.hasError {
+				t.Errorf("UpdateServer() error = %v, hasError %v", err, tt.hasError)
+			}
+			if remoteCalled != tt.wantRemote {
+				t.Errorf("Expected remote rename called = %v", tt.wantRemote)
+			}
+
+			if len(cm.config.Servers) != 1 {
+				t.Fatalf("Expected 1 server, got %d", len(cm.config.Servers))
+			}
+			got, exists := cm.config.Servers[tt.wantName]
+			if !exists || got.Name != tt.wantName {
+				t.Errorf("Expected server stored as %q, got %+v", tt.wantName, cm.config.Servers)
+			}
+		})
+	}
+}
+
+func TestUpdateServerRenameTaken(t *testing.T) {
+	cm := &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config: &Config{Servers: map[string]Server{
+			"old":   {Name: "old", URL: "https://old.example.com/Secret"},
+			"other": {Name: "other", URL: "https://other.example.com/Secret"},
+		}},
+	}
+
+	if err := cm.UpdateServer("old", ServerUpdate{NewName: "other", LocalOnly: true}); err == nil {
+		t.Error("Expected error renaming to an existing server name")
+	}
+}
+
+func TestUpdateServerCertSha256(t *testing.T) {
+	cm := &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config: &Config{Servers: map[string]Server{
+			"main": {Name: "main", URL: "https://main.example.com/Secret", CertSha256: "AA"},
+		}},
+	}
+
+	if err := cm.UpdateServer("main", ServerUpdate{CertSha256: "BB"}); err != nil {
+		t.Fatalf("UpdateServer() unexpected error: %v", err)
+	}
+	if got := cm.config.Servers["main"].CertSha256; got != "BB" {
+		t.Errorf("Expected certSha256 BB, got %q", got)
+	}
+}
+
+func TestAPIClientWithoutPinnedCert(t *testing.T) {
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"legacy": {Name: "legacy", URL: "https://legacy.example.com/Secret"},
+		}},
+	}
+
+	for name, getClient := range map[string]func(string) (*api.APIClient, error){
+		"getAPIClientForServer": cm.getAPIClientForServer,
+		"getOneShotAPIClient":   cm.getOneShotAPIClient,
+	} {
+		_, err := getClient("legacy")
+		if err == nil || !strings.Contains(err.Error(), "has no pinned certificate") || !strings.Contains(err.Error(), "servers update legacy --cert-sha256") {
+			t.Errorf("%s: expected an error explaining the missing certificate, got %v", name, err)
+		}
+	}
+
+	// --skip-cert-check does not need a pin
+	cm.options.Client.SkipCertCheck = true
+	if _, err := cm.getAPIClientForServer("legacy"); err != nil {
+		t.Errorf("Expected no error with SkipCertCheck, got %v", err)
+	}
+}
+
+func TestUpdateServerEndpointPrefix(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"name": "main", "version": "1.9.0"}`))
+	}))
+	defer server.Close()
+
+	cm := &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config: &Config{Servers: map[string]Server{
+			"main": {Name: "main", URL: server.URL + "/Secret/", CertSha256: "dummy"},
+		}},
+	}
+
+	prefix := "/outline/api/"
+	if err := cm.UpdateServer("main", ServerUpdate{EndpointPrefix: &prefix}); err != nil {
+		t.Fatalf("UpdateServer() unexpected error: %v", err)
+	}
+	if got := cm.config.Servers["main"].EndpointPrefix; got != "outline/api" {
+		t.Errorf("Expected endpoint prefix 'outline/api', got %q", got)
+	}
+
+	if _, err := cm.GetServer("main", false); err != nil {
+		t.Fatalf("GetServer() unexpected error: %v", err)
+	}
+	if gotPath != "/Secret/outline/api/server" {
+		t.Errorf("Expected request to /Secret/outline/api/server, got %s", gotPath)
+	}
+
+	empty := ""
+	if err := cm.UpdateServer("main", ServerUpdate{EndpointPrefix: &empty}); err != nil {
+		t.Fatalf("UpdateServer() unexpected error: %v", err)
+	}
+	if got := cm.config.Servers["main"].EndpointPrefix; got != "" {
+		t.Errorf("Expected the endpoint prefix to be removed, got %q", got)
+	}
+}
+
+func TestGetServerUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	cm := &ConfigManager{
+		config: &Config{
+			Servers: map[string]Server{"main": {Name: "main", URL: server.URL + "/Secret", CertSha256: "dummy"}},
+			Groups:  map[string][]string{"eu": {"main"}, "all": {"main"}, "other": {}},
+		},
+	}
+
+	details, err := cm.GetServer("main", true)
+	if err != nil {
+		t.Fatalf("Expected the local part for an unreachable server, got %v", err)
+	}
+	if details.Info != nil || details.KeyCount != nil || details.Err == nil {
+		t.Errorf("Expected no live data and the connection error, got %+v", details)
+	}
+	if len(details.Groups) != 2 || details.Groups[0] != "all" || details.Groups[1] != "eu" {
+		t.Errorf("Expected groups [all eu], got %v", details.Groups)
+	}
+}
+
+func TestUpdateServerSNI(t *testing.T) {
+	cm := &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config: &Config{Servers: map[string]Server{
+			"main": {Name: "main", URL: "https://203.0.113.10/Secret", CertSha256: "dummy"},
+		}},
+	}
+
+	sni := " outline.example.com "
+	if err := cm.UpdateServer("main", ServerUpdate{SNI: &sni}); err != nil {
+		t.Fatalf("UpdateServer() unexpected error: %v", err)
+	}
+	if got := cm.clientOptions(cm.config.Servers["main"]).ServerName; got != "outline.example.com" {
+		t.Errorf("Expected client server name 'outline.example.com', got %q", got)
+	}
+
+	empty := ""
+	if err := cm.UpdateServer("main", ServerUpdate{SNI: &empty}); err != nil {
+		t.Fatalf("UpdateServer() unexpected error: %v", err)
+	}
+	if got := cm.config.Servers["main"].SNI; got != "" {
+		t.Errorf("Expected the SNI override to be removed, got %q", got)
+	}
+}
+
+func TestEditAccessKeyCheckUsage(t *testing.T) {
+	tests := []struct {
+		name      string
+		dataLimit int64
+		assumeYes bool
+		wantErr   error
+		wantSet   bool
+	}{
+		{"limit above usage", 2000, false, nil, true},
+		{"limit below usage needs confirmation", 500, false, ErrInputRequired, false},
+		{"limit at usage needs confirmation", 1000, false, ErrInputRequired, false},
+		{"limit below usage with --yes", 500, true, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limitSet := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/metrics/transfer":
+					w.Write([]byte(`{"bytesTransferredByUserId": {"7": 1000}}`))
+				case r.Method == http.MethodPut && r.URL.Path == "/access-keys/7/data-limit":
+					limitSet = true
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			cm := &ConfigManager{
+				config: &Config{Servers: map[string]Server{
+					"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+				}},
+				options: Options{NoInput: true, AssumeYes: tt.assumeYes},
+			}
+
+			_, err := cm.EditAccessKey("test", EditKeyOptions{KeyID: "7", DataLimit: &tt.dataLimit, CheckUsage: true})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("EditAccessKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if limitSet != tt.wantSet {
+				t.Errorf("Expected limit set = %v", tt.wantSet)
+			}
+		})
+	}
+}
+
+func TestEditAccessKeyCheckUsageUnits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"bytesTransferredByUserId": {"7": 2048}}`))
+	}))
+	defer server.Close()
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+		options: Options{NoInput: true, Units: UnitsIEC},
+	}
+
+	limit := int64(1024)
+	_, err := cm.EditAccessKey("test", EditKeyOptions{KeyID: "7", DataLimit: &limit, CheckUsage: true})
+	if err == nil || !strings.Contains(err.Error(), "transferred 2.0 KiB, so a limit of 1.0 KiB") {
+		t.Errorf("Expected usage and limit in IEC units, got %v", err)
+	}
+}
+
+func TestEditAccessKeyDataLimit(t *testing.T) {
+	zero := int64(0)
+	gigabyte := int64(1000000000)
+
+	tests := []struct {
+		name        string
+		opts        EditKeyOptions
+		wantRequest string
+		wantBody    string
+	}{
+		{"zero limit is set", EditKeyOptions{KeyName: "alice", DataLimit: &zero}, "PUT /access-keys/7/data-limit", `{"limit":{"bytes":0}}`},
+		{"limit is set", EditKeyOptions{KeyID: "7", DataLimit: &gigabyte}, "PUT /access-keys/7/data-limit", `{"limit":{"bytes":1000000000}}`},
+		{"limit is removed", EditKeyOptions{KeyName: "alice", RemoveLimit: true}, "DELETE /access-keys/7/data-limit", ""},
+		{"nil limit leaves it alone", EditKeyOptions{KeyID: "7", NewName: "bob"}, "PUT /access-keys/7/name", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet && r.URL.Path == "/access-keys" {
+					w.Write([]byte(`{"accessKeys": [{"id": "7", "name": "alice"}]}`))
+					return
+				}
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				if r.URL.Path == "/access-keys/7/data-limit" && r.Method == http.MethodPut {
+					data, _ := io.ReadAll(r.Body)
+					body = strings.TrimSpace(string(data))
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			cm := &ConfigManager{config: &Config{Servers: map[string]Server{
+				"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+			}}}
+
+			if _, err := cm.EditAccessKey("test", tt.opts); err != nil {
+				t.Fatalf("EditAccessKey() unexpected error: %v", err)
+			}
+			if len(requests) != 1 || requests[0] != tt.wantRequest {
+				t.Errorf("Expected only %s, got %v", tt.wantRequest, requests)
+			}
+			if body != tt.wantBody {
+				t.Errorf("Expected body %s, got %s", tt.wantBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UserMetrics is the transfer of a single access key
+type UserMetrics struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+// MetricsReport is the machine-readable form of a server's transfer metrics
+type MetricsReport struct {
+	Server         string        `json:"server"`
+	MetricsEnabled bool          `json:"metricsEnabled"`
+	Users          []UserMetrics `json:"users"`
+	Total          int64         `json:"total"`
+	// ServerCreatedAt bounds how far back the cumulative totals can reach
+	ServerCreatedAt *time.Time `json:"serverCreatedAt,omitempty"`
+	// Others sums the users that Top left out of Users
+	Others *OtherUsers `json:"others,omitempty"`
+}
+
+// OtherUsers sums the transfer of the users below the top of a report
+type OtherUsers struct {
+	Count int   `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Top keeps the n users with the most transfer and sums the rest into Others. Total is unchanged.
+func (r *MetricsReport) Top(n int) {
+	if n <= 0 || len(r.Users) <= n {
+		return
+	}
+
+	others := &OtherUsers{Count: len(r.Users) - n}
+	for _, user := range r.Users[n:] {
+		others.Bytes += user.Bytes
+	}
+	r.Users = r.Users[:n]
+	r.Others = others
+}
+
+// FleetMetrics sums the transfer of several servers. Key IDs are only unique on their own server,
+// so users are never merged across servers; only the server totals are added up.
+type FleetMetrics struct {
+	Servers []ServerTotal `json:"servers"`
+	Total   int64         `json:"total"`
+}
+
+// ServerTotal is the transfer of a single server within FleetMetrics
+type ServerTotal struct {
+	Server         string `json:"server"`
+	MetricsEnabled bool   `json:"metricsEnabled"`
+	Users          int    `json:"users"`
+	Total          int64  `json:"total"`
+}
+
+// AggregateMetrics sums the totals of the reports, keeping their order
+func AggregateMetrics(reports []*MetricsReport) *FleetMetrics {
+	fleet := &FleetMetrics{Servers: make([]ServerTotal, 0, len(reports))}
+	for _, report := range reports {
+		users := len(report.Users)
+		if report.Others != nil {
+			users += report.Others.Count
+		}
+		fleet.Servers = append(fleet.Servers, ServerTotal{
+			Server:         report.Server,
+			MetricsEnabled: report.MetricsEnabled,
+			Users:          users,
+			Total:          report.Total,
+		})
+		fleet.Total += report.Total
+	}
+	return fleet
+}
+
+// fetchMetricsReport fetches transfer metrics for a server and resolves key names from its key list.
+// A server with metrics disabled yields an empty report instead of an error.
+func (cm *ConfigManager) fetchMetricsReport(serverName string) (*MetricsReport, error) {
+	server := cm.config.Servers[serverName]
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	report := &MetricsReport{Server: serverName, Users: []UserMetrics{}}
+
+	serverInfo, err := apiClient.GetServerInfo(server.URL)
+	if err != nil {
+		slog.Error("failed to get server info", "server", serverName, "error", err)
+		return nil, err
+	}
+
+	if serverInfo.CreatedTimestampMs > 0 {
+		createdAt := time.UnixMilli(serverInfo.CreatedTimestampMs).UTC()
+		report.ServerCreatedAt = &createdAt
+	}
+
+	if !serverInfo.MetricsEnabled {
+		slog.Debug("metrics are disabled", "server", serverName)
+		return report, nil
+	}
+	report.MetricsEnabled = true
+
+	metrics, err := apiClient.GetTransferMetrics(server.URL)
+	if err != nil {
+		slog.Error("failed to get metrics", "server", serverName, "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "server", serverName, "error", err)
+		return nil, err
+	}
+
+	names := make(map[string]string, len(accessKeys))
+	for _, key := range accessKeys {
+		names[key.ID] = key.Name
+	}
+
+	report.Users = RankUsers(metrics.BytesTransferredByUserId, names)
+	for _, user := range report.Users {
+		report.Total += user.Bytes
+	}
+
+	return report, nil
+}
+
+// RankUsers turns a transfer map into a list sorted by transfer, highest first, and then by key ID,
+// so the order never depends on map iteration. Names are looked up in names, which may be nil.
+func RankUsers(transfer map[string]int64, names map[string]string) []UserMetrics {
+	users := make([]UserMetrics, 0, len(transfer))
+	for userID, bytes := range transfer {
+		users = append(users, UserMetrics{ID: userID, Name: names[userID], Bytes: bytes})
+	}
+
+	slices.SortFunc(users, func(a, b UserMetrics) int {
+		if a.Bytes != b.Bytes {
+			return cmp.Compare(b.Bytes, a.Bytes)
+		}
+		return compareKeyIDs(a.ID, b.ID)
+	})
+	return users
+}
+
+// compareKeyIDs orders numeric key IDs by value, so key 10 comes after key 9, and numeric IDs
+// before any others, which are compared as strings
+func compareKeyIDs(a, b string) int {
+	numA, errA := strconv.ParseUint(a, 10, 64)
+	numB, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Compare(numA, numB)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// MetricsWindowNote explains that Outline's transfer totals are cumulative rather than a rolling window
+func MetricsWindowNote(createdAt, now time.Time) string {
+	note := "Totals are cumulative since the server's metrics were last reset, not a rolling window"
+	if createdAt.IsZero() {
+		return note + "."
+	}
+	days := int(now.Sub(createdAt).Hours() / 24)
+	return fmt.Sprintf("%s (server created %s, %d days ago).", note, createdAt.Format(time.DateOnly), days)
+}
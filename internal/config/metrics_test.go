@@ -0,0 +1,307 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func newMetricsServer(t *testing.T, metricsEnabled bool) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/server":
+			json.NewEncoder(w).Encode(api.OutlineServer{Name: "Test", MetricsEnabled: metricsEnabled, CreatedTimestampMs: 1704067200000})
+		case "/access-keys":
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{
+				{ID: "1", Name: "alice"},
+				{ID: "2", Name: "bob"},
+			}})
+		case "/metrics/transfer":
+			if !metricsEnabled {
+				t.Error("Metrics should not be fetched when disabled")
+			}
+			json.NewEncoder(w).Encode(api.TransferMetrics{BytesTransferredByUserId: map[string]int64{
+				"1": 100,
+				"2": 300,
+				"3": 50,
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestFetchMetricsReport(t *testing.T) {
+	server := newMetricsServer(t, true)
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	report, err := cm.fetchMetricsReport("test")
+	if err != nil {
+		t.Fatalf("fetchMetricsReport failed: %v", err)
+	}
+
+	if !report.MetricsEnabled {
+		t.Error("Expected metrics to be enabled")
+	}
+
+	if report.ServerCreatedAt == nil || report.ServerCreatedAt.Format(time.DateOnly) != "2024-01-01" {
+		t.Errorf("Expected server creation date 2024-01-01, got %v", report.ServerCreatedAt)
+	}
+
+	if report.Total != 450 {
+		t.Errorf("Expected total 450, got %d", report.Total)
+	}
+
+	expected := []UserMetrics{
+		{ID: "2", Name: "bob", Bytes: 300},
+		{ID: "1", Name: "alice", Bytes: 100},
+		{ID: "3", Name: "", Bytes: 50},
+	}
+	if len(report.Users) != len(expected) {
+		t.Fatalf("Expected %d users, got %d", len(expected), len(report.Users))
+	}
+	for i, user := range expected {
+		if report.Users[i] != user {
+			t.Errorf("users[%d] = %+v, want %+v", i, report.Users[i], user)
+		}
+	}
+}
+
+func TestFetchMetricsReportDisabled(t *testing.T) {
+	server := newMetricsServer(t, false)
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	report, err := cm.fetchMetricsReport("test")
+	if err != nil {
+		t.Fatalf("fetchMetricsReport failed: %v", err)
+	}
+
+	if report.MetricsEnabled {
+		t.Error("Expected metrics to be disabled")
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Failed to marshal report: %v", err)
+	}
+
+	if string(data) != `{"server":"test","metricsEnabled":false,"users":[],"total":0,"serverCreatedAt":"2024-01-01T00:00:00Z"}` {
+		t.Errorf("Unexpected JSON: %s", data)
+	}
+}
+
+func TestMetricsWindowNote(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	note := MetricsWindowNote(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), now)
+	if !strings.Contains(note, "cumulative") || !strings.Contains(note, "server created 2024-01-01, 60 days ago") {
+		t.Errorf("Unexpected note: %s", note)
+	}
+
+	if note := MetricsWindowNote(time.Time{}, now); strings.Contains(note, "created") {
+		t.Errorf("Expected no server age without a creation time, got: %s", note)
+	}
+}
+
+func TestRankUsers(t *testing.T) {
+	transfer := map[string]int64{"10": 50, "9": 50, "2": 50, "key-b": 50, "key-a": 50, "1": 300, "3": 0}
+	want := []string{"1", "2", "9", "10", "key-a", "key-b", "3"}
+
+	// Map iteration order changes between runs, so rank several times
+	for run := 0; run < 20; run++ {
+		users := RankUsers(transfer, map[string]string{"1": "alice"})
+		ids := make([]string, len(users))
+		for i, user := range users {
+			ids[i] = user.ID
+		}
+		if strings.Join(ids, ",") != strings.Join(want, ",") {
+			t.Fatalf("run %d: RankUsers order = %v, want %v", run, ids, want)
+		}
+		if users[0].Name != "alice" {
+			t.Errorf("Expected the name of key 1 to be resolved, got %q", users[0].Name)
+		}
+	}
+}
+
+func TestMetricsReportTop(t *testing.T) {
+	users := RankUsers(map[string]int64{"1": 500, "2": 300, "3": 20, "4": 10}, nil)
+
+	report := &MetricsReport{Users: users, Total: 830}
+	report.Top(2)
+	if len(report.Users) != 2 || report.Users[1].ID != "2" {
+		t.Errorf("Expected the top 2 users to be kept, got %+v", report.Users)
+	}
+	if report.Others == nil || report.Others.Count != 2 || report.Others.Bytes != 30 {
+		t.Errorf("Expected 2 others with 30 bytes, got %+v", report.Others)
+	}
+	if report.Total != 830 {
+		t.Errorf("Expected the total to be unchanged, got %d", report.Total)
+	}
+
+	report = &MetricsReport{Users: users}
+	report.Top(4)
+	if len(report.Users) != 4 || report.Others != nil {
+		t.Errorf("Expected no others when every user fits, got %+v", report)
+	}
+}
+
+func TestCompareKeyIDs(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"9", "10", -1},
+		{"10", "10", 0},
+		{"10", "abc", -1},
+		{"abc", "2", 1},
+		{"abc", "abd", -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareKeyIDs(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareKeyIDs(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAggregateMetrics(t *testing.T) {
+	reports := []*MetricsReport{
+		// Both servers have a key with ID 1, which are different users
+		{Server: "a", MetricsEnabled: true, Users: []UserMetrics{{ID: "1", Bytes: 300}, {ID: "2", Bytes: 200}}, Total: 500},
+		{Server: "b", MetricsEnabled: true, Users: []UserMetrics{{ID: "1", Bytes: 1000}}, Others: &OtherUsers{Count: 2, Bytes: 50}, Total: 1050},
+		{Server: "c", Users: []UserMetrics{}},
+	}
+
+	fleet := AggregateMetrics(reports)
+	if fleet.Total != 1550 {
+		t.Errorf("Total = %d, want 1550", fleet.Total)
+	}
+	want := []ServerTotal{
+		{Server: "a", MetricsEnabled: true, Users: 2, Total: 500},
+		{Server: "b", MetricsEnabled: true, Users: 3, Total: 1050},
+		{Server: "c", Users: 0, Total: 0},
+	}
+	if !slices.Equal(fleet.Servers, want) {
+		t.Errorf("Servers = %+v, want %+v", fleet.Servers, want)
+	}
+}
+
+// BenchmarkConnectionPooling runs the fan-out of `servers metrics --all` against several TLS
+// servers. GetMetrics reuses one connection for both requests to a server, which the one-shot
+// case compares with clients that reconnect for every request. The create cases sweep MaxConns
+// on a bulk `keys create` that sends concurrent requests to one server.
+func BenchmarkConnectionPooling(b *testing.B) {
+	const serverCount = 16
+
+	servers := make(map[string]Server, serverCount)
+	for i := range serverCount {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/server":
+				json.NewEncoder(w).Encode(api.OutlineServer{Name: "Test", CreatedTimestampMs: 1704067200000})
+			case "/metrics/transfer":
+				json.NewEncoder(w).Encode(api.TransferMetrics{BytesTransferredByUserId: map[string]int64{"1": 1024}})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		b.Cleanup(server.Close)
+
+		hash := sha256.Sum256(server.Certificate().Raw)
+		name := fmt.Sprintf("server-%02d", i)
+		servers[name] = Server{Name: name, URL: server.URL, CertSha256: hex.EncodeToString(hash[:])}
+	}
+	cm := &ConfigManager{config: &Config{Servers: servers}}
+
+	b.Run("keep-alive", func(b *testing.B) {
+		for b.Loop() {
+			result, err := cm.GetMetrics(ServerSelection{All: true})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if failed := result.Failed(); len(failed) > 0 {
+				b.Fatal(failed[0].Err)
+			}
+		}
+	})
+
+	// The same requests as fetchMetrics, with a client that closes its connection after each
+	fetchOneShot := func(name string) (*ServerMetrics, error) {
+		server := cm.config.Servers[name]
+		apiClient, err := cm.getOneShotAPIClient(name)
+		if err != nil {
+			return nil, err
+		}
+		metrics, err := apiClient.GetTransferMetrics(server.URL)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := apiClient.GetServerInfo(server.URL); err != nil {
+			return nil, err
+		}
+		return &ServerMetrics{Transfer: metrics}, nil
+	}
+	b.Run("one-shot", func(b *testing.B) {
+		for b.Loop() {
+			for _, result := range fanOut(cm.sortedServerNames(), false, fetchOneShot) {
+				if result.Err != nil {
+					b.Fatal(result.Err)
+				}
+			}
+		}
+	})
+
+	// Each create waits a little, like a server across a network, so that requests overlap
+	const keyCount = 32
+	var created atomic.Int64
+	createServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(api.AccessKey{ID: strconv.FormatInt(created.Add(1), 10)})
+	}))
+	b.Cleanup(createServer.Close)
+	hash := sha256.Sum256(createServer.Certificate().Raw)
+	createServers := map[string]Server{
+		"test": {Name: "test", URL: createServer.URL, CertSha256: hex.EncodeToString(hash[:])},
+	}
+
+	for _, concurrency := range []int{4, keyCount} {
+		for _, maxConns := range []int{1, 2, 8, 32} {
+			b.Run(fmt.Sprintf("create/concurrency=%d/max-conns=%d", concurrency, maxConns), func(b *testing.B) {
+				cm := &ConfigManager{
+					config:  &Config{Servers: createServers},
+					options: Options{Client: api.ClientOptions{MaxConns: maxConns}},
+				}
+				for b.Loop() {
+					if _, err := cm.CreateAccessKey("test", CreateKeyOptions{Count: keyCount, Concurrency: concurrency}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
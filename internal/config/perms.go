@@ -0,0 +1,66 @@
+package config
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"runtime"
+)
+
+// configFileMode is used for files holding server URLs; os.WriteFile only applies it to new files
+const configFileMode = 0600
+
+// hasLoosePermissions reports whether group or others can access the file. Windows permissions are
+// not expressed in the mode bits, so the check is skipped there.
+func hasLoosePermissions(mode fs.FileMode) bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	return mode.Perm()&0077 != 0
+}
+
+// PermissionsFix describes what FixConfigPermissions found and did
+type PermissionsFix struct {
+	Path string
+	// Unsupported is set on Windows, where the mode bits do not describe the permissions
+	Unsupported bool
+	// Missing is set when there is no config file yet
+	Missing bool
+	OldMode fs.FileMode
+	NewMode fs.FileMode
+	// Changed is set when the mode was loose and has been restricted
+	Changed bool
+}
+
+// FixConfigPermissions restricts the config file to its owner
+func (cm *ConfigManager) FixConfigPermissions() (*PermissionsFix, error) {
+	fix := &PermissionsFix{Path: cm.configPath, NewMode: configFileMode}
+	if runtime.GOOS == "windows" {
+		fix.Unsupported = true
+		return fix, nil
+	}
+
+	info, err := os.Stat(cm.configPath)
+	if os.IsNotExist(err) {
+		fix.Missing = true
+		return fix, nil
+	}
+	if err != nil {
+		slog.Error("failed to stat config file", "error", err)
+		return nil, err
+	}
+
+	fix.OldMode = info.Mode().Perm()
+	if !hasLoosePermissions(info.Mode()) {
+		fix.NewMode = fix.OldMode
+		return fix, nil
+	}
+
+	if err := os.Chmod(cm.configPath, configFileMode); err != nil {
+		slog.Error("failed to change config file permissions", "error", err)
+		return nil, err
+	}
+
+	fix.Changed = true
+	return fix, nil
+}
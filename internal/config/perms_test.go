@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFixConfigPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on Windows")
+	}
+
+	cm := &ConfigManager{configPath: filepath.Join(t.TempDir(), "config.yaml")}
+	if err := os.WriteFile(cm.configPath, []byte("servers: {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := os.Chmod(cm.configPath, 0644); err != nil {
+		t.Fatalf("Failed to chmod config: %v", err)
+	}
+
+	info, _ := os.Stat(cm.configPath)
+	if !hasLoosePermissions(info.Mode()) {
+		t.Fatalf("Expected mode %04o to be reported as too permissive", info.Mode().Perm())
+	}
+
+	fix, err := cm.FixConfigPermissions()
+	if err != nil {
+		t.Fatalf("FixConfigPermissions failed: %v", err)
+	}
+	if !fix.Changed || fix.OldMode != 0644 {
+		t.Errorf("Expected the change from 0644 to be reported, got %+v", fix)
+	}
+
+	info, _ = os.Stat(cm.configPath)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %04o", info.Mode().Perm())
+	}
+}
+
+func TestSaveConfigCreatesPrivateFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on Windows")
+	}
+
+	cm := &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config:     &Config{Servers: map[string]Server{}},
+	}
+	if err := cm.saveConfig(); err != nil {
+		t.Fatalf("saveConfig failed: %v", err)
+	}
+
+	info, _ := os.Stat(cm.configPath)
+	if hasLoosePermissions(info.Mode()) {
+		t.Errorf("Expected new config to be private, got %04o", info.Mode().Perm())
+	}
+}
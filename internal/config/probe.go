@@ -0,0 +1,99 @@
+package config
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// ServerStatus describes a configured server together with its live state
+type ServerStatus struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	CertSha256 string `json:"certSha256"`
+	Reachable  *bool  `json:"reachable,omitempty"`
+	Version    string `json:"version,omitempty"`
+	KeyCount   *int   `json:"keyCount,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// RedactURL hides the secret path of a management API URL, which grants full control of the server
+func RedactURL(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		return "<redacted>"
+	}
+
+	redacted := parsedURL.Scheme + "://" + parsedURL.Host
+	if parsedURL.Path != "" && parsedURL.Path != "/" {
+		redacted += "/<redacted>"
+	}
+	return redacted
+}
+
+// sortedServerNames returns the configured server names in alphabetical order
+func (cm *ConfigManager) sortedServerNames() []string {
+	names := make([]string, 0, len(cm.config.Servers))
+	for name := range cm.config.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serverStatuses builds the status of every configured server, optionally probing them concurrently
+func (cm *ConfigManager) serverStatuses(probe bool) []ServerStatus {
+	names := cm.sortedServerNames()
+	statuses := make([]ServerStatus, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		server := cm.config.Servers[name]
+		statuses[i] = ServerStatus{
+			Name:       name,
+			URL:        RedactURL(server.URL),
+			CertSha256: server.CertSha256,
+		}
+
+		if !probe {
+			continue
+		}
+
+		wg.Add(1)
+		go func(status *ServerStatus, server Server) {
+			defer wg.Done()
+			cm.probeServer(status, server)
+		}(&statuses[i], server)
+	}
+	wg.Wait()
+
+	return statuses
+}
+
+// probeServer fills the live fields of a server status from the management API
+func (cm *ConfigManager) probeServer(status *ServerStatus, server Server) {
+	reachable := false
+	status.Reachable = &reachable
+
+	apiClient, err := cm.getAPIClientForServer(status.Name)
+	if err != nil {
+		status.Error = err.Error()
+		return
+	}
+
+	serverInfo, err := apiClient.GetServerInfo(server.URL)
+	if err != nil {
+		status.Error = err.Error()
+		return
+	}
+	reachable = true
+	status.Version = serverInfo.Version
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		status.Error = err.Error()
+		return
+	}
+	keyCount := len(accessKeys)
+	status.KeyCount = &keyCount
+}
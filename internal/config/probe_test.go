@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"secret path", "https://example.com:8443/SecretPath", "https://example.com:8443/<redacted>"},
+		{"no path", "https://example.com", "https://example.com"},
+		{"root path", "https://example.com/", "https://example.com"},
+		{"invalid URL", "not a url", "<redacted>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactURL(tt.input); got != tt.expected {
+				t.Errorf("RedactURL(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestServerStatuses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/server":
+			json.NewEncoder(w).Encode(api.OutlineServer{Name: "Test Server", Version: "1.2.3"})
+		case "/access-keys":
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{{ID: "1"}, {ID: "2"}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"b-good": {Name: "b-good", URL: server.URL, CertSha256: "dummy"},
+			"a-bad":  {Name: "a-bad", URL: failing.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	offline := cm.serverStatuses(false)
+	if len(offline) != 2 {
+		t.Fatalf("Expected 2 statuses, got %d", len(offline))
+	}
+	if offline[0].Name != "a-bad" || offline[1].Name != "b-good" {
+		t.Errorf("Expected statuses sorted by name, got %s, %s", offline[0].Name, offline[1].Name)
+	}
+	if offline[0].Reachable != nil {
+		t.Error("Expected no reachability without probing")
+	}
+
+	probed := cm.serverStatuses(true)
+	if probed[0].Reachable == nil || *probed[0].Reachable {
+		t.Error("Expected a-bad to be unreachable")
+	}
+	if probed[1].Reachable == nil || !*probed[1].Reachable {
+		t.Fatalf("Expected b-good to be reachable, got error %q", probed[1].Error)
+	}
+	if probed[1].Version != "1.2.3" {
+		t.Errorf("Expected version 1.2.3, got %s", probed[1].Version)
+	}
+	if probed[1].KeyCount == nil || *probed[1].KeyCount != 2 {
+		t.Errorf("Expected 2 keys, got %v", probed[1].KeyCount)
+	}
+}
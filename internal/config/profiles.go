@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile is the profile stored in config.yaml
+const DefaultProfile = "default"
+
+// ProfileInfo describes a config profile found in the config directory
+type ProfileInfo struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Active bool   `json:"active"`
+}
+
+// ValidateProfileName checks a profile name given on the command line. Names become file names
+// in the config directory, so they cannot contain path separators or start with a dot, and
+// 'config' is reserved because config.yaml is the default profile's file.
+func ValidateProfileName(name string) error {
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid profile name '%s': must not contain path separators or start with a dot", name)
+	}
+	// Compared without case, since the config directory may be on a case-insensitive file system
+	if strings.EqualFold(name, "config") || (strings.EqualFold(name, DefaultProfile) && name != DefaultProfile) {
+		return fmt.Errorf("profile name '%s' is reserved, use '%s' for the default profile", name, DefaultProfile)
+	}
+	return nil
+}
+
+func profileFileName(profile string) string {
+	if profile == "" || profile == DefaultProfile {
+		return "config.yaml"
+	}
+	return profile + ".yaml"
+}
+
+func profileFromFileName(fileName string) string {
+	profile := strings.TrimSuffix(fileName, ".yaml")
+	if profile == "config" {
+		return DefaultProfile
+	}
+	return profile
+}
+
+// profiles returns every profile in the config directory, always including the default and active ones
+func (cm *ConfigManager) profiles() ([]ProfileInfo, error) {
+	configDir := filepath.Dir(cm.configPath)
+	active := profileFromFileName(filepath.Base(cm.configPath))
+
+	entries, err := os.ReadDir(configDir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{DefaultProfile: true, active: true}
+	for _, entry := range entries {
+		// default.yaml is never used: the default profile is stored in config.yaml
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" || entry.Name() == DefaultProfile+".yaml" {
+			continue
+		}
+		names[profileFromFileName(entry.Name())] = true
+	}
+
+	profiles := make([]ProfileInfo, 0, len(names))
+	for name := range names {
+		profiles = append(profiles, ProfileInfo{
+			Name:   name,
+			Path:   filepath.Join(configDir, profileFileName(name)),
+			Active: name == active,
+		})
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	return profiles, nil
+}
+
+// ListProfiles returns the available profiles, marking the one in use
+func (cm *ConfigManager) ListProfiles() ([]ProfileInfo, error) {
+	profiles, err := cm.profiles()
+	if err != nil {
+		slog.Error("failed to read config directory", "error", err)
+		return nil, err
+	}
+	return profiles, nil
+}
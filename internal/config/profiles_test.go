@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	// default.yaml is not the default profile's file and is left out
+	for _, name := range []string{"config.yaml", "default.yaml", "work.yaml", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "cache"), 0755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+
+	cm := &ConfigManager{configPath: filepath.Join(dir, profileFileName("personal"))}
+	profiles, err := cm.profiles()
+	if err != nil {
+		t.Fatalf("profiles failed: %v", err)
+	}
+
+	expected := []ProfileInfo{
+		{Name: "default", Path: filepath.Join(dir, "config.yaml")},
+		{Name: "personal", Path: filepath.Join(dir, "personal.yaml"), Active: true},
+		{Name: "work", Path: filepath.Join(dir, "work.yaml")},
+	}
+	if len(profiles) != len(expected) {
+		t.Fatalf("Expected %d profiles, got %+v", len(expected), profiles)
+	}
+	for i := range expected {
+		if profiles[i] != expected[i] {
+			t.Errorf("Profile %d: expected %+v, got %+v", i, expected[i], profiles[i])
+		}
+	}
+}
+
+func TestKeyCachePathPerProfile(t *testing.T) {
+	dir := t.TempDir()
+
+	defaultCM := &ConfigManager{configPath: filepath.Join(dir, profileFileName(DefaultProfile))}
+	workCM := &ConfigManager{configPath: filepath.Join(dir, profileFileName("work"))}
+
+	if got := defaultCM.keyCachePath("srv"); got != filepath.Join(dir, "cache", "srv.json") {
+		t.Errorf("Unexpected default profile cache path %s", got)
+	}
+	if got := workCM.keyCachePath("srv"); got != filepath.Join(dir, "cache", "work", "srv.json") {
+		t.Errorf("Unexpected work profile cache path %s", got)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import "sync"
+
+// ProgressFunc is told how many of the total items of a bulk operation are done. It is called
+// from the goroutines doing the work, but never concurrently. done equals total once the
+// operation is over, also when it stopped early.
+type ProgressFunc func(task string, done, total int)
+
+// bulkProgress counts the finished items of a bulk operation for the configured ProgressFunc
+type bulkProgress struct {
+	mu     sync.Mutex
+	report ProgressFunc
+	task   string
+	done   int
+	total  int
+}
+
+// startProgress starts reporting a bulk operation. Nothing is reported when no ProgressFunc is
+// set or there is only one item, since the result follows right away.
+func (cm *ConfigManager) startProgress(task string, total int) *bulkProgress {
+	p := &bulkProgress{task: task, total: total}
+	if total > 1 {
+		p.report = cm.options.Progress
+	}
+	if p.report != nil {
+		p.report(task, 0, total)
+	}
+	return p
+}
+
+// tick counts one finished item
+func (p *bulkProgress) tick() {
+	if p.report == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.report(p.task, p.done, p.total)
+}
+
+// finish ends the report of an operation that skipped some of its items
+func (p *bulkProgress) finish() {
+	if p.report == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done < p.total {
+		p.done = p.total
+		p.report(p.task, p.done, p.total)
+	}
+}
+
+// withProgress wraps a fan-out function so that every server it finishes is counted
+func withProgress[T any](p *bulkProgress, fn func(name string) (T, error)) func(name string) (T, error) {
+	return func(name string) (T, error) {
+		defer p.tick()
+		return fn(name)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestStartProgress(t *testing.T) {
+	var reports []int
+	cm := &ConfigManager{options: Options{Progress: func(task string, done, total int) {
+		if task != "Creating keys" || total != 5 {
+			t.Errorf("report(%q, %d, %d), want task 'Creating keys' and total 5", task, done, total)
+		}
+		reports = append(reports, done)
+	}}}
+
+	progress := cm.startProgress("Creating keys", 5)
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			progress.tick()
+		}()
+	}
+	wg.Wait()
+	progress.finish()
+
+	// Two items were skipped, so finish reports the operation as over
+	want := []int{0, 1, 2, 3, 5}
+	if len(reports) != len(want) {
+		t.Fatalf("reports = %v, want %v", reports, want)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("reports = %v, want %v", reports, want)
+			break
+		}
+	}
+}
+
+func TestStartProgressSingleItem(t *testing.T) {
+	cm := &ConfigManager{options: Options{Progress: func(task string, done, total int) {
+		t.Errorf("unexpected report(%q, %d, %d) for a single item", task, done, total)
+	}}}
+
+	progress := cm.startProgress("Checking servers", 1)
+	progress.tick()
+	progress.finish()
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInputRequired is returned instead of prompting when input is disabled or stdin is not a terminal
+var ErrInputRequired = errors.New("interactive input required; pass --yes or set the value explicitly")
+
+// confirm asks a yes/no question on stderr. Every prompt goes through here so that --yes and
+// --no-input are honoured everywhere.
+func (cm *ConfigManager) confirm(question string) (bool, error) {
+	if cm.options.AssumeYes {
+		return true, nil
+	}
+	if cm.options.NoInput || !stdinIsTerminal() {
+		return false, ErrInputRequired
+	}
+	return readConfirmation(os.Stdin, os.Stderr, question)
+}
+
+func readConfirmation(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	if errors.Is(err, io.EOF) && answer == "" {
+		// A closed stdin must not count as consent
+		return false, ErrInputRequired
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
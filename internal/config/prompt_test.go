@@ -0,0 +1,48 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadConfirmation(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+		err      error
+	}{
+		{"yes", "y\n", true, nil},
+		{"full word", "Yes\n", true, nil},
+		{"no", "n\n", false, nil},
+		{"empty answer defaults to no", "\n", false, nil},
+		{"answer without newline", "y", true, nil},
+		{"closed stdin", "", false, ErrInputRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			confirmed, err := readConfirmation(strings.NewReader(tt.input), io.Discard, "Delete?")
+			if !errors.Is(err, tt.err) {
+				t.Errorf("readConfirmation(%q) error = %v, want %v", tt.input, err, tt.err)
+			}
+			if confirmed != tt.expected {
+				t.Errorf("readConfirmation(%q) = %v, want %v", tt.input, confirmed, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfirmWithoutInput(t *testing.T) {
+	cm := &ConfigManager{options: Options{NoInput: true}}
+	if _, err := cm.confirm("Delete?"); !errors.Is(err, ErrInputRequired) {
+		t.Errorf("Expected ErrInputRequired with --no-input, got %v", err)
+	}
+
+	cm.options.AssumeYes = true
+	if confirmed, err := cm.confirm("Delete?"); !confirmed || err != nil {
+		t.Errorf("Expected --yes to confirm, got %v, %v", confirmed, err)
+	}
+}
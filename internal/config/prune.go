@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// PruneKeyOptions selects the keys to prune. Every criterion that is set must hold.
+type PruneKeyOptions struct {
+	// Unused selects keys with zero transfer according to the server metrics
+	Unused bool
+	// OlderThan selects keys first seen at least this long ago according to the key cache.
+	// Keys that are not cached are never selected, since their age is unknown.
+	OlderThan time.Duration
+}
+
+// PruneResult is a key selected by PruneAccessKeys and what happened to it
+type PruneResult struct {
+	Key       api.AccessKey `json:"key"`
+	UsedBytes *int64        `json:"usedBytes,omitempty"`
+	FirstSeen *time.Time    `json:"firstSeen,omitempty"`
+	Deleted   bool          `json:"deleted"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// PruneReport lists the keys PruneAccessKeys selected
+type PruneReport struct {
+	Server string `json:"server"`
+	// DryRun is set when the keys were only listed
+	DryRun bool          `json:"dryRun"`
+	Keys   []PruneResult `json:"keys"`
+	// Uncached counts the keys left out by OlderThan because the key cache does not know them
+	Uncached int `json:"uncached"`
+}
+
+// PruneAccessKeys selects the keys of a server that match every criterion of opts and deletes
+// them. Without Options.AssumeYes nothing is deleted and the report is a dry run. A report is
+// returned also when some deletions failed.
+func (cm *ConfigManager) PruneAccessKeys(serverName string, opts PruneKeyOptions) (*PruneReport, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "error", err)
+		return nil, err
+	}
+
+	var usage map[string]int64
+	if opts.Unused {
+		metrics, err := apiClient.GetTransferMetrics(server.URL)
+		if err != nil {
+			slog.Error("failed to get metrics", "error", err)
+			return nil, err
+		}
+		usage = metrics.BytesTransferredByUserId
+	}
+
+	var cache *KeyCache
+	if opts.OlderThan > 0 {
+		if cache, err = cm.loadKeyCache(serverName); err != nil {
+			slog.Error("failed to load key cache", "error", err)
+			return nil, err
+		}
+	}
+
+	report := &PruneReport{Server: serverName, DryRun: !cm.options.AssumeYes}
+	report.Keys, report.Uncached = selectKeysToPrune(accessKeys, usage, cache, opts, time.Now())
+	if report.DryRun {
+		return report, nil
+	}
+
+	failed := 0
+	progress := cm.startProgress("Deleting keys", len(report.Keys))
+	for i := range report.Keys {
+		result := &report.Keys[i]
+		if err := apiClient.DeleteAccessKey(server.URL, result.Key.ID); err != nil {
+			slog.Error("failed to delete access key", "keyID", result.Key.ID, "error", err)
+			result.Error = err.Error()
+			failed++
+		} else {
+			result.Deleted = true
+		}
+		progress.tick()
+	}
+
+	if failed > 0 {
+		return report, fmt.Errorf("failed to delete %d of %d keys", failed, len(report.Keys))
+	}
+	return report, nil
+}
+
+// selectKeysToPrune returns the keys that match every criterion of opts, and how many keys were
+// left out only because the cache does not know their age. usage and cache are only read for
+// the criteria that need them.
+func selectKeysToPrune(keys []api.AccessKey, usage map[string]int64, cache *KeyCache, opts PruneKeyOptions, now time.Time) ([]PruneResult, int) {
+	selected := []PruneResult{}
+	uncached := 0
+	for _, key := range keys {
+		result := PruneResult{Key: key}
+		if opts.Unused {
+			used := usage[key.ID]
+			if used > 0 {
+				continue
+			}
+			result.UsedBytes = &used
+		}
+		if opts.OlderThan > 0 {
+			cached, ok := cache.Keys[key.ID]
+			if !ok || cached.FirstSeen.IsZero() {
+				uncached++
+				continue
+			}
+			if now.Sub(cached.FirstSeen) < opts.OlderThan {
+				continue
+			}
+			result.FirstSeen = &cached.FirstSeen
+		}
+		selected = append(selected, result)
+	}
+	return selected, uncached
+}
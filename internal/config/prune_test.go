@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestSelectKeysToPrune(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	keys := []api.AccessKey{{ID: "1"}, {ID: "2"}, {ID: "3"}, {ID: "4"}}
+	usage := map[string]int64{"2": 100}
+	cache := &KeyCache{Keys: map[string]CachedKey{
+		"1": {FirstSeen: now.Add(-100 * 24 * time.Hour)},
+		"2": {FirstSeen: now.Add(-100 * 24 * time.Hour)},
+		"3": {FirstSeen: now.Add(-10 * 24 * time.Hour)},
+	}}
+
+	tests := []struct {
+		name         string
+		opts         PruneKeyOptions
+		wantIDs      []string
+		wantUncached int
+	}{
+		{"unused", PruneKeyOptions{Unused: true}, []string{"1", "3", "4"}, 0},
+		{"older than", PruneKeyOptions{OlderThan: 90 * 24 * time.Hour}, []string{"1", "2"}, 1},
+		{"unused and older than", PruneKeyOptions{Unused: true, OlderThan: 90 * 24 * time.Hour}, []string{"1"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, uncached := selectKeysToPrune(keys, usage, cache, tt.opts, now)
+			var ids []string
+			for _, result := range selected {
+				ids = append(ids, result.Key.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) || uncached != tt.wantUncached {
+				t.Errorf("selectKeysToPrune() = %v with %d uncached, want %v with %d", ids, uncached, tt.wantIDs, tt.wantUncached)
+			}
+		})
+	}
+}
+
+func TestPruneAccessKeys(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/access-keys":
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{{ID: "1"}, {ID: "2"}}})
+		case r.URL.Path == "/metrics/transfer":
+			json.NewEncoder(w).Encode(api.TransferMetrics{BytesTransferredByUserId: map[string]int64{"2": 10}})
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/access-keys/"):
+			mu.Lock()
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/access-keys/"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cm := &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	report, err := cm.PruneAccessKeys("test", PruneKeyOptions{Unused: true})
+	if err != nil {
+		t.Fatalf("PruneAccessKeys failed: %v", err)
+	}
+	if !report.DryRun || len(report.Keys) != 1 || report.Keys[0].Deleted || len(deleted) != 0 {
+		t.Errorf("Expected a dry run listing key 1 without deleting, got %+v and deleted %v", report, deleted)
+	}
+
+	cm.options.AssumeYes = true
+	report, err = cm.PruneAccessKeys("test", PruneKeyOptions{Unused: true})
+	if err != nil {
+		t.Fatalf("PruneAccessKeys failed: %v", err)
+	}
+	if report.DryRun || !report.Keys[0].Deleted || !slices.Equal(deleted, []string{"1"}) {
+		t.Errorf("Expected key 1 to be deleted with AssumeYes, got %+v and deleted %v", report, deleted)
+	}
+}
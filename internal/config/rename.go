@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+// BulkRenameOptions selects the keys to rename and how their new names are computed
+type BulkRenameOptions struct {
+	// NamePrefix selects the keys whose name starts with it
+	NamePrefix string
+	// NameTemplate computes the new name with the api.AccessKey as its data
+	NameTemplate *template.Template
+}
+
+// RenameResult is a key selected by BulkRenameAccessKeys and what happened to it
+type RenameResult struct {
+	Key     api.AccessKey `json:"key"`
+	NewName string        `json:"newName,omitempty"`
+	Renamed bool          `json:"renamed"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// RenameReport lists the keys BulkRenameAccessKeys selected
+type RenameReport struct {
+	Server string `json:"server"`
+	// DryRun is set when the new names were only computed
+	DryRun bool           `json:"dryRun"`
+	Keys   []RenameResult `json:"keys"`
+	// Unchanged counts the selected keys the template gave their current name, which are left alone
+	Unchanged int `json:"unchanged"`
+}
+
+// BulkRenameAccessKeys renames every key whose name starts with opts.NamePrefix to the name
+// opts.NameTemplate computes for it. Without Options.AssumeYes nothing is renamed and the report is
+// a dry run. Keys whose template fails or gives an empty name are reported with an error and not
+// renamed. A report is returned also when some renames failed.
+func (cm *ConfigManager) BulkRenameAccessKeys(serverName string, opts BulkRenameOptions) (*RenameReport, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "error", err)
+		return nil, err
+	}
+
+	report := &RenameReport{Server: serverName, DryRun: !cm.options.AssumeYes}
+	report.Keys, report.Unchanged = newKeyNames(accessKeys, opts)
+
+	failed := 0
+	for _, result := range report.Keys {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	if report.DryRun {
+		if failed > 0 {
+			return report, fmt.Errorf("the template failed for %d of %d keys", failed, len(report.Keys))
+		}
+		return report, nil
+	}
+
+	progress := cm.startProgress("Renaming keys", len(report.Keys))
+	for i := range report.Keys {
+		result := &report.Keys[i]
+		if result.Error == "" {
+			if err := apiClient.RenameAccessKey(server.URL, result.Key.ID, result.NewName); err != nil {
+				slog.Error("failed to rename access key", "keyID", result.Key.ID, "error", err)
+				result.Error = err.Error()
+				failed++
+			} else {
+				result.Renamed = true
+			}
+		}
+		progress.tick()
+	}
+
+	if failed > 0 {
+		return report, fmt.Errorf("failed to rename %d of %d keys", failed, len(report.Keys))
+	}
+	return report, nil
+}
+
+// newKeyNames executes the name template for every key whose name starts with the prefix, and
+// counts the keys it leaves with their current name
+func newKeyNames(keys []api.AccessKey, opts BulkRenameOptions) ([]RenameResult, int) {
+	selected := []RenameResult{}
+	unchanged := 0
+	for _, key := range keys {
+		if !strings.HasPrefix(key.Name, opts.NamePrefix) {
+			continue
+		}
+
+		result := RenameResult{Key: key}
+		var name strings.Builder
+		if err := opts.NameTemplate.Execute(&name, key); err != nil {
+			result.Error = err.Error()
+		} else if result.NewName = strings.TrimSpace(name.String()); result.NewName == "" {
+			result.Error = "the template gave an empty name"
+		} else if result.NewName == key.Name {
+			unchanged++
+			continue
+		}
+		selected = append(selected, result)
+	}
+	return selected, unchanged
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestNewKeyNames(t *testing.T) {
+	keys := []api.AccessKey{
+		{ID: "1", Name: "old-alice"},
+		{ID: "2", Name: "bob"},
+		{ID: "3", Name: "old-"},
+		{ID: "4", Name: "old-carol"},
+	}
+
+	tests := []struct {
+		name          string
+		template      string
+		wantNames     map[string]string
+		wantErrors    []string
+		wantUnchanged int
+	}{
+		{"prefix", "2024-{{.Name}}", map[string]string{"1": "2024-old-alice", "3": "2024-old-", "4": "2024-old-carol"}, nil, 0},
+		{"unchanged", "{{if eq .ID \"4\"}}{{.Name}}{{else}}new-{{.ID}}{{end}}", map[string]string{"1": "new-1", "3": "new-3"}, nil, 1},
+		{"empty name", "{{slice .Name 4}}", map[string]string{"1": "alice", "4": "carol"}, []string{"3"}, 0},
+		{"template error", "{{.Missing}}", map[string]string{}, []string{"1", "3", "4"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := BulkRenameOptions{NamePrefix: "old-", NameTemplate: template.Must(template.New("name").Parse(tt.template))}
+			results, unchanged := newKeyNames(keys, opts)
+
+			names := map[string]string{}
+			var errs []string
+			for _, result := range results {
+				if result.Error != "" {
+					errs = append(errs, result.Key.ID)
+					continue
+				}
+				names[result.Key.ID] = result.NewName
+			}
+			if len(names) != len(tt.wantNames) || strings.Join(errs, ",") != strings.Join(tt.wantErrors, ",") || unchanged != tt.wantUnchanged {
+				t.Fatalf("newKeyNames() = %v, errors for %v, %d unchanged; want %v, %v, %d", names, errs, unchanged, tt.wantNames, tt.wantErrors, tt.wantUnchanged)
+			}
+			for id, want := range tt.wantNames {
+				if names[id] != want {
+					t.Errorf("Key %s: expected new name %q, got %q", id, want, names[id])
+				}
+			}
+		})
+	}
+}
+
+func TestBulkRenameAccessKeys(t *testing.T) {
+	renamed := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/access-keys":
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{{ID: "1", Name: "old-alice"}, {ID: "2", Name: "bob"}}})
+		case r.Method == http.MethodPut && r.URL.Path == "/access-keys/1/name":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			renamed["1"] = body["name"]
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	opts := BulkRenameOptions{NamePrefix: "old-", NameTemplate: template.Must(template.New("name").Parse("2024-{{.Name}}"))}
+	for _, assumeYes := range []bool{false, true} {
+		cm := &ConfigManager{
+			options: Options{AssumeYes: assumeYes},
+			config: &Config{Servers: map[string]Server{
+				"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+			}},
+		}
+
+		report, err := cm.BulkRenameAccessKeys("test", opts)
+		if err != nil {
+			t.Fatalf("BulkRenameAccessKeys failed: %v", err)
+		}
+		if report.DryRun == assumeYes || len(report.Keys) != 1 || report.Keys[0].NewName != "2024-old-alice" || report.Keys[0].Renamed != assumeYes {
+			t.Errorf("With AssumeYes = %v, unexpected report %+v", assumeYes, report)
+		}
+		if got := renamed["1"]; (got != "") != assumeYes {
+			t.Errorf("With AssumeYes = %v, the server received the rename %q", assumeYes, got)
+		}
+	}
+	if renamed["1"] != "2024-old-alice" {
+		t.Errorf("Expected key 1 to be renamed to 2024-old-alice, got %q", renamed["1"])
+	}
+}
@@ -0,0 +1,14 @@
+package config
+
+import _ "embed"
+
+// configSchema is the JSON Schema of the config file. It is maintained by hand next to Config
+// and Server; TestConfigSchema fails when a field is missing from it.
+//
+//go:embed schema.json
+var configSchema []byte
+
+// ConfigSchema returns the JSON Schema of the config file, for validating configs in editors and CI
+func ConfigSchema() []byte {
+	return configSchema
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// schemaObject is the part of a JSON Schema object definition the test compares with the structs
+type schemaObject struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+	Required   []string                   `json:"required"`
+}
+
+func propertyNames(object schemaObject) []string {
+	var names []string
+	for name := range object.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestConfigSchema(t *testing.T) {
+	var schema struct {
+		schemaObject
+		Defs map[string]schemaObject `json:"$defs"`
+	}
+	if err := json.Unmarshal(ConfigSchema(), &schema); err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+
+	if got, want := propertyNames(schema.schemaObject), yamlFields(reflect.TypeFor[Config]()); !slices.Equal(got, want) {
+		t.Errorf("Config schema has properties %v, the Config struct has %v", got, want)
+	}
+
+	server := schema.Defs["server"]
+	if got, want := propertyNames(server), yamlFields(reflect.TypeFor[Server]()); !slices.Equal(got, want) {
+		t.Errorf("Server schema has properties %v, the Server struct has %v", got, want)
+	}
+	// The same fields validateConfig requires
+	if !slices.Equal(server.Required, []string{"url", "certSha256"}) {
+		t.Errorf("Expected url and certSha256 to be required, got %v", server.Required)
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	// UnitsSI formats sizes with powers of 1000 (kB, MB, GB)
+	UnitsSI = "si"
+	// UnitsIEC formats sizes with powers of 1024 (KiB, MiB, GiB)
+	UnitsIEC = "iec"
+)
+
+// FormatBytes renders a byte count in the given unit system, UnitsSI or UnitsIEC
+func FormatBytes(bytes int64, units string) string {
+	if units == UnitsIEC {
+		return humanize.IBytes(uint64(bytes))
+	}
+	return humanize.Bytes(uint64(bytes))
+}
+
+// AmbiguousSizeWarning explains why a size that parsed to bytes is probably not what was meant,
+// or returns "" when the size is unambiguous
+func AmbiguousSizeWarning(sizeStr string, bytes int64) string {
+	sizeStr = strings.TrimSpace(sizeStr)
+	number := strings.TrimRightFunc(sizeStr, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	unit := strings.TrimSpace(sizeStr[len(number):])
+
+	if unit == "" && strings.Contains(number, ".") {
+		return fmt.Sprintf("'%s' has no unit and is read as %d bytes; add a unit such as '%sGB'", sizeStr, bytes, number)
+	}
+
+	multiplier, err := humanize.ParseBytes("1" + unit)
+	if err != nil {
+		return ""
+	}
+	exact, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return ""
+	}
+	if !exact.Mul(exact, new(big.Rat).SetInt64(int64(multiplier))).IsInt() {
+		return fmt.Sprintf("'%s' is not a whole number of bytes and is truncated to %d bytes", sizeStr, bytes)
+	}
+	return ""
+}
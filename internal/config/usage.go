@@ -0,0 +1,113 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// Limit states of a key in a usage report
+const (
+	LimitUnlimited = "unlimited"
+	LimitWithin    = "within"
+	LimitExceeded  = "exceeded"
+)
+
+// KeyUsage is a row of a usage report
+type KeyUsage struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+	// Share is the key's percentage of the server's total transfer
+	Share float64 `json:"share"`
+	// DataLimit is the limit that applies to the key, its own or the server default
+	DataLimit   *int64 `json:"dataLimit,omitempty"`
+	LimitSource string `json:"limitSource,omitempty"`
+	LimitStatus string `json:"limitStatus"`
+}
+
+// UsageReport ranks the keys of a server by transfer. Total also counts keys that were deleted
+// since the metrics were last reset, so the shares can add up to less than 100%.
+type UsageReport struct {
+	Server          string     `json:"server"`
+	Keys            []KeyUsage `json:"keys"`
+	Total           int64      `json:"total"`
+	ServerCreatedAt *time.Time `json:"serverCreatedAt,omitempty"`
+}
+
+// GetUsageReport joins the keys of a server with their transfer, sorted by transfer, highest first
+func (cm *ConfigManager) GetUsageReport(serverName string) (*UsageReport, error) {
+	server, exists := cm.config.Servers[serverName]
+	if !exists {
+		slog.Error("server not found", "server", serverName)
+		return nil, serverNotFound(serverName)
+	}
+
+	apiClient, err := cm.getAPIClientForServer(serverName)
+	if err != nil {
+		slog.Error("failed to get API client", "error", err)
+		return nil, err
+	}
+
+	serverInfo, err := apiClient.GetServerInfo(server.URL)
+	if err != nil {
+		slog.Error("failed to get server info", "error", err)
+		return nil, err
+	}
+	if !serverInfo.MetricsEnabled {
+		return nil, fmt.Errorf("metrics are disabled on server '%s', so there is no usage to report", serverName)
+	}
+
+	metrics, err := apiClient.GetTransferMetrics(server.URL)
+	if err != nil {
+		slog.Error("failed to get metrics", "error", err)
+		return nil, err
+	}
+
+	accessKeys, err := apiClient.ListAccessKeys(server.URL)
+	if err != nil {
+		slog.Error("failed to list access keys", "error", err)
+		return nil, err
+	}
+
+	report := &UsageReport{Server: serverName, Keys: make([]KeyUsage, 0, len(accessKeys))}
+	if serverInfo.CreatedTimestampMs > 0 {
+		createdAt := time.UnixMilli(serverInfo.CreatedTimestampMs).UTC()
+		report.ServerCreatedAt = &createdAt
+	}
+	for _, bytes := range metrics.BytesTransferredByUserId {
+		report.Total += bytes
+	}
+
+	for _, key := range accessKeys {
+		usage := KeyUsage{
+			ID:          key.ID,
+			Name:        key.Name,
+			Bytes:       metrics.BytesTransferredByUserId[key.ID],
+			LimitStatus: LimitUnlimited,
+		}
+		if report.Total > 0 {
+			usage.Share = float64(usage.Bytes) * 100 / float64(report.Total)
+		}
+		if limit, source := effectiveDataLimit(key, serverInfo.AccessKeyDataLimit); limit != nil {
+			usage.DataLimit = &limit.Bytes
+			usage.LimitSource = source
+			usage.LimitStatus = LimitWithin
+			if usage.Bytes >= limit.Bytes {
+				usage.LimitStatus = LimitExceeded
+			}
+		}
+		report.Keys = append(report.Keys, usage)
+	}
+
+	slices.SortFunc(report.Keys, func(a, b KeyUsage) int {
+		if a.Bytes != b.Bytes {
+			return cmp.Compare(b.Bytes, a.Bytes)
+		}
+		return compareKeyIDs(a.ID, b.ID)
+	})
+
+	return report, nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestGetUsageReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/server":
+			json.NewEncoder(w).Encode(api.OutlineServer{MetricsEnabled: true, AccessKeyDataLimit: &api.DataLimit{Bytes: 250}})
+		case "/access-keys":
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{
+				{ID: "1", Name: "alice", DataLimit: &api.DataLimit{Bytes: 1000}},
+				{ID: "2", Name: "bob"},
+				{ID: "4", Name: "idle"},
+			}})
+		case "/metrics/transfer":
+			// Key 3 was deleted, but its transfer still counts towards the total
+			json.NewEncoder(w).Encode(api.TransferMetrics{BytesTransferredByUserId: map[string]int64{
+				"1": 100,
+				"2": 300,
+				"3": 100,
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	report, err := cm.GetUsageReport("test")
+	if err != nil {
+		t.Fatalf("GetUsageReport failed: %v", err)
+	}
+
+	if report.Total != 500 {
+		t.Errorf("Expected total 500, got %d", report.Total)
+	}
+
+	expected := []struct {
+		name   string
+		bytes  int64
+		share  float64
+		source string
+		status string
+	}{
+		{"bob", 300, 60, "server default", LimitExceeded},
+		{"alice", 100, 20, "key", LimitWithin},
+		{"idle", 0, 0, "server default", LimitWithin},
+	}
+	if len(report.Keys) != len(expected) {
+		t.Fatalf("Expected %d keys, got %+v", len(expected), report.Keys)
+	}
+	for i, want := range expected {
+		got := report.Keys[i]
+		if got.Name != want.name || got.Bytes != want.bytes || math.Abs(got.Share-want.share) > 0.001 || got.LimitSource != want.source || got.LimitStatus != want.status {
+			t.Errorf("Keys[%d] = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestGetUsageReportOrdersTiesByKeyID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/server":
+			json.NewEncoder(w).Encode(api.OutlineServer{MetricsEnabled: true})
+		case "/access-keys":
+			// Listed out of order; keys with equal transfer must still come out by ID
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{
+				{ID: "10"}, {ID: "2"}, {ID: "1"}, {ID: "9"},
+			}})
+		case "/metrics/transfer":
+			json.NewEncoder(w).Encode(api.TransferMetrics{BytesTransferredByUserId: map[string]int64{"9": 100}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cm := &ConfigManager{
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	report, err := cm.GetUsageReport("test")
+	if err != nil {
+		t.Fatalf("GetUsageReport failed: %v", err)
+	}
+
+	var ids []string
+	for _, usage := range report.Keys {
+		ids = append(ids, usage.ID)
+	}
+	if got := strings.Join(ids, ","); got != "9,1,2,10" {
+		t.Errorf("Expected keys in order 9,1,2,10, got %s", got)
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// WatchLimitsOptions configures WatchLimits
+type WatchLimitsOptions struct {
+	// Threshold is the percentage of its data limit at which a key is reported
+	Threshold float64
+	Interval  time.Duration
+}
+
+// LimitAlert reports a key whose usage reached the watch threshold of its data limit
+type LimitAlert struct {
+	Time        time.Time `json:"time"`
+	Server      string    `json:"server"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Bytes       int64     `json:"bytes"`
+	DataLimit   int64     `json:"dataLimit"`
+	LimitSource string    `json:"limitSource"`
+	// Percent is the share of the data limit used
+	Percent float64 `json:"percent"`
+}
+
+// WatchLimits polls the usage of a server's keys every interval until ctx is done and calls alert
+// for every key that reached the threshold since the previous poll. Keys already at the threshold
+// on the first poll are reported then. A key that drops below the threshold again, because its
+// limit was raised or the metrics were reset, is reported again when it next reaches it.
+//
+// A failing first poll is returned, since it usually means the server cannot be watched at all;
+// later failures are logged and the next poll is tried as usual.
+func (cm *ConfigManager) WatchLimits(ctx context.Context, serverName string, opts WatchLimitsOptions, alert func(LimitAlert)) error {
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	above := make(map[string]bool)
+	for first := true; ; first = false {
+		report, err := cm.GetUsageReport(serverName)
+		switch {
+		case err != nil && first:
+			return err
+		case err != nil:
+			slog.Warn("failed to poll key usage, trying again at the next interval", "server", serverName, "error", err)
+		default:
+			for _, limitAlert := range limitAlerts(report, opts.Threshold, above, time.Now()) {
+				alert(limitAlert)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// limitAlerts returns the keys of the report at or above threshold percent of their data limit
+// that were not in above, and updates above to the keys that are. Keys with a zero limit are
+// skipped, since they cannot transfer anything.
+func limitAlerts(report *UsageReport, threshold float64, above map[string]bool, now time.Time) []LimitAlert {
+	var alerts []LimitAlert
+	seen := make(map[string]bool, len(report.Keys))
+	for _, usage := range report.Keys {
+		if usage.DataLimit == nil || *usage.DataLimit == 0 {
+			continue
+		}
+
+		percent := float64(usage.Bytes) * 100 / float64(*usage.DataLimit)
+		if percent < threshold {
+			continue
+		}
+		seen[usage.ID] = true
+		if above[usage.ID] {
+			continue
+		}
+
+		alerts = append(alerts, LimitAlert{
+			Time:        now,
+			Server:      report.Server,
+			ID:          usage.ID,
+			Name:        usage.Name,
+			Bytes:       usage.Bytes,
+			DataLimit:   *usage.DataLimit,
+			LimitSource: usage.LimitSource,
+			Percent:     percent,
+		})
+	}
+
+	clear(above)
+	for id := range seen {
+		above[id] = true
+	}
+	return alerts
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/art-shutter/outline-cli/internal/api"
+)
+
+func TestLimitAlerts(t *testing.T) {
+	limit := int64(1000)
+	zero := int64(0)
+	report := func(aliceBytes int64) *UsageReport {
+		return &UsageReport{Server: "test", Keys: []KeyUsage{
+			{ID: "1", Name: "alice", Bytes: aliceBytes, DataLimit: &limit, LimitSource: "key"},
+			{ID: "2", Name: "bob", Bytes: 950, DataLimit: &limit, LimitSource: "server default"},
+			{ID: "3", Name: "carol", Bytes: 5000},
+			{ID: "4", Name: "dave", Bytes: 0, DataLimit: &zero},
+		}}
+	}
+
+	above := make(map[string]bool)
+	polls := []struct {
+		name       string
+		aliceBytes int64
+		want       []string
+	}{
+		{"first poll reports keys already above", 100, []string{"2"}},
+		{"key crossing is reported", 900, []string{"1"}},
+		{"keys still above are not reported again", 990, nil},
+		{"key dropping below is rearmed", 10, nil},
+		{"rearmed key is reported again", 1200, []string{"1"}},
+	}
+
+	for _, poll := range polls {
+		alerts := limitAlerts(report(poll.aliceBytes), 90, above, time.Now())
+		var got []string
+		for _, alert := range alerts {
+			got = append(got, alert.ID)
+		}
+		if !slices.Equal(got, poll.want) {
+			t.Errorf("%s: got alerts for %v, want %v", poll.name, got, poll.want)
+		}
+	}
+}
+
+func TestWatchLimits(t *testing.T) {
+	var mu sync.Mutex
+	used := int64(100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/server":
+			json.NewEncoder(w).Encode(api.OutlineServer{MetricsEnabled: true})
+		case "/access-keys":
+			json.NewEncoder(w).Encode(api.AccessKeysResponse{AccessKeys: []api.AccessKey{
+				{ID: "1", Name: "alice", DataLimit: &api.DataLimit{Bytes: 1000}},
+			}})
+		case "/metrics/transfer":
+			json.NewEncoder(w).Encode(api.TransferMetrics{BytesTransferredByUserId: map[string]int64{"1": used}})
+			// The key crosses the threshold between the first and the second poll
+			used = 950
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cm := &ConfigManager{
+		configPath: filepath.Join(t.TempDir(), "config.yaml"),
+		config: &Config{Servers: map[string]Server{
+			"test": {Name: "test", URL: server.URL, CertSha256: "dummy"},
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var alerts []LimitAlert
+	err := cm.WatchLimits(ctx, "test", WatchLimitsOptions{Threshold: 90, Interval: 10 * time.Millisecond}, func(alert LimitAlert) {
+		alerts = append(alerts, alert)
+		cancel()
+	})
+	if err != nil {
+		t.Fatalf("WatchLimits failed: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Name != "alice" || alerts[0].Percent != 95 {
+		t.Errorf("Expected one alert for alice at 95%%, got %+v", alerts)
+	}
+
+	if err := cm.WatchLimits(ctx, "missing", WatchLimitsOptions{Threshold: 90, Interval: time.Second}, func(LimitAlert) {}); err == nil {
+		t.Error("Expected a failing first poll to be returned")
+	}
+}